@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validLessonStatus reports whether a teacher may record status for a lesson.
+// Excused absences come from approved excuses and cannot be set directly.
+func validLessonStatus(status string) bool {
+	switch status {
+	case "present", "absent", "late":
+		return true
+	}
+	return false
+}
+
+// lessonOf identifies the lesson held on date, given by a timetable entry or by a subject and period.
+// It returns the timetable entry of the lesson; when none is scheduled, only the subject and period are set.
+// It writes the error response itself and returns false when the lesson cannot be identified.
+func lessonOf(c *gin.Context, timetableID *uint, subjectID, classPeriod uint, date string) (TimetableEntry, bool) {
+	if !validDate(date) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return TimetableEntry{}, false
+	}
+	weekday, _ := time.Parse("2006-01-02", date)
+	day := weekday.Weekday().String()
+	// Outside every term only the entries for every term are held, which the zero term selects
+	term, err := store.Terms.Containing(date)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return TimetableEntry{}, false
+	}
+	if timetableID == nil {
+		if subjectID == 0 || classPeriod == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Timetable ID, or subject ID and class period, are required"})
+			return TimetableEntry{}, false
+		}
+		entry, err := store.Timetable.Scheduled(subjectID, day, classPeriod, &term)
+		if err == sql.ErrNoRows {
+			return TimetableEntry{SubjectID: subjectID, ClassPeriod: classPeriod}, true
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable entry"})
+			return TimetableEntry{}, false
+		}
+		return entry, true
+	}
+
+	entry, err := store.Timetable.ByID(*timetableID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable entry not found"})
+		return entry, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable entry"})
+		return entry, false
+	}
+	if !strings.EqualFold(entry.Day, day) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The timetable entry is not held on " + day})
+		return entry, false
+	}
+	if (subjectID != 0 && subjectID != entry.SubjectID) || (classPeriod != 0 && classPeriod != entry.ClassPeriod) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject ID and class period must match the timetable entry"})
+		return entry, false
+	}
+	if entry.TermID != nil && *entry.TermID != term.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The timetable entry does not apply on " + date})
+		return entry, false
+	}
+	return entry, true
+}
+
+// classStudents returns the uids of the students of a class in the school year of date
+func classStudents(className string, date string) ([]uint, error) {
+	var yearID *uint
+	year, err := store.Terms.CurrentYear(date)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		yearID = &year.ID
+	}
+	members, err := store.Classes.Members(className, yearID)
+	if err != nil {
+		return nil, err
+	}
+	var students []uint
+	for _, member := range members {
+		user, err := store.Users.ByID(member.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Role == "student" {
+			students = append(students, user.UID)
+		}
+	}
+	return students, nil
+}
+
+// TakeLessonAttendance records the attendance of a whole class for one lesson. Students left out are present.
+// Taking attendance again for the same lesson corrects the existing records instead of adding new ones.
+func TakeLessonAttendance(c *gin.Context) {
+	var lesson LessonAttendance
+	if err := c.ShouldBindJSON(&lesson); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if lesson.Date == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date is required"})
+		return
+	}
+	entry, ok := lessonOf(c, lesson.TimetableID, lesson.SubjectID, lesson.ClassPeriod, lesson.Date)
+	if !ok {
+		return
+	}
+	subject, err := store.Subjects.ByID(entry.SubjectID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Subject not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+		return
+	}
+	if !requireTeacherSubject(c, subject.ID) {
+		return
+	}
+	if !requireOpenYear(c, lesson.Date) {
+		return
+	}
+
+	students, err := classStudents(subject.ClassName, lesson.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
+		return
+	}
+	if len(students) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No students in class"})
+		return
+	}
+	statuses := make(map[uint]string, len(students))
+	for _, uid := range students {
+		statuses[uid] = "present"
+	}
+	var outsiders []string
+	for _, record := range lesson.Records {
+		if !validLessonStatus(record.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Status must be present, absent, or late"})
+			return
+		}
+		if _, ok := statuses[record.UserID]; !ok {
+			outsiders = append(outsiders, strconv.FormatUint(uint64(record.UserID), 10))
+			continue
+		}
+		statuses[record.UserID] = record.Status
+	}
+	if len(outsiders) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Students " + strings.Join(outsiders, ", ") + " do not belong to class " + subject.ClassName})
+		return
+	}
+
+	existing, err := store.Attendance.ListByLesson(subject.ID, lesson.Date, entry.ClassPeriod)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+		return
+	}
+	previous := make(map[uint]Attendance, len(existing))
+	for _, att := range existing {
+		previous[att.UserID] = att
+	}
+
+	var changes []Attendance
+	for _, uid := range students {
+		att := Attendance{UserID: uid, SubjectID: subject.ID, Status: statuses[uid], Date: lesson.Date, ClassPeriod: &entry.ClassPeriod}
+		if entry.ID != 0 {
+			att.TimetableID = &entry.ID
+		}
+		// An absence on a day already covered by an approved excuse is recorded as excused
+		if att.Status == "absent" {
+			excuse, err := store.Excuses.Covering(uid, lesson.Date)
+			if err != nil && err != sql.ErrNoRows {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving excuse"})
+				return
+			}
+			if err == nil {
+				att.Status = "excused"
+				att.ExcuseID = &excuse.ID
+			}
+		}
+		if before, ok := previous[uid]; ok {
+			if before.Status == att.Status {
+				continue
+			}
+			att.ID = before.ID
+		}
+		changes = append(changes, att)
+	}
+
+	saved, err := store.Attendance.SaveLesson(changes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving attendance"})
+		return
+	}
+	created, updated := 0, 0
+	for _, att := range saved {
+		if before, ok := previous[att.UserID]; ok {
+			updated++
+			recordAudit(c, "update", "attendance", att.ID, before, att)
+			continue
+		}
+		created++
+		recordAudit(c, "create", "attendance", att.ID, nil, att)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Attendance saved successfully", "created": created, "updated": updated})
+}
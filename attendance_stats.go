@@ -0,0 +1,439 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttendanceRule flags students whose attendance falls below a threshold.
+// The rules are read from the JSON file named by ATTENDANCE_RULES, which replaces the default rule.
+type AttendanceRule struct {
+	Name         string  `json:"name"`          // Shown with the alerts the rule raises
+	Scope        string  `json:"scope"`         // "subject" checks each subject separately, "overall" all lessons together
+	MinPercent   float64 `json:"min_percent"`   // Students who attended a smaller share of lessons are flagged
+	MinLessons   int     `json:"min_lessons"`   // Recorded lessons needed before the rule applies
+	CountExcused bool    `json:"count_excused"` // Counts excused absences as attended
+}
+
+// attendanceRules are checked for attendance alerts, set by loadAttendanceRules.
+// By default a student attending less than half of the lessons of a subject is flagged, as they cannot be classified in it.
+var attendanceRules = []AttendanceRule{
+	{Name: "Below 50% in a subject", Scope: "subject", MinPercent: 50},
+}
+
+// loadAttendanceRules reads the attendance rules named by ATTENDANCE_RULES, keeping the default when unset
+func loadAttendanceRules() error {
+	path, exists := os.LookupEnv("ATTENDANCE_RULES")
+	if !exists {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid ATTENDANCE_RULES: %w", err)
+	}
+	var rules []AttendanceRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("invalid ATTENDANCE_RULES: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.Name == "" || (rule.Scope != "subject" && rule.Scope != "overall") || rule.MinPercent <= 0 || rule.MinPercent > 100 || rule.MinLessons < 0 {
+			return fmt.Errorf("invalid ATTENDANCE_RULES: rule %q needs a name, a subject or overall scope, min_percent in (0, 100] and a non-negative min_lessons", rule.Name)
+		}
+	}
+	attendanceRules = rules
+	return nil
+}
+
+// countAttendance adds n records with status to rate
+func countAttendance(rate *AttendanceRate, status string, n int) {
+	rate.Lessons += n
+	switch status {
+	case "present":
+		rate.Present += n
+	case "late":
+		rate.Late += n
+	case "absent":
+		rate.Absent += n
+	case "excused":
+		rate.Excused += n
+	}
+}
+
+// attendedPercent returns the share of lessons attended rounded to one decimal, or nil without lessons
+func attendedPercent(rate AttendanceRate, countExcused bool) *float64 {
+	if rate.Lessons == 0 {
+		return nil
+	}
+	attended := rate.Present + rate.Late
+	if countExcused {
+		attended += rate.Excused
+	}
+	percent := math.Round(float64(attended)*1000/float64(rate.Lessons)) / 10
+	return &percent
+}
+
+// statsRange reads the date range of attendance statistics from the from and to query parameters.
+// Without either, it is the term given by term_id, the current term by default.
+// It writes the error response itself and returns false when the range is invalid.
+func statsRange(c *gin.Context) (from, to string, ok bool) {
+	from, to = c.Query("from"), c.Query("to")
+	if (from != "" && !validDate(from)) || (to != "" && !validDate(to)) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return "", "", false
+	}
+	if from != "" && to != "" && from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "From date must not be after to date"})
+		return "", "", false
+	}
+	if from == "" && to == "" {
+		term, ok := termParam(c)
+		if !ok {
+			return "", "", false
+		}
+		if term != nil {
+			from, to = term.StartDate, term.EndDate
+		}
+	}
+	return from, to, true
+}
+
+// statsDate is the day whose class memberships the statistics of a range use: its end, or today when open
+func statsDate(to string) string {
+	if to == "" || to > today() {
+		return today()
+	}
+	return to
+}
+
+// optionalDate returns a range bound for the response, nil when open
+func optionalDate(date string) *string {
+	if date == "" {
+		return nil
+	}
+	return &date
+}
+
+// attendanceStats builds the statistics of students from their attendance counts.
+// Subject names are looked up in names and added to it.
+func attendanceStats(userIDs []uint, counts []AttendanceCount, names map[uint]string, from, to string) ([]StudentAttendanceStats, error) {
+	perSubject := make(map[uint]map[uint]*AttendanceRate, len(userIDs))
+	for _, count := range counts {
+		if perSubject[count.UserID] == nil {
+			perSubject[count.UserID] = make(map[uint]*AttendanceRate)
+		}
+		rate := perSubject[count.UserID][count.SubjectID]
+		if rate == nil {
+			rate = &AttendanceRate{}
+			perSubject[count.UserID][count.SubjectID] = rate
+		}
+		countAttendance(rate, count.Status, count.Count)
+	}
+
+	date := statsDate(to)
+	students := make([]StudentAttendanceStats, 0, len(userIDs))
+	for _, userID := range userIDs {
+		stats := StudentAttendanceStats{UserID: userID, From: optionalDate(from), To: optionalDate(to), Subjects: []SubjectAttendanceRate{}}
+		person, err := store.Users.Person(userID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		stats.FirstName, stats.LastName = person.FirstName, person.LastName
+		className, err := store.Classes.ClassOf(userID, date)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		stats.ClassName = className
+
+		for subjectID, rate := range perSubject[userID] {
+			name, ok := names[subjectID]
+			if !ok {
+				subject, err := store.Subjects.ByID(subjectID)
+				if err != nil {
+					return nil, err
+				}
+				name = subject.Name
+				names[subjectID] = name
+			}
+			rate.Percent = attendedPercent(*rate, false)
+			stats.Subjects = append(stats.Subjects, SubjectAttendanceRate{SubjectID: subjectID, Name: name, Rate: *rate})
+			countAttendance(&stats.Rate, "present", rate.Present)
+			countAttendance(&stats.Rate, "late", rate.Late)
+			countAttendance(&stats.Rate, "absent", rate.Absent)
+			countAttendance(&stats.Rate, "excused", rate.Excused)
+		}
+		stats.Rate.Percent = attendedPercent(stats.Rate, false)
+		sortSubjectRates(stats.Subjects)
+		students = append(students, stats)
+	}
+	return students, nil
+}
+
+func sortSubjectRates(subjects []SubjectAttendanceRate) {
+	sort.Slice(subjects, func(i, j int) bool {
+		if subjects[i].Name != subjects[j].Name {
+			return subjects[i].Name < subjects[j].Name
+		}
+		return subjects[i].SubjectID < subjects[j].SubjectID
+	})
+}
+
+// sortStudentStats orders students by last name, then first name
+func sortStudentStats(students []StudentAttendanceStats) {
+	sort.Slice(students, func(i, j int) bool {
+		if students[i].LastName != students[j].LastName {
+			return students[i].LastName < students[j].LastName
+		}
+		if students[i].FirstName != students[j].FirstName {
+			return students[i].FirstName < students[j].FirstName
+		}
+		return students[i].UserID < students[j].UserID
+	})
+}
+
+// classStats loads the attendance statistics of the students of a class over a date range
+func classStats(className, from, to string) ([]StudentAttendanceStats, error) {
+	students, err := classStudents(className, statsDate(to))
+	if err != nil {
+		return nil, err
+	}
+	counts, err := store.Attendance.Counts(students, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := attendanceStats(students, counts, map[uint]string{}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		// Students are listed under the class asked for, even if they have changed class since
+		stats[i].ClassName = className
+	}
+	sortStudentStats(stats)
+	return stats, nil
+}
+
+// requireStatsClass allows teachers to read the attendance statistics of classes they teach or are homeroom teacher of
+func requireStatsClass(c *gin.Context, className string) bool {
+	return teacherScope(c, func(teacherID uint) (bool, error) {
+		class, err := store.Classes.ByName(className)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if class.HomeroomTeacherID != nil && *class.HomeroomTeacherID == teacherID {
+			return true, nil
+		}
+		return teacherTeachesClass(teacherID, className)
+	})
+}
+
+// respondStudentStats writes a student's attendance statistics over the requested date range
+func respondStudentStats(c *gin.Context, studentID uint) {
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+	counts, err := store.Attendance.Counts([]uint{studentID}, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+		return
+	}
+	stats, err := attendanceStats([]uint{studentID}, counts, map[uint]string{}, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+		return
+	}
+	c.JSON(http.StatusOK, stats[0])
+}
+
+func GetAttendanceStats(c *gin.Context) {
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	respondStudentStats(c, uid)
+}
+
+func GetChildAttendanceStats(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	respondStudentStats(c, studentID)
+}
+
+// GetStudentAttendanceStats returns a student's attendance statistics to admins and to teachers of the student's class
+func GetStudentAttendanceStats(c *gin.Context) {
+	studentID, err := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid student ID"})
+		return
+	}
+	student, err := store.Users.ByID(uint(studentID))
+	if err == sql.ErrNoRows || (err == nil && student.Role != "student") {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Student not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving student"})
+		return
+	}
+	if c.GetString("role") == "teacher" {
+		className, err := store.Classes.ClassOf(student.UID, statsDate(c.Query("to")))
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+			return
+		}
+		if !requireStatsClass(c, className) {
+			return
+		}
+	}
+	respondStudentStats(c, student.UID)
+}
+
+// GetClassAttendanceStats returns the attendance of a class in total, per subject and per student
+func GetClassAttendanceStats(c *gin.Context) {
+	class, ok := classParam(c)
+	if !ok {
+		return
+	}
+	if !requireStatsClass(c, class.Name) {
+		return
+	}
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+	students, err := classStats(class.Name, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+		return
+	}
+
+	stats := ClassAttendanceStats{ClassName: class.Name, From: optionalDate(from), To: optionalDate(to), Subjects: []SubjectAttendanceRate{}, Students: students}
+	subjects := make(map[uint]*SubjectAttendanceRate)
+	for _, student := range students {
+		for _, subject := range student.Subjects {
+			total := subjects[subject.SubjectID]
+			if total == nil {
+				total = &SubjectAttendanceRate{SubjectID: subject.SubjectID, Name: subject.Name}
+				subjects[subject.SubjectID] = total
+			}
+			for _, rate := range []*AttendanceRate{&total.Rate, &stats.Rate} {
+				countAttendance(rate, "present", subject.Rate.Present)
+				countAttendance(rate, "late", subject.Rate.Late)
+				countAttendance(rate, "absent", subject.Rate.Absent)
+				countAttendance(rate, "excused", subject.Rate.Excused)
+			}
+		}
+	}
+	for _, subject := range subjects {
+		subject.Rate.Percent = attendedPercent(subject.Rate, false)
+		stats.Subjects = append(stats.Subjects, *subject)
+	}
+	sortSubjectRates(stats.Subjects)
+	stats.Rate.Percent = attendedPercent(stats.Rate, false)
+	c.JSON(http.StatusOK, stats)
+}
+
+// checkAttendanceRules returns the alerts a student's attendance raises
+func checkAttendanceRules(student StudentAttendanceStats) []AttendanceAlert {
+	var alerts []AttendanceAlert
+	check := func(rule AttendanceRule, rate AttendanceRate, subject *SubjectAttendanceRate) {
+		percent := attendedPercent(rate, rule.CountExcused)
+		if percent == nil || rate.Lessons < rule.MinLessons || *percent >= rule.MinPercent {
+			return
+		}
+		rate.Percent = percent
+		alert := AttendanceAlert{
+			UserID:     student.UserID,
+			FirstName:  student.FirstName,
+			LastName:   student.LastName,
+			ClassName:  student.ClassName,
+			Rule:       rule.Name,
+			MinPercent: rule.MinPercent,
+			Rate:       rate,
+		}
+		if subject != nil {
+			alert.SubjectID = &subject.SubjectID
+			alert.SubjectName = &subject.Name
+		}
+		alerts = append(alerts, alert)
+	}
+	for _, rule := range attendanceRules {
+		if rule.Scope == "overall" {
+			check(rule, student.Rate, nil)
+			continue
+		}
+		for i := range student.Subjects {
+			check(rule, student.Subjects[i].Rate, &student.Subjects[i])
+		}
+	}
+	return alerts
+}
+
+// GetAttendanceAlerts lists the students whose attendance breaks an attendance rule. Admins get every class
+// or the one selected with class_name; teachers get the classes they are homeroom teacher of, or one of them.
+func GetAttendanceAlerts(c *gin.Context) {
+	var classes []Class
+	if className := c.Query("class_name"); className != "" {
+		class, err := store.Classes.ByName(className)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Class not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+			return
+		}
+		if !requireHomeroom(c, class, "Only the homeroom teacher can view attendance alerts") {
+			return
+		}
+		classes = append(classes, class)
+	} else {
+		all, err := store.Classes.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving classes"})
+			return
+		}
+		uid, err := currentUserID(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		for _, class := range all {
+			if c.GetString("role") != "teacher" || (class.HomeroomTeacherID != nil && *class.HomeroomTeacherID == uid) {
+				classes = append(classes, class)
+			}
+		}
+	}
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	alerts := []AttendanceAlert{}
+	for _, class := range classes {
+		students, err := classStats(class.Name, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+			return
+		}
+		for _, student := range students {
+			alerts = append(alerts, checkAttendanceRules(student)...)
+		}
+	}
+	c.JSON(http.StatusOK, alerts)
+}
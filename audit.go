@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditJSON marshals an entity snapshot for the audit log, nil stays NULL
+func auditJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// recordAuditAs stores an audit log entry on behalf of the given actor.
+// A failure is logged rather than returned because the audited write has already happened.
+func recordAuditAs(c *gin.Context, actorID uint, actorRole, action, entityType string, entityID interface{}, before, after interface{}) {
+	var actor interface{}
+	if actorID != 0 {
+		actor = actorID
+	}
+	_, err := db.Exec("INSERT INTO audit_log (actor_id, actor_role, action, entity_type, entity_id, before_json, after_json, ip, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		actor, actorRole, action, entityType, fmt.Sprint(entityID), auditJSON(before), auditJSON(after), c.ClientIP(), formatTimestamp(time.Now()))
+	if err != nil {
+		log.Printf("audit log write failed (%s %s %v): %v", action, entityType, entityID, err)
+	}
+}
+
+// recordAudit stores an audit log entry for a write made by the authenticated user
+func recordAudit(c *gin.Context, action, entityType string, entityID interface{}, before, after interface{}) {
+	actorID, _ := currentUserID(c)
+	recordAuditAs(c, actorID, c.GetString("role"), action, entityType, entityID, before, after)
+}
+
+// auditDateBound turns a YYYY-MM-DD or RFC 3339 query value into a comparable timestamp.
+// Plain dates used as an upper bound include the whole day.
+func auditDateBound(value string, upper bool) (string, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if upper {
+			t = t.Add(24 * time.Hour)
+		}
+		return formatTimestamp(t), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	return formatTimestamp(t), nil
+}
+
+func GetAuditLog(c *gin.Context) {
+	query := "SELECT id, actor_id, actor_role, action, entity_type, entity_id, before_json, after_json, ip, created_at FROM audit_log WHERE 1 = 1"
+	var args []interface{}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query += " AND actor_id = ?"
+		args = append(args, actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		query += " AND action = ?"
+		args = append(args, action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query += " AND entity_type = ?"
+		args = append(args, entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query += " AND entity_id = ?"
+		args = append(args, entityID)
+	}
+	if from := c.Query("from"); from != "" {
+		bound, err := auditDateBound(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid from date"})
+			return
+		}
+		query += " AND created_at >= ?"
+		args = append(args, bound)
+	}
+	if to := c.Query("to"); to != "" {
+		bound, err := auditDateBound(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid to date"})
+			return
+		}
+		query += " AND created_at < ?"
+		args = append(args, bound)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Limit must be between 1 and 1000"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid offset"})
+		return
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving audit log"})
+		return
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var actorID sql.NullInt64
+		var actorRole, before, after, ip sql.NullString
+		if err := rows.Scan(&entry.ID, &actorID, &actorRole, &entry.Action, &entry.EntityType, &entry.EntityID, &before, &after, &ip, &entry.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning audit entry"})
+			return
+		}
+		entry.ActorID = uint(actorID.Int64)
+		entry.ActorRole = actorRole.String
+		entry.IP = ip.String
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
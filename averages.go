@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Value of the Polish "+" and "-" modifiers, so that "4+" counts as 4.5 and "5-" as 4.75
+const (
+	gradePlusModifier  = 0.5
+	gradeMinusModifier = -0.25
+)
+
+// numericGradeValue parses a grade such as "4", "3.5", "3,5", "4+" or "5-".
+// It is used for subjects without a grading scale and returns false for values
+// that cannot be averaged, such as "np", "banana" or "NaN". Modifiers never take
+// a grade outside 1-6, so "6+" counts as 6 and "1-" as 1.
+func numericGradeValue(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	modifier := 0.0
+	switch {
+	case strings.HasSuffix(value, "+"):
+		modifier = gradePlusModifier
+		value = strings.TrimSuffix(value, "+")
+	case strings.HasSuffix(value, "-"):
+		modifier = gradeMinusModifier
+		value = strings.TrimSuffix(value, "-")
+	}
+	base, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil || math.IsNaN(base) || base < 1 || base > 6 {
+		return 0, false
+	}
+	return math.Min(math.Max(base+modifier, 1), 6), true
+}
+
+// countsTowardsAverage reports whether a grade type is averaged; comments and behavior notes are not
+func countsTowardsAverage(gradeType string) bool {
+	return gradeType != "comment" && gradeType != "behavior note"
+}
+
+func roundAverage(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// gradeValuer returns the numeric equivalent of a grade, the ID of the grading scale it was read with
+// (0 without a scale) and false when the grade is not averaged
+type gradeValuer func(Grade) (value float64, scaleID uint, ok bool, err error)
+
+// gradeAverages computes the weighted averages of one student's grades.
+// The overall average is left out when the subjects are graded on different scales.
+func gradeAverages(userID uint, grades []Grade, valueOf gradeValuer) (GradeAverages, error) {
+	type sum struct {
+		weighted float64
+		average  SubjectAverage
+	}
+	sums := map[uint]*sum{}
+	var overallWeighted float64
+	var overallWeight int
+	scales := map[uint]bool{}
+
+	for _, grade := range grades {
+		if !countsTowardsAverage(grade.GradeType) {
+			continue
+		}
+		value, scaleID, ok, err := valueOf(grade)
+		if err != nil {
+			return GradeAverages{}, err
+		}
+		if !ok {
+			continue
+		}
+		weight := grade.Weight
+		if weight < 1 {
+			weight = 1
+		}
+
+		s, ok := sums[grade.SubjectID]
+		if !ok {
+			s = &sum{average: SubjectAverage{SubjectID: grade.SubjectID, Lowest: value, Highest: value}}
+			sums[grade.SubjectID] = s
+		}
+		s.weighted += value * float64(weight)
+		s.average.Count++
+		s.average.TotalWeight += weight
+		s.average.Lowest = math.Min(s.average.Lowest, value)
+		s.average.Highest = math.Max(s.average.Highest, value)
+
+		scales[scaleID] = true
+		overallWeighted += value * float64(weight)
+		overallWeight += weight
+	}
+
+	averages := GradeAverages{UserID: userID, Subjects: []SubjectAverage{}}
+	for _, s := range sums {
+		s.average.Average = roundAverage(s.weighted / float64(s.average.TotalWeight))
+		averages.Subjects = append(averages.Subjects, s.average)
+	}
+	sort.Slice(averages.Subjects, func(i, j int) bool {
+		return averages.Subjects[i].SubjectID < averages.Subjects[j].SubjectID
+	})
+	if overallWeight > 0 && len(scales) == 1 {
+		overall := roundAverage(overallWeighted / float64(overallWeight))
+		averages.Overall = &overall
+	}
+	return averages, nil
+}
+
+// respondAverages writes the averages of a student's grades in the term selected with ?term_id=
+func respondAverages(c *gin.Context, userID uint) {
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	grades, err := store.Grades.ListByStudent(userID, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grades"})
+		return
+	}
+	averages, err := gradeAverages(userID, grades, scaleGradeValues())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grading scale"})
+		return
+	}
+	c.JSON(http.StatusOK, averages)
+}
+
+func GetAverages(c *gin.Context) {
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	respondAverages(c, uid)
+}
+
+func GetStudentAverages(c *gin.Context) {
+	var user User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if !requireTeacherStudent(c, user.UID) {
+		return
+	}
+	respondAverages(c, user.UID)
+}
+
+func GetChildAverages(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	respondAverages(c, studentID)
+}
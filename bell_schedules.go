@@ -0,0 +1,342 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Period returns the times of a class period in the schedule
+func (schedule BellSchedule) Period(classPeriod uint) (BellPeriod, bool) {
+	for _, period := range schedule.Periods {
+		if period.ClassPeriod == classPeriod {
+			return period, true
+		}
+	}
+	return BellPeriod{}, false
+}
+
+// activeBellSchedule returns the bell schedule used on date: the one assigned to the date, else the default one,
+// or nil when there is neither. Without a date it returns the default schedule.
+func activeBellSchedule(schedules []BellSchedule, date string) *BellSchedule {
+	var fallback *BellSchedule
+	for i := range schedules {
+		for _, days := range schedules[i].Dates {
+			if date != "" && date >= days.StartDate && date <= days.EndDate {
+				return &schedules[i]
+			}
+		}
+		if schedules[i].IsDefault {
+			fallback = &schedules[i]
+		}
+	}
+	return fallback
+}
+
+// bellPeriod returns the times of a class period on date from the schedule used that day, or from the default
+// schedule when that one lacks the period, together with the schedule they come from
+func bellPeriod(schedules []BellSchedule, date string, classPeriod uint) (BellPeriod, *BellSchedule, bool) {
+	for _, schedule := range []*BellSchedule{activeBellSchedule(schedules, date), activeBellSchedule(schedules, "")} {
+		if schedule == nil {
+			continue
+		}
+		if period, ok := schedule.Period(classPeriod); ok {
+			return period, schedule, true
+		}
+	}
+	return BellPeriod{}, nil, false
+}
+
+// validateBellSchedule checks the name and periods of a new or edited schedule and sorts the periods.
+// It writes the error response itself and returns false when the schedule is invalid.
+func validateBellSchedule(c *gin.Context, schedule *BellSchedule) bool {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	if schedule.Name == "" || len(schedule.Periods) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name and at least one period are required"})
+		return false
+	}
+	sort.SliceStable(schedule.Periods, func(i, j int) bool {
+		return schedule.Periods[i].ClassPeriod < schedule.Periods[j].ClassPeriod
+	})
+	for i, period := range schedule.Periods {
+		if period.ClassPeriod == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class period must be at least 1"})
+			return false
+		}
+		if !validClockTime(period.StartTime) || !validClockTime(period.EndTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Time must be in HH:MM format"})
+			return false
+		}
+		if period.EndTime <= period.StartTime {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "End time must be after start time"})
+			return false
+		}
+		if i == 0 {
+			continue
+		}
+		previous := schedule.Periods[i-1]
+		if period.ClassPeriod == previous.ClassPeriod {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Class period %d is given twice", period.ClassPeriod)})
+			return false
+		}
+		if period.StartTime < previous.EndTime {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Class period %d must start after period %d ends", period.ClassPeriod, previous.ClassPeriod)})
+			return false
+		}
+	}
+
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return false
+	}
+	for _, existing := range schedules {
+		if existing.ID != schedule.ID && strings.EqualFold(existing.Name, schedule.Name) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Bell schedule already exists"})
+			return false
+		}
+	}
+	return true
+}
+
+// requirePeriodsInUse rejects a default schedule that lacks a period of the timetable entries following the bell schedule.
+// It writes the error response itself and returns false when the schedule must not become or stay the default.
+func requirePeriodsInUse(c *gin.Context, schedule BellSchedule) bool {
+	periods, err := store.Timetable.BellPeriodsInUse()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+		return false
+	}
+	for _, classPeriod := range periods {
+		if _, ok := schedule.Period(classPeriod); !ok {
+			c.JSON(http.StatusConflict, gin.H{"message": fmt.Sprintf("Class period %d is used by timetable entries following the bell schedule", classPeriod)})
+			return false
+		}
+	}
+	return true
+}
+
+// bellScheduleParam returns the schedule named by the :id parameter.
+// It writes the error response itself and returns false when there is no such schedule.
+func bellScheduleParam(c *gin.Context) (BellSchedule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid bell schedule ID"})
+		return BellSchedule{}, false
+	}
+	schedule, err := store.Bells.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Bell schedule not found"})
+		return schedule, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedule"})
+		return schedule, false
+	}
+	return schedule, true
+}
+
+// AddBellSchedule creates a bell schedule; the first one becomes the default
+func AddBellSchedule(c *gin.Context) {
+	var schedule BellSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	schedule.ID = 0
+	schedule.Dates = nil
+	if !validateBellSchedule(c, &schedule) {
+		return
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	if activeBellSchedule(schedules, "") == nil {
+		schedule.IsDefault = true
+	}
+	if schedule.IsDefault && !requirePeriodsInUse(c, schedule) {
+		return
+	}
+
+	id, err := store.Bells.Create(schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving bell schedule"})
+		return
+	}
+	schedule.ID = id
+	recordAudit(c, "create", "bell_schedule", schedule.ID, nil, schedule)
+	c.JSON(http.StatusCreated, gin.H{"message": "Bell schedule created successfully", "id": schedule.ID})
+}
+
+// UpdateBellSchedule renames a bell schedule and replaces its periods, retiming every lesson that follows it
+func UpdateBellSchedule(c *gin.Context) {
+	before, ok := bellScheduleParam(c)
+	if !ok {
+		return
+	}
+	var schedule BellSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	schedule.ID, schedule.IsDefault, schedule.Dates = before.ID, before.IsDefault, before.Dates
+	if !validateBellSchedule(c, &schedule) {
+		return
+	}
+	if schedule.IsDefault && !requirePeriodsInUse(c, schedule) {
+		return
+	}
+
+	err := store.Bells.Update(schedule)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Bell schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving bell schedule"})
+		return
+	}
+	recordAudit(c, "update", "bell_schedule", schedule.ID, before, schedule)
+	c.JSON(http.StatusOK, gin.H{"message": "Bell schedule updated successfully"})
+}
+
+func SetDefaultBellSchedule(c *gin.Context) {
+	var assignment BellScheduleAssignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	schedule, err := store.Bells.ByID(assignment.BellScheduleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Bell schedule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedule"})
+		return
+	}
+	if !requirePeriodsInUse(c, schedule) {
+		return
+	}
+	if err := store.Bells.SetDefault(schedule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving bell schedule"})
+		return
+	}
+	recordAudit(c, "update", "bell_schedule", "default", nil, assignment)
+	c.JSON(http.StatusOK, gin.H{"message": "Default bell schedule updated successfully"})
+}
+
+// AddBellScheduleDates makes a bell schedule replace the default one on a range of days, such as shortened days
+func AddBellScheduleDates(c *gin.Context) {
+	schedule, ok := bellScheduleParam(c)
+	if !ok {
+		return
+	}
+	var dates BellScheduleDates
+	if err := c.ShouldBindJSON(&dates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	dates.ID, dates.ScheduleID = 0, schedule.ID
+	if schedule.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The default bell schedule already applies on every other day"})
+		return
+	}
+	if dates.EndDate == "" {
+		dates.EndDate = dates.StartDate
+	}
+	if dates.StartDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Start date is required"})
+		return
+	}
+	if !validDate(dates.StartDate) || !validDate(dates.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if dates.EndDate < dates.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must not be before start date"})
+		return
+	}
+
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	for _, other := range schedules {
+		for _, days := range other.Dates {
+			if days.StartDate <= dates.EndDate && dates.StartDate <= days.EndDate {
+				c.JSON(http.StatusConflict, gin.H{"message": "Dates overlap those of " + other.Name, "id": days.ID})
+				return
+			}
+		}
+	}
+
+	id, err := store.Bells.AddDates(dates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving bell schedule dates"})
+		return
+	}
+	dates.ID = id
+	recordAudit(c, "create", "bell_schedule_dates", dates.ID, nil, dates)
+	c.JSON(http.StatusCreated, gin.H{"message": "Bell schedule dates added successfully", "id": dates.ID})
+}
+
+func DeleteBellScheduleDates(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid bell schedule dates ID"})
+		return
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	var before *BellScheduleDates
+	for _, schedule := range schedules {
+		for i := range schedule.Dates {
+			if schedule.Dates[i].ID == uint(id) {
+				before = &schedule.Dates[i]
+			}
+		}
+	}
+	if before == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Bell schedule dates not found"})
+		return
+	}
+
+	err = store.Bells.DeleteDates(before.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Bell schedule dates not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting bell schedule dates"})
+		return
+	}
+	recordAudit(c, "delete", "bell_schedule_dates", before.ID, *before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Bell schedule dates deleted successfully"})
+}
+
+// GetBellSchedules lists the bell schedules with the one used on ?date=, today by default
+func GetBellSchedules(c *gin.Context) {
+	date := c.DefaultQuery("date", today())
+	if !validDate(date) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bell_schedules": schedules, "active_schedule": activeBellSchedule(schedules, date)})
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // Lets CALENDAR_TIMEZONE work on hosts without a zoneinfo database
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	calendarLocation, _ = time.LoadLocation("Europe/Warsaw") // Time zone of the lesson times in the timetable
+	publicURL           string                               // Base URL of the API used in feed links, empty to use the request host
+)
+
+// loadCalendarConfig reads the time zone of the timetable from CALENDAR_TIMEZONE and the base URL of the feed links from PUBLIC_URL
+func loadCalendarConfig() error {
+	if name, exists := os.LookupEnv("CALENDAR_TIMEZONE"); exists {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return fmt.Errorf("CALENDAR_TIMEZONE: %w", err)
+		}
+		calendarLocation = location
+	}
+	publicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	return nil
+}
+
+// calendarFeedURL returns the address of the feed with the given token
+func calendarFeedURL(c *gin.Context, token string) string {
+	base := publicURL
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/api/calendar/" + token + ".ics"
+}
+
+// calendarUser returns the signed-in user, who must be a student or a teacher.
+// It writes the error response itself and returns false otherwise.
+func calendarUser(c *gin.Context) (uint, bool) {
+	role := c.GetString("role")
+	if role != "student" && role != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Calendar feeds are available to students and teachers"})
+		return 0, false
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return 0, false
+	}
+	return uid, true
+}
+
+// CreateCalendarToken issues the secret feed URL of the signed-in user, replacing the previous one
+func CreateCalendarToken(c *gin.Context) {
+	uid, ok := calendarUser(c)
+	if !ok {
+		return
+	}
+	token, err := generateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving calendar feed"})
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM calendar_tokens WHERE user_id = ?", uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving calendar feed"})
+		return
+	}
+	if _, err := tx.InsertID("id", "INSERT INTO calendar_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)",
+		uid, hashToken(token), formatTimestamp(time.Now())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving calendar feed"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving calendar feed"})
+		return
+	}
+	recordAudit(c, "create_calendar_feed", "user", uid, nil, nil)
+	c.JSON(http.StatusCreated, gin.H{"message": "Calendar feed created successfully", "url": calendarFeedURL(c, token)})
+}
+
+// DeleteCalendarToken revokes the feed URL of the signed-in user
+func DeleteCalendarToken(c *gin.Context) {
+	uid, ok := calendarUser(c)
+	if !ok {
+		return
+	}
+	result, err := db.Exec("DELETE FROM calendar_tokens WHERE user_id = ?", uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting calendar feed"})
+		return
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Calendar feed not found"})
+		return
+	}
+	recordAudit(c, "revoke_calendar_feed", "user", uid, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked successfully"})
+}
+
+// GetCalendarFeed serves the iCalendar feed named by its token. Calendar apps cannot sign in,
+// so the secret token in the URL is the only credential.
+func GetCalendarFeed(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok || token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Calendar feed not found"})
+		return
+	}
+	var uid uint
+	err := db.QueryRow("SELECT user_id FROM calendar_tokens WHERE token_hash = ?", hashToken(token)).Scan(&uid)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Calendar feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving calendar feed"})
+		return
+	}
+	user, err := store.Users.ByID(uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	// Lessons repeat through the current term only; exams are listed whatever their term
+	var term *Term
+	current, err := store.Terms.Current(today())
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return
+	}
+	if err == nil {
+		term = &current
+	}
+
+	var entries []TimetableEntry
+	var exams []Exam
+	switch user.Role {
+	case "teacher":
+		if term != nil {
+			entries, err = store.Timetable.ListByTeacher(user.UID, term)
+		}
+		if err == nil {
+			exams, err = store.Exams.ListByTeacher(user.UID, nil)
+		}
+	case "student":
+		className, classErr := store.Classes.ClassOf(user.UID, today())
+		if classErr != nil && classErr != sql.ErrNoRows {
+			err = classErr
+		}
+		// A student without a class gets an empty calendar rather than a broken subscription
+		if classErr == nil {
+			if term != nil {
+				entries, err = store.Timetable.ListByClass(className, term)
+			}
+			if err == nil {
+				exams, err = store.Exams.ListByClass(className, nil)
+			}
+		}
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"message": "Calendar feed not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving calendar"})
+		return
+	}
+
+	// Every lesson of the term is dated to apply its overrides and the holidays
+	var teacherID uint
+	if user.Role == "teacher" {
+		teacherID = user.UID
+	}
+	lessons := map[uint][]ScheduledLesson{}
+	if term != nil {
+		start, _ := time.Parse("2006-01-02", term.StartDate)
+		end, _ := time.Parse("2006-01-02", term.EndDate)
+		if teacherID != 0 {
+			entries, err = withSubstitutions(entries, teacherID, start, end)
+		}
+		var dated []ScheduledLesson
+		if err == nil {
+			dated, err = scheduledLessons(entries, start, end)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving calendar"})
+			return
+		}
+		for _, lesson := range dated {
+			lessons[lesson.ID] = append(lessons[lesson.ID], lesson)
+		}
+	}
+	subjects := map[uint]string{}
+	subjectName := func(id uint) (string, error) {
+		if name, ok := subjects[id]; ok {
+			return name, nil
+		}
+		subject, err := store.Subjects.ByID(id)
+		if err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+		subjects[id] = subject.Name
+		return subject.Name, nil
+	}
+
+	w := &calendarWriter{stamp: time.Now().UTC().Format("20060102T150405Z")}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Mercury//School Timetable//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + escapeCalendarText("Mercury"))
+	w.line("X-WR-TIMEZONE:" + calendarLocation.String())
+	if term != nil && len(lessons) > 0 {
+		start, _ := time.ParseInLocation("2006-01-02", term.StartDate, calendarLocation)
+		end, _ := time.ParseInLocation("2006-01-02", term.EndDate, calendarLocation)
+		w.timezone(calendarLocation, start, end.AddDate(0, 0, 1))
+		for _, entry := range entries {
+			name, err := subjectName(entry.SubjectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+				return
+			}
+			w.lesson(entry, name, lessons[entry.ID], term.EndDate, teacherID)
+		}
+	}
+	for _, exam := range exams {
+		name, err := subjectName(exam.SubjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+			return
+		}
+		w.exam(exam, name)
+	}
+	w.line("END:VCALENDAR")
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(w.b.String()))
+}
+
+// calendarWriter builds an iCalendar (RFC 5545) document
+type calendarWriter struct {
+	b     strings.Builder
+	stamp string // DTSTAMP of every event, the time the feed was generated
+}
+
+// line writes a content line, folding it into lines of at most 75 octets
+func (w *calendarWriter) line(content string) {
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.b.WriteString(content[:cut])
+		w.b.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = 74
+	}
+	w.b.WriteString(content)
+	w.b.WriteString("\r\n")
+}
+
+var calendarTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeCalendarText escapes a TEXT property value
+func escapeCalendarText(value string) string {
+	return calendarTextEscaper.Replace(value)
+}
+
+// calendarOffset formats a UTC offset in seconds as +HHMM
+func calendarOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+}
+
+// timezone writes the VTIMEZONE of location with the offset changes between from and to
+func (w *calendarWriter) timezone(location *time.Location, from, to time.Time) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + location.String())
+	observance := func(start string, at time.Time, offsetFrom int) {
+		name, offset := at.Zone()
+		kind := "STANDARD"
+		if at.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		w.line("BEGIN:" + kind)
+		w.line("DTSTART:" + start)
+		w.line("TZOFFSETFROM:" + calendarOffset(offsetFrom))
+		w.line("TZOFFSETTO:" + calendarOffset(offset))
+		w.line("TZNAME:" + name)
+		w.line("END:" + kind)
+	}
+	at := from.In(location)
+	_, offset := at.Zone()
+	observance("19700101T000000", at, offset)
+	for {
+		_, end := at.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		// The onset is given in the local time that was in effect before it
+		start := end.In(time.FixedZone("", offset)).Format("20060102T150405")
+		at = end.In(location)
+		observance(start, at, offset)
+		_, offset = at.Zone()
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// calendarTime formats a YYYY-MM-DD date and an HH:MM time as a local DATE-TIME value
+func calendarTime(date, clock string) string {
+	return strings.ReplaceAll(date, "-", "") + "T" + strings.Replace(clock, ":", "", 1) + "00"
+}
+
+// lesson writes the dated lessons of a timetable entry as an event repeating weekly through the term. Cancelled
+// lessons, and in a teacher's calendar those given to a substitute, are excluded; changed ones replace their occurrence.
+// When teacherID only substitutes in the entry, just the lessons they take are written, as single events.
+func (w *calendarWriter) lesson(entry TimetableEntry, subjectName string, lessons []ScheduledLesson, termEnd string, teacherID uint) {
+	if len(lessons) == 0 {
+		return
+	}
+	tzid := ";TZID=" + calendarLocation.String() + ":"
+	if teacherID != 0 && entry.TeacherID != teacherID {
+		for _, lesson := range lessons {
+			if lesson.Cancelled || lesson.TeacherID != teacherID {
+				continue
+			}
+			w.line("BEGIN:VEVENT")
+			w.line(fmt.Sprintf("UID:timetable-%d-%s@mercury", entry.ID, strings.ReplaceAll(lesson.Date, "-", "")))
+			w.line("DTSTAMP:" + w.stamp)
+			w.lessonDetails(lesson.TimetableEntry, lesson.Date, subjectName, lesson.Note)
+			w.line("END:VEVENT")
+		}
+		return
+	}
+
+	var excluded []string
+	var changed []ScheduledLesson
+	for _, lesson := range lessons {
+		if lesson.Cancelled || (teacherID != 0 && lesson.TeacherID != teacherID) {
+			excluded = append(excluded, calendarTime(lesson.Date, entry.StartTime))
+		} else if len(lesson.Changes) > 0 {
+			changed = append(changed, lesson)
+		}
+	}
+	end, _ := time.ParseInLocation("2006-01-02", termEnd, calendarLocation)
+	until := end.AddDate(0, 0, 1).Add(-time.Second).UTC().Format("20060102T150405Z")
+	uid := fmt.Sprintf("UID:timetable-%d@mercury", entry.ID)
+
+	w.line("BEGIN:VEVENT")
+	w.line(uid)
+	w.line("DTSTAMP:" + w.stamp)
+	w.line("RRULE:FREQ=WEEKLY;UNTIL=" + until)
+	if len(excluded) > 0 {
+		w.line("EXDATE" + tzid + strings.Join(excluded, ","))
+	}
+	w.lessonDetails(entry, lessons[0].Date, subjectName, "")
+	w.line("END:VEVENT")
+	for _, lesson := range changed {
+		w.line("BEGIN:VEVENT")
+		w.line(uid)
+		w.line("DTSTAMP:" + w.stamp)
+		w.line("RECURRENCE-ID" + tzid + calendarTime(lesson.Date, entry.StartTime))
+		w.lessonDetails(lesson.TimetableEntry, lesson.Date, subjectName, lesson.Note)
+		w.line("END:VEVENT")
+	}
+}
+
+// lessonDetails writes the time, place and description of a lesson held on date
+func (w *calendarWriter) lessonDetails(entry TimetableEntry, date, subjectName, note string) {
+	tzid := ";TZID=" + calendarLocation.String() + ":"
+	w.line("DTSTART" + tzid + calendarTime(date, entry.StartTime))
+	w.line("DTEND" + tzid + calendarTime(date, entry.EndTime))
+	w.line("SUMMARY:" + escapeCalendarText(subjectName))
+	if entry.Room != "" {
+		w.line("LOCATION:" + escapeCalendarText(entry.Room))
+	}
+	description := "Class " + entry.ClassName
+	if entry.ClassPeriod != 0 {
+		description += fmt.Sprintf(", period %d", entry.ClassPeriod)
+	}
+	if note != "" {
+		description += "\n" + note
+	}
+	w.line("DESCRIPTION:" + escapeCalendarText(description))
+}
+
+// exam writes an exam as an all-day event
+func (w *calendarWriter) exam(exam Exam, subjectName string) {
+	date, err := time.Parse("2006-01-02", exam.Date)
+	if err != nil {
+		return
+	}
+	description := "Class " + exam.ClassName
+	if exam.Description != "" {
+		description += "\n" + exam.Description
+	}
+
+	w.line("BEGIN:VEVENT")
+	w.line(fmt.Sprintf("UID:exam-%d@mercury", exam.ID))
+	w.line("DTSTAMP:" + w.stamp)
+	w.line("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+	w.line("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+	w.line("SUMMARY:" + escapeCalendarText(strings.TrimSpace(subjectName+" "+exam.Type)))
+	w.line("DESCRIPTION:" + escapeCalendarText(description))
+	w.line("TRANSP:TRANSPARENT")
+	w.line("END:VEVENT")
+}
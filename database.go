@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Supported values of DB_DRIVER
+const (
+	driverSQLite   = "sqlite"
+	driverPostgres = "postgres"
+)
+
+// DB is the connection pool together with the SQL dialect of its driver.
+// Queries are written once with ? placeholders and rewritten to $1, $2, ... for PostgreSQL.
+type DB struct {
+	*sql.DB
+	driver string
+}
+
+// Tx is a transaction on a DB, rewriting queries like DB does
+type Tx struct {
+	*sql.Tx
+	driver string
+}
+
+// openDB opens the database selected by DB_DRIVER: SQLite at DB_PATH (the default)
+// or PostgreSQL at DATABASE_URL
+func openDB() (*DB, error) {
+	driver, exists := os.LookupEnv("DB_DRIVER")
+	if !exists {
+		driver = driverSQLite
+	}
+
+	switch driver {
+	case driverSQLite:
+		dbPath, exists := os.LookupEnv("DB_PATH")
+		if !exists {
+			dbPath = "./database.db"
+		}
+		// Concurrent writers wait for each other instead of failing with "database is locked"
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		conn, err := sql.Open("sqlite", dbPath+separator+"_pragma=busy_timeout(5000)")
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: conn, driver: driver}, nil
+	case driverPostgres:
+		url, exists := os.LookupEnv("DATABASE_URL")
+		if !exists {
+			return nil, fmt.Errorf("DATABASE_URL environment variable is not set")
+		}
+		conn, err := sql.Open("pgx", url)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: conn, driver: driver}, nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q, expected %q or %q", driver, driverSQLite, driverPostgres)
+	}
+}
+
+// rebind rewrites ? placeholders for the driver, leaving quoted literals and identifiers alone
+func rebind(driver, query string) string {
+	if driver != driverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bindArgs stores booleans as 0/1, since flag columns are INTEGER on every driver
+func bindArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if v, ok := arg.(bool); ok {
+			if v {
+				args[i] = 1
+			} else {
+				args[i] = 0
+			}
+		}
+	}
+	return args
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(rebind(db.driver, query), bindArgs(args)...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(rebind(db.driver, query), bindArgs(args)...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(rebind(db.driver, query), bindArgs(args)...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, driver: db.driver}, nil
+}
+
+// InsertID runs an INSERT and returns the generated value of the key column pk
+func (db *DB) InsertID(pk, query string, args ...interface{}) (int64, error) {
+	return insertID(db, db.driver, pk, query, args...)
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(rebind(tx.driver, query), bindArgs(args)...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(rebind(tx.driver, query), bindArgs(args)...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(rebind(tx.driver, query), bindArgs(args)...)
+}
+
+// InsertID runs an INSERT and returns the generated value of the key column pk
+func (tx *Tx) InsertID(pk, query string, args ...interface{}) (int64, error) {
+	return insertID(tx, tx.driver, pk, query, args...)
+}
+
+// execer is implemented by DB and Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertID reads the new key back with RETURNING on PostgreSQL, whose driver has no LastInsertId
+func insertID(e execer, driver, pk, query string, args ...interface{}) (int64, error) {
+	if driver == driverPostgres {
+		var id int64
+		err := e.QueryRow(query+" RETURNING "+pk, args...).Scan(&id)
+		return id, err
+	}
+	result, err := e.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validExcuseStatus reports whether status may be used to filter excuses; empty selects every status
+func validExcuseStatus(status string) bool {
+	switch status {
+	case "", "pending", "approved", "rejected":
+		return true
+	}
+	return false
+}
+
+// submitExcuse stores an excuse for a student's absences on behalf of the logged-in student or parent
+func submitExcuse(c *gin.Context, studentID uint) {
+	var excuse Excuse
+	if err := c.ShouldBindJSON(&excuse); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	excuse.Reason = strings.TrimSpace(excuse.Reason)
+	if excuse.Reason == "" || excuse.StartDate == "" || excuse.EndDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Reason, start date, and end date are required"})
+		return
+	}
+	if !validDate(excuse.StartDate) || !validDate(excuse.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if excuse.EndDate < excuse.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must not be before start date"})
+		return
+	}
+	if !requireOpenYear(c, excuse.StartDate) {
+		return
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	created := Excuse{
+		UserID:      studentID,
+		SubmittedBy: uid,
+		Reason:      excuse.Reason,
+		StartDate:   excuse.StartDate,
+		EndDate:     excuse.EndDate,
+		Status:      "pending",
+		CreatedAt:   formatTimestamp(time.Now()),
+	}
+	id, err := store.Excuses.Create(created)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving excuse"})
+		return
+	}
+	created.ID = id
+	recordAudit(c, "create", "excuse", created.ID, nil, created)
+	c.JSON(http.StatusCreated, gin.H{"message": "Excuse submitted successfully", "id": created.ID})
+}
+
+func SubmitExcuse(c *gin.Context) {
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	submitExcuse(c, uid)
+}
+
+func SubmitChildExcuse(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	submitExcuse(c, studentID)
+}
+
+// respondExcuses writes the excuses submitted for a student
+func respondExcuses(c *gin.Context, studentID uint) {
+	excuses, err := store.Excuses.ListByStudent(studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving excuses"})
+		return
+	}
+	c.JSON(http.StatusOK, excuses)
+}
+
+func GetExcuses(c *gin.Context) {
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	respondExcuses(c, uid)
+}
+
+func GetChildExcuses(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	respondExcuses(c, studentID)
+}
+
+// GetClassExcuses lists excuses to review. Admins select a class; teachers get the excuses of the classes
+// they are homeroom teacher of, or of one of them.
+func GetClassExcuses(c *gin.Context) {
+	className := c.Query("class_name")
+	status := c.Query("status")
+	if !validExcuseStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid status"})
+		return
+	}
+
+	var excuses []Excuse
+	var err error
+	if className == "" {
+		if c.GetString("role") != "teacher" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required"})
+			return
+		}
+		uid, uidErr := currentUserID(c)
+		if uidErr != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		excuses, err = store.Excuses.ListByHomeroom(uid, status)
+	} else {
+		class, classErr := store.Classes.ByName(className)
+		if classErr == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Class not found"})
+			return
+		}
+		if classErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+			return
+		}
+		if !requireHomeroom(c, class, "Only the homeroom teacher can review excuses") {
+			return
+		}
+		excuses, err = store.Excuses.ListByClass(class.Name, status)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving excuses"})
+		return
+	}
+	c.JSON(http.StatusOK, excuses)
+}
+
+// requireExcuseHomeroom allows teachers to review only excuses of students of their homeroom class
+func requireExcuseHomeroom(c *gin.Context, excuse Excuse) bool {
+	if c.GetString("role") != "teacher" {
+		return true
+	}
+	className, err := store.Classes.ClassOf(excuse.UserID, excuse.StartDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the homeroom teacher can review excuses"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+		return false
+	}
+	class, err := store.Classes.ByName(className)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+		return false
+	}
+	return requireHomeroom(c, class, "Only the homeroom teacher can review excuses")
+}
+
+func ReviewExcuse(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid excuse ID"})
+		return
+	}
+	var review ExcuseReview
+	if err := c.ShouldBindJSON(&review); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if review.Status != "approved" && review.Status != "rejected" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Status must be approved or rejected"})
+		return
+	}
+	if review.Comment != nil {
+		comment := strings.TrimSpace(*review.Comment)
+		review.Comment = &comment
+		if comment == "" {
+			review.Comment = nil
+		}
+	}
+
+	excuse, err := store.Excuses.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Excuse not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving excuse"})
+		return
+	}
+	if !requireExcuseHomeroom(c, excuse) {
+		return
+	}
+	if excuse.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"message": "Excuse has already been reviewed"})
+		return
+	}
+	if !requireOpenYear(c, excuse.StartDate) {
+		return
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	now := formatTimestamp(time.Now())
+	excused := 0
+	if review.Status == "approved" {
+		excused, err = store.Excuses.Approve(excuse.ID, uid, review.Comment, now)
+	} else {
+		err = store.Excuses.Reject(excuse.ID, uid, review.Comment, now)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"message": "Excuse has already been reviewed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error reviewing excuse"})
+		return
+	}
+
+	reviewed := excuse
+	reviewed.Status = review.Status
+	reviewed.ReviewedBy = &uid
+	reviewed.ReviewedAt = &now
+	reviewed.ReviewComment = review.Comment
+	if review.Status == "approved" {
+		recordAudit(c, "approve", "excuse", excuse.ID, excuse, reviewed)
+		c.JSON(http.StatusOK, gin.H{"message": "Excuse approved successfully", "excused": excused})
+		return
+	}
+	recordAudit(c, "reject", "excuse", excuse.ID, excuse, reviewed)
+	c.JSON(http.StatusOK, gin.H{"message": "Excuse rejected successfully"})
+}
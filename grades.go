@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gradeHistoryForStudents lets students read the revision history of their own grades
+var gradeHistoryForStudents = false
+
+// gradeTypes lists the values accepted in grades.grade_type
+var gradeTypes = []string{"numeric", "comment", "behavior note", "custom"}
+
+func validGradeType(gradeType string) bool {
+	for _, t := range gradeTypes {
+		if t == gradeType {
+			return true
+		}
+	}
+	return false
+}
+
+// maxGradeWeight is the largest weight a grade can count with in averages
+const maxGradeWeight = 10
+
+func validGradeWeight(weight int) bool {
+	return weight >= 1 && weight <= maxGradeWeight
+}
+
+// validDate reports whether value is a YYYY-MM-DD calendar date
+func validDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+// gradeParam loads the grade named by the :id path parameter.
+// It writes the error response itself and returns false when the grade cannot be loaded.
+func gradeParam(c *gin.Context) (Grade, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid grade ID"})
+		return Grade{}, false
+	}
+	grade, err := store.Grades.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Grade not found"})
+		return Grade{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grade"})
+		return Grade{}, false
+	}
+	return grade, true
+}
+
+func UpdateGrade(c *gin.Context) {
+	grade, ok := gradeParam(c)
+	if !ok {
+		return
+	}
+
+	var change GradeChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if change.Grade == "" || change.GradeType == "" || change.Date == "" || change.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Grade, grade type, date, and reason are required"})
+		return
+	}
+	if !validGradeType(change.GradeType) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid grade type"})
+		return
+	}
+	if !validDate(change.Date) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if change.Weight != 0 && !validGradeWeight(change.Weight) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Weight must be between 1 and 10"})
+		return
+	}
+
+	if !requireTeacherSubject(c, grade.SubjectID) {
+		return
+	}
+	if !requireOpenYear(c, grade.Date) || !requireOpenYear(c, change.Date) {
+		return
+	}
+	if !requireGradeOnScale(c, grade.SubjectID, change.GradeType, &change.Grade) {
+		return
+	}
+	editorID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	updated := grade
+	updated.Grade = change.Grade
+	updated.GradeType = change.GradeType
+	if change.Weight != 0 {
+		updated.Weight = change.Weight
+	}
+	updated.Date = change.Date
+	if err := store.Grades.Update(updated, editorID, change.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating grade"})
+		return
+	}
+
+	recordAudit(c, "update", "grade", grade.ID, grade, updated)
+	c.JSON(http.StatusOK, gin.H{"message": "Grade updated successfully"})
+}
+
+func DeleteGrade(c *gin.Context) {
+	grade, ok := gradeParam(c)
+	if !ok {
+		return
+	}
+
+	var change GradeChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if change.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Reason is required"})
+		return
+	}
+
+	if !requireTeacherSubject(c, grade.SubjectID) {
+		return
+	}
+	if !requireOpenYear(c, grade.Date) {
+		return
+	}
+	editorID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	if err := store.Grades.Delete(grade.ID, editorID, change.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting grade"})
+		return
+	}
+
+	recordAudit(c, "delete", "grade", grade.ID, grade, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Grade deleted successfully"})
+}
+
+// gradeHistory returns the current state of a grade (nil once deleted) and its revisions.
+// found is false when the grade never existed.
+func gradeHistory(gradeID uint) (current *Grade, revisions []GradeRevision, found bool, err error) {
+	grade, err := store.Grades.ByID(gradeID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, false, err
+	}
+	if err == nil {
+		current = &grade
+	}
+	revisions, err = store.Grades.Revisions(gradeID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return current, revisions, current != nil || len(revisions) > 0, nil
+}
+
+// historyStudentID returns the student a grade belongs to, from the grade itself or its last revision
+func historyStudentID(current *Grade, revisions []GradeRevision) uint {
+	if current != nil {
+		return current.UserID
+	}
+	return revisions[len(revisions)-1].UserID
+}
+
+func GetGradeHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid grade ID"})
+		return
+	}
+
+	current, revisions, found, err := gradeHistory(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grade history"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Grade not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grade": current, "revisions": revisions})
+}
+
+func GetOwnGradeHistory(c *gin.Context) {
+	if !gradeHistoryForStudents {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Grade history is not available to students"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid grade ID"})
+		return
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	current, revisions, found, err := gradeHistory(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grade history"})
+		return
+	}
+	// Another student's grade is reported as missing rather than forbidden
+	if !found || historyStudentID(current, revisions) != uid {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Grade not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grade": current, "revisions": revisions})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lookup returns the scale value matching a grade. List values match case-insensitively;
+// range values are numbers, optionally with a "%" suffix or a decimal comma.
+func (scale GradingScale) Lookup(grade string) (GradingScaleValue, bool) {
+	grade = strings.TrimSpace(grade)
+	if scale.Kind == "range" {
+		number, err := strconv.ParseFloat(strings.Replace(strings.TrimSuffix(grade, "%"), ",", ".", 1), 64)
+		if err != nil || math.IsNaN(number) || scale.MinValue == nil || scale.MaxValue == nil || number < *scale.MinValue || number > *scale.MaxValue {
+			return GradingScaleValue{}, false
+		}
+		return GradingScaleValue{Value: grade, NumericValue: &number}, true
+	}
+	for _, value := range scale.Values {
+		if strings.EqualFold(value.Value, grade) {
+			return value, true
+		}
+	}
+	return GradingScaleValue{}, false
+}
+
+// subjectScale returns the grading scale used by a subject, or nil when neither the subject nor the school has one
+func subjectScale(subjectID uint) (*GradingScale, error) {
+	scale, err := store.Scales.ForSubject(subjectID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &scale, nil
+}
+
+// requireGradeOnScale checks a numeric grade against the subject's grading scale and rewrites it
+// to the scale's spelling. Without a scale the grade must be a Polish-style number such as "4+".
+// It writes the error response itself and returns false when the grade is rejected.
+func requireGradeOnScale(c *gin.Context, subjectID uint, gradeType string, grade *string) bool {
+	if gradeType != "numeric" {
+		return true
+	}
+	scale, err := subjectScale(subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grading scale"})
+		return false
+	}
+	if scale == nil {
+		if _, ok := numericGradeValue(*grade); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Grade does not match the subject's grading scale"})
+			return false
+		}
+		return true
+	}
+	value, ok := scale.Lookup(*grade)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Grade does not match the subject's grading scale"})
+		return false
+	}
+	*grade = value.Value
+	return true
+}
+
+// scaleGradeValues returns the numeric value of grades under their subjects' grading scales.
+// Scales are looked up once per subject.
+func scaleGradeValues() gradeValuer {
+	scales := map[uint]*GradingScale{}
+	return func(grade Grade) (float64, uint, bool, error) {
+		scale, ok := scales[grade.SubjectID]
+		if !ok {
+			var err error
+			if scale, err = subjectScale(grade.SubjectID); err != nil {
+				return 0, 0, false, err
+			}
+			scales[grade.SubjectID] = scale
+		}
+		if scale == nil {
+			value, ok := numericGradeValue(grade.Grade)
+			return value, 0, ok, nil
+		}
+		value, ok := scale.Lookup(grade.Grade)
+		if !ok || value.NumericValue == nil {
+			return 0, 0, false, nil
+		}
+		return *value.NumericValue, scale.ID, true, nil
+	}
+}
+
+func AddGradingScale(c *gin.Context) {
+	var scale GradingScale
+	if err := c.ShouldBindJSON(&scale); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	scale.Name = strings.TrimSpace(scale.Name)
+	if scale.Name == "" || scale.Kind == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name and kind are required"})
+		return
+	}
+
+	switch scale.Kind {
+	case "list":
+		if len(scale.Values) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "A list scale needs at least one value"})
+			return
+		}
+		seen := map[string]bool{}
+		for i, value := range scale.Values {
+			value.Value = strings.TrimSpace(value.Value)
+			key := strings.ToLower(value.Value)
+			if value.Value == "" || seen[key] {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Scale values must be unique and not empty"})
+				return
+			}
+			seen[key] = true
+			scale.Values[i] = value
+		}
+		scale.MinValue, scale.MaxValue = nil, nil
+	case "range":
+		if scale.MinValue == nil || scale.MaxValue == nil || *scale.MinValue >= *scale.MaxValue {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "A range scale needs a min value below its max value"})
+			return
+		}
+		scale.Values = nil
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Kind must be list or range"})
+		return
+	}
+
+	scales, err := store.Scales.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grading scales"})
+		return
+	}
+	for _, existing := range scales {
+		if strings.EqualFold(existing.Name, scale.Name) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Grading scale already exists"})
+			return
+		}
+	}
+
+	id, err := store.Scales.Create(scale)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving grading scale"})
+		return
+	}
+	scale.ID = id
+	recordAudit(c, "create", "grading_scale", scale.ID, nil, scale)
+	c.JSON(http.StatusCreated, gin.H{"message": "Grading scale created successfully", "id": scale.ID})
+}
+
+func GetGradingScales(c *gin.Context) {
+	scales, err := store.Scales.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grading scales"})
+		return
+	}
+	c.JSON(http.StatusOK, scales)
+}
+
+// requireScaleExists writes 404 and returns false when a scale ID is given but unknown
+func requireScaleExists(c *gin.Context, scaleID *uint) bool {
+	if scaleID == nil {
+		return true
+	}
+	_, err := store.Scales.ByID(*scaleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Grading scale not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grading scale"})
+		return false
+	}
+	return true
+}
+
+func SetDefaultGradingScale(c *gin.Context) {
+	var assignment GradingScaleAssignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if !requireScaleExists(c, assignment.GradingScaleID) {
+		return
+	}
+	if err := store.Scales.SetDefault(assignment.GradingScaleID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving grading scale"})
+		return
+	}
+	recordAudit(c, "update", "grading_scale", "default", nil, assignment)
+	c.JSON(http.StatusOK, gin.H{"message": "Default grading scale updated successfully"})
+}
+
+func SetSubjectGradingScale(c *gin.Context) {
+	subjectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid subject ID"})
+		return
+	}
+	var assignment GradingScaleAssignment
+	if err := c.ShouldBindJSON(&assignment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if !requireScaleExists(c, assignment.GradingScaleID) {
+		return
+	}
+	err = store.Subjects.SetGradingScale(uint(subjectID), assignment.GradingScaleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Subject not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving grading scale"})
+		return
+	}
+	recordAudit(c, "update", "subject", uint(subjectID), nil, assignment)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject grading scale updated successfully"})
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 func RegisterUser(c *gin.Context) {
@@ -40,8 +40,7 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	var existingUser User
-	err := db.QueryRow("SELECT uid, email, password FROM users WHERE email = ?", user.Email).Scan(&existingUser.UID, &existingUser.Email, &existingUser.Password)
+	_, err := store.Users.ByEmail(user.Email)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{"message": "Email already taken"})
 		return
@@ -58,77 +57,137 @@ func RegisterUser(c *gin.Context) {
 	}
 	user.Password = hashedPassword
 
-	tx, err := db.Begin()
+	userID, err := store.Users.Create(user, person)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving user"})
 		return
 	}
-	defer tx.Rollback()
 
-	result, err := tx.Exec("INSERT INTO users (email, password, role) VALUES (?, ?, ?)", user.Email, user.Password, user.Role)
+	recordAudit(c, "create", "user", userID, nil, gin.H{
+		"email":      user.Email,
+		"role":       user.Role,
+		"first_name": person.FirstName,
+		"last_name":  person.LastName,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
+}
+
+func Login(c *gin.Context) {
+	var user User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	ip := c.ClientIP()
+	wait, err := checkLoginThrottle(ip, user.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking login attempts"})
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait)
 		return
 	}
 
-	userID, err := result.LastInsertId()
+	// Unknown e-mail and wrong password get the same message and the same bcrypt cost
+	storedUser, err := store.Users.ByEmail(user.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving user ID"})
+		checkDummyPassword(user.Password)
+	}
+	if err != nil || !CheckPasswordHash(user.Password, storedUser.Password) {
+		if err := recordLoginFailure(ip, user.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
 		return
 	}
 
-	_, err = tx.Exec("INSERT INTO persons (user_id, first_name, last_name, birth_date, address, phone) VALUES (?, ?, ?, ?, ?, ?)",
-		userID, person.FirstName, person.LastName, person.BirthDate, person.Address, person.Phone)
+	// The account counter is only cleared once the login is complete, so that re-entering the
+	// password cannot reset it between second-factor guesses
+	enabled, err := totpEnabled(storedUser.UID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving user details"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking two-factor authentication"})
+		return
+	}
+	if enabled {
+		challenge, err := signChallengeToken(storedUser.UID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challenge})
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
+	issueTokens(c, storedUser.UID, storedUser.Email, storedUser.Role)
 }
 
-func Login(c *gin.Context) {
-	var user User
-	if err := c.ShouldBindJSON(&user); err != nil {
+func RefreshToken(c *gin.Context) {
+	var request RefreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
 		return
 	}
 
-	var storedUser User
-	var role string
-	err := db.QueryRow("SELECT uid, email, password, role FROM users WHERE email = ?", user.Email).Scan(&storedUser.UID, &storedUser.Email, &storedUser.Password, &role)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid email credentials"})
+	var session Session
+	var user User
+	err := db.QueryRow("SELECT sessions.id, sessions.expires_at, users.uid, users.email, users.role FROM sessions INNER JOIN users ON users.uid = sessions.user_id WHERE sessions.refresh_token_hash = ? AND sessions.revoked_at IS NULL",
+		hashToken(request.RefreshToken)).Scan(&session.ID, &session.ExpiresAt, &user.UID, &user.Email, &user.Role)
+	if err != nil || session.ExpiresAt <= formatTimestamp(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token"})
 		return
 	}
 
-	if !CheckPasswordHash(user.Password, storedUser.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid password credentials"})
+	refreshToken, err := generateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
 		return
 	}
-
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
-	claims := &Claims{
-		Email: storedUser.Email,
-		Role:  role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	result, err := db.Exec("UPDATE sessions SET refresh_token_hash = ?, expires_at = ? WHERE id = ? AND refresh_token_hash = ?",
+		hashToken(refreshToken), formatTimestamp(time.Now().Add(refreshTokenTTL)), session.ID, hashToken(request.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating session"})
+		return
+	}
+	// A concurrent refresh already rotated this token
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token"})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	accessToken, err := signAccessToken(user.UID, user.Email, user.Role, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
 		return
 	}
+	recordAuditAs(c, user.UID, user.Role, "refresh", "session", session.ID, nil, nil)
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+func Logout(c *gin.Context) {
+	sessionID := c.GetUint("session_id")
+
+	if err := revokeSession(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error revoking session"})
+		return
+	}
+
+	recordAudit(c, "logout", "session", sessionID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 func ChangePassword(c *gin.Context) {
@@ -138,17 +197,21 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	email, _ := c.Get("email")
-
-	var user User
-	err := db.QueryRow("SELECT uid, email, password FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email, &user.Password)
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
 
+	if !throttleAttempt(c, user.Email) {
+		return
+	}
 	if !CheckPasswordHash(input.OldPassword, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Incorrect old password"})
+		failAttempt(c, user.Email, "Incorrect old password")
+		return
+	}
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
 		return
 	}
 
@@ -158,41 +221,39 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	_, err = db.Exec("UPDATE users SET password = ? WHERE email = ?", hashedPassword, user.Email)
-	if err != nil {
+	if err := store.Users.SetPassword(user.UID, hashedPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating password"})
 		return
 	}
 
+	// Sign out every other device that may still hold a token for the old password
+	if err := revokeUserSessions(user.UID, c.GetUint("session_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error revoking sessions"})
+		return
+	}
+
+	recordAudit(c, "change_password", "user", user.UID, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
 func DeleteAccount(c *gin.Context) {
-	email, _ := c.Get("email")
-
-	tx, err := db.Begin()
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
-	defer tx.Rollback()
 
-	_, err = tx.Exec("DELETE FROM persons WHERE user_id IN (SELECT uid FROM users WHERE email = ?)", email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting user details"})
+	if err := store.Users.Delete(user.UID); err == errUserHasRecords {
+		c.JSON(http.StatusConflict, gin.H{"message": "Account has school records and cannot be deleted"})
 		return
-	}
-
-	_, err = tx.Exec("DELETE FROM users WHERE email = ?", email)
-	if err != nil {
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting user"})
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
-		return
-	}
+	// The account is gone by now, so the actor is recorded explicitly
+	recordAuditAs(c, user.UID, user.Role, "delete", "user", user.UID, gin.H{"email": user.Email, "role": user.Role}, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
@@ -208,18 +269,22 @@ func AddTimetableEntry(c *gin.Context) {
 		return
 	}
 
-	if entry.SubjectID == 0 || entry.StartTime == "" || entry.EndTime == "" || entry.TeacherID == 0 || entry.ClassName == "" || entry.Day == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject ID, start time, end time, teacher ID, class name, and day are required"})
+	entry.ID = 0
+	if !validateTimetableEntry(c, &entry) {
+		return
+	}
+	if !checkTimetableConflicts(c, entry) {
 		return
 	}
 
-	_, err := db.Exec("INSERT INTO timetable (day, subject_id, time_start, time_end, room, teacher_id, class_name) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		entry.Day, entry.SubjectID, entry.StartTime, entry.EndTime, entry.Room, entry.TeacherID, entry.ClassName)
+	id, err := store.Timetable.Create(entry)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving timetable entry"})
 		return
 	}
 
+	entry.ID = id
+	recordAudit(c, "create", "timetable", entry.ID, nil, entry)
 	c.JSON(http.StatusCreated, gin.H{"message": "Timetable entry created successfully"})
 }
 
@@ -234,96 +299,143 @@ func AddGrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "User ID, subject ID, grade, grade type, and date are required"})
 		return
 	}
+	if grade.Weight == 0 {
+		grade.Weight = 1
+	}
+	if !validGradeWeight(grade.Weight) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Weight must be between 1 and 10"})
+		return
+	}
+
+	if !requireTeacherSubjectStudent(c, grade.SubjectID, grade.UserID) {
+		return
+	}
+	if !requireOpenYear(c, grade.Date) {
+		return
+	}
+	if !requireGradeOnScale(c, grade.SubjectID, grade.GradeType, &grade.Grade) {
+		return
+	}
 
-	_, err := db.Exec("INSERT INTO grades (user_id, subject_id, grade, grade_type, date) VALUES (?, ?, ?, ?, ?)",
-		grade.UserID, grade.SubjectID, grade.Grade, grade.GradeType, grade.Date)
+	// Teachers always sign their own grades; admins may enter one on a teacher's behalf
+	if c.GetString("role") == "teacher" || grade.TeacherID == 0 {
+		uid, err := currentUserID(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		grade.TeacherID = uid
+	}
+
+	id, err := store.Grades.Create(grade)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	grade.ID = id
+	recordAudit(c, "create", "grade", grade.ID, nil, grade)
 	c.JSON(http.StatusCreated, gin.H{"message": "Grade created successfully"})
 }
 
 func GetTimetable(c *gin.Context) {
-	email, _ := c.Get("email")
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	// A week is expanded from the entries of every term, each lesson keeping to its own term
+	week, ok := weekParam(c)
+	if !ok {
+		return
+	}
+	if week != nil {
+		term = nil
+	}
 	role, _ := c.Get("role")
-	var user User
-	var classmember ClassMember
-	err := db.QueryRow("SELECT uid, email, class_name FROM users INNER JOIN class_members ON users.uid = class_members.user_id WHERE email = ?", email).Scan(&user.UID, &user.Email, &classmember.ClassName)
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
-	var rows *sql.Rows
-	if role == "student" {
-		rows, err := db.Query("SELECT id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name FROM timetable WHERE class_name = ?", classmember.ClassName)
+	var timetable []TimetableEntry
+	var teacherUID uint
+	switch role {
+	case "teacher":
+		teacherUID = user.UID
+		timetable, err = store.Timetable.ListByTeacher(user.UID, term)
+	case "student":
+		var className string
+		className, err = store.Classes.ClassOf(user.UID, today())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+			c.JSON(http.StatusNotFound, gin.H{"message": "Student is not assigned to a class"})
 			return
 		}
-		defer rows.Close()
-	} else if role == "teacher" {
-		rows, err := db.Query("SELECT id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name FROM timetable WHERE teacher_id = ?", user.UID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+		timetable, err = store.Timetable.ListByClass(className, term)
+	case "admin":
+		// Admins have no class of their own and pick one with ?class_name= or ?teacher_id=
+		if className := c.Query("class_name"); className != "" {
+			timetable, err = store.Timetable.ListByClass(className, term)
+		} else if teacherID := c.Query("teacher_id"); teacherID != "" {
+			id, parseErr := strconv.ParseUint(teacherID, 10, 32)
+			if parseErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid teacher_id"})
+				return
+			}
+			teacherUID = uint(id)
+			timetable, err = store.Timetable.ListByTeacher(uint(id), term)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "class_name or teacher_id is required"})
 			return
 		}
-		defer rows.Close()
+	default:
+		// Parents read their children's timetable under /api/parent/children
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return
 	}
-	var timetable []TimetableEntry
-	for rows.Next() {
-		var entry TimetableEntry
-		if err := rows.Scan(&entry.ID, &entry.Day, &entry.SubjectID, &entry.ClassPeriod, &entry.StartTime, &entry.EndTime, &entry.Room, &entry.TeacherID, &entry.ClassName); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning timetable entry"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+		return
+	}
+
+	if week != nil {
+		lessons, err := weekTimetable(timetable, *week, teacherUID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
 			return
 		}
-		timetable = append(timetable, entry)
+		c.JSON(http.StatusOK, lessons)
+		return
 	}
-
 	c.JSON(http.StatusOK, timetable)
 }
 
 func GetGrades(c *gin.Context) {
-	email, _ := c.Get("email")
-
-	var user User
-	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
 
-	var grades []Grade
-	rows, err := db.Query("SELECT id, user_id, subject_id, grade, grade_type, date FROM grades WHERE user_id = ?", user.UID)
+	grades, err := store.Grades.ListByStudent(user.UID, term)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grades"})
 		return
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var grade Grade
-		if err := rows.Scan(&grade.ID, &grade.UserID, &grade.SubjectID, &grade.Grade, &grade.GradeType, &grade.Date); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning grade"})
-			return
-		}
-		grades = append(grades, grade)
-	}
 
 	c.JSON(http.StatusOK, grades)
 }
 
 func GetUserInfo(c *gin.Context) {
-	email, _ := c.Get("email")
-	var user User
-	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
-	var person Person
-	err = db.QueryRow("SELECT first_name, last_name, birth_date, address, phone FROM persons WHERE user_id = ?", user.UID).Scan(&person.FirstName, &person.LastName, &person.BirthDate, &person.Address, &person.Phone)
+	person, err := store.Users.Person(user.UID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User details not found"})
 		return
@@ -340,37 +452,23 @@ func GetUserInfo(c *gin.Context) {
 
 func GetSubjects(c *gin.Context) {
 
-	email, _ := c.Get("email")
-	var user User
-	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
 
-	var classmember ClassMember
-	err = db.QueryRow("SELECT class_name FROM class_members WHERE user_id = ?", user.UID).Scan(&classmember.ClassName)
+	className, err := store.Classes.ClassOf(user.UID, today())
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
 
-	var subjects []Subject
-	rows, err := db.Query("SELECT id, name, class_name, teacher_id FROM subjects WHERE class_name = ?", classmember.ClassName)
+	subjects, err := store.Subjects.ListByClass(className)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subjects"})
 		return
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var subject Subject
-		if err := rows.Scan(&subject.ID, &subject.Name, &subject.ClassName, &subject.TeacherID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning subject"})
-			return
-		}
-		subjects = append(subjects, subject)
-	}
 
 	c.JSON(http.StatusOK, subjects)
 }
@@ -381,16 +479,69 @@ func AddAttendance(c *gin.Context) {
 		return
 	}
 
-	if attendance.UserID == 0 || attendance.SubjectID == 0 || attendance.Status == "" || attendance.Date == "" {
+	if attendance.UserID == 0 || (attendance.SubjectID == 0 && attendance.TimetableID == nil) || attendance.Status == "" || attendance.Date == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "User ID, subject ID, subjectid, status, and date are required"})
 		return
 	}
-	_, err := db.Exec("INSERT INTO attendance (user_id, subject_id, status, date) VALUES (?, ?, ?, ?)", attendance.UserID, attendance.SubjectID, attendance.Status, attendance.Date)
+	// Lesson times are read from the timetable entry
+	attendance.StartTime, attendance.EndTime = nil, nil
+	if attendance.TimetableID != nil || attendance.ClassPeriod != nil {
+		var classPeriod uint
+		if attendance.ClassPeriod != nil {
+			classPeriod = *attendance.ClassPeriod
+		}
+		entry, ok := lessonOf(c, attendance.TimetableID, attendance.SubjectID, classPeriod, attendance.Date)
+		if !ok {
+			return
+		}
+		attendance.SubjectID = entry.SubjectID
+		attendance.ClassPeriod = &entry.ClassPeriod
+		attendance.TimetableID = nil
+		if entry.ID != 0 {
+			attendance.TimetableID = &entry.ID
+		}
+	}
+	if !requireTeacherSubjectStudent(c, attendance.SubjectID, attendance.UserID) {
+		return
+	}
+	if !requireOpenYear(c, attendance.Date) {
+		return
+	}
+	// A student has one record per lesson; retaking attendance goes through PUT .../attendance/lesson
+	if attendance.ClassPeriod != nil {
+		existing, err := store.Attendance.ListByLesson(attendance.SubjectID, attendance.Date, *attendance.ClassPeriod)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+			return
+		}
+		for _, att := range existing {
+			if att.UserID == attendance.UserID {
+				c.JSON(http.StatusConflict, gin.H{"message": "Attendance for this lesson has already been recorded"})
+				return
+			}
+		}
+	}
+	// An absence on a day already covered by an approved excuse is recorded as excused
+	attendance.ExcuseID = nil
+	if attendance.Status == "absent" {
+		excuse, err := store.Excuses.Covering(attendance.UserID, attendance.Date)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving excuse"})
+			return
+		}
+		if err == nil {
+			attendance.Status = "excused"
+			attendance.ExcuseID = &excuse.ID
+		}
+	}
+	id, err := store.Attendance.Create(attendance)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	attendance.ID = id
+	recordAudit(c, "create", "attendance", attendance.ID, nil, attendance)
 	c.JSON(http.StatusCreated, gin.H{"message": "Attendance added successfully"})
 }
 
@@ -398,134 +549,129 @@ func GetLuckyNumber(c *gin.Context){
 	c.JSON(http.StatusOK, gin.H{"lucky_number": getRandomNumber()})
 }
 func GetExams(c *gin.Context){
-	email, _ := c.Get("email")
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
 	role, _ := c.Get("role")
-	var user User
-	var classmember ClassMember
-	err := db.QueryRow("SELECT uid, email, class_name FROM users INNER JOIN class_members ON user.uid = class_members.user_id WHERE email = ?", email).Scan(&user.UID, &user.Email, &classmember.ClassName)
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
-	var rows *sql.Rows
-	if role == "student" {
-		rows, err := db.Query("SELECT id, class_name, teacher_id, subject_id, date, type, FROM exams WHERE class_name = ?", user.UID)
+	var exams []Exam
+	switch role {
+	case "teacher":
+		exams, err = store.Exams.ListByTeacher(user.UID, term)
+	case "student":
+		var className string
+		className, err = store.Classes.ClassOf(user.UID, today())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving exams"})
+			c.JSON(http.StatusNotFound, gin.H{"message": "Student is not assigned to a class"})
 			return
 		}
-		defer rows.Close()
-	} else if role == "teacher" {
-		rows, err := db.Query("SELECT id, class_name, teacher_id, subject_id, date, type, FROM exams WHERE teacher_id = ?", user.UID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving exams"})
+		exams, err = store.Exams.ListByClass(className, term)
+	case "admin":
+		// Admins have no class of their own and pick one with ?class_name= or ?teacher_id=
+		if className := c.Query("class_name"); className != "" {
+			exams, err = store.Exams.ListByClass(className, term)
+		} else if teacherID := c.Query("teacher_id"); teacherID != "" {
+			id, parseErr := strconv.ParseUint(teacherID, 10, 32)
+			if parseErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid teacher_id"})
+				return
+			}
+			exams, err = store.Exams.ListByTeacher(uint(id), term)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "class_name or teacher_id is required"})
 			return
 		}
-		defer rows.Close()
+	default:
+		// Parents read their children's exams under /api/parent/children
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return
 	}
-	var exams []Exam
-	for rows.Next() {
-		var exam Exam
-		if err := rows.Scan(&exam.ID, &exam.ClassName, &exam.TeacherID, &exam.SubjectID, &exam.Date, &exam.Type); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning exam entry"})
-			return
-		}
-		exams = append(exams, exam)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving exams"})
+		return
 	}
 	c.JSON(http.StatusOK, exams)
 }
 func GetAttendance(c *gin.Context){
-	email, _ := c.Get("email")
-	var user User
-	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	user, err := store.Users.ByEmail(c.GetString("email"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
 		return
 	}
-	var attendance []Attendance
-	rows, err := db.Query("SELECT id, user_id, subject_id, status, date FROM attendance WHERE user_id = ?", user.UID)
+	attendance, err := store.Attendance.ListByStudent(user.UID, term)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var att Attendance
-		if err := rows.Scan(&att.ID, &att.UserID, &att.SubjectID, &att.Status, &att.Date); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning attendance"})
-			return
-		}
-		attendance = append(attendance, att)
-	}
 	c.JSON(http.StatusOK, attendance)
 }
 func GetClassMembers(c *gin.Context){
+	yearID, ok := yearParam(c)
+	if !ok {
+		return
+	}
 	var class Class
 	if err := c.ShouldBindJSON(&class); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
 		return
 	}
-	var classmembers []ClassMember
-	rows, err := db.Query("SELECT id, user_id, class_name FROM class_members WHERE class_name = ?", class.Name)
+	if !requireTeacherClass(c, class.Name) {
+		return
+	}
+	classmembers, err := store.Classes.Members(class.Name, yearID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var classmember ClassMember
-		if err := rows.Scan(&classmember.ID, &classmember.UserID, &classmember.ClassName); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning class member"})
-			return
-		}
-		classmembers = append(classmembers, classmember)
-	}
 	c.JSON(http.StatusOK, classmembers)
 }
 func GetStudentGrades(c *gin.Context){
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
 		return
 	}
-	var grades []Grade
-	rows, err := db.Query("SELECT id, user_id, subject_id, grade, grade_type, date FROM grades WHERE user_id = ?", user.UID)
+	if !requireTeacherStudent(c, user.UID) {
+		return
+	}
+	grades, err := store.Grades.ListByStudent(user.UID, term)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grades"})
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var grade Grade
-		if err := rows.Scan(&grade.ID, &grade.UserID, &grade.SubjectID, &grade.Grade, &grade.GradeType, &grade.Date); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning grade"})
-			return
-		}
-		grades = append(grades, grade)
-	}
 	c.JSON(http.StatusOK, grades)
 }
 func GetStudentAttendance(c *gin.Context){
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
 		return
 	}
-	var attendance []Attendance
-	rows, err := db.Query("SELECT id, user_id, subject_id, status, date FROM attendance WHERE user_id = ?", user.UID)
+	if !requireTeacherStudent(c, user.UID) {
+		return
+	}
+	attendance, err := store.Attendance.ListByStudent(user.UID, term)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var att Attendance
-		if err := rows.Scan(&att.ID, &att.UserID, &att.SubjectID, &att.Status, &att.Date); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning attendance"})
-			return
-		}
-		attendance = append(attendance, att)
-	}
 	c.JSON(http.StatusOK, attendance)
 }
 func GetStudentInfo(c *gin.Context){
@@ -534,8 +680,10 @@ func GetStudentInfo(c *gin.Context){
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
 		return
 	}
-	var person Person
-	err := db.QueryRow("SELECT first_name, last_name, birth_date, address, phone FROM persons WHERE user_id = ?", user.UID).Scan(&person.FirstName, &person.LastName, &person.BirthDate, &person.Address, &person.Phone)
+	if !requireTeacherStudent(c, user.UID) {
+		return
+	}
+	person, err := store.Users.Person(user.UID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "User details not found"})
 		return
@@ -558,11 +706,35 @@ func AddExam(c *gin.Context){
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Class name, teacher ID, subject ID, date, and type are required"})
 		return
 	}
-	_, err := db.Exec("INSERT INTO exams (class_name, teacher_id, subject_id, date, type) VALUES (?, ?, ?, ?, ?)", exam.ClassName, exam.TeacherID, exam.SubjectID, exam.Date, exam.Type)
+	if !requireTeacherSubject(c, exam.SubjectID) || !requireTeacherClass(c, exam.ClassName) {
+		return
+	}
+	subject, err := store.Subjects.ByID(exam.SubjectID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Subject not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+		return
+	}
+	if subject.ClassName != exam.ClassName {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject is not taught in class " + exam.ClassName})
+		return
+	}
+	if c.GetString("role") == "teacher" {
+		uid, err := currentUserID(c)
+		if err != nil || uid != exam.TeacherID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+			return
+		}
+	}
+	id, err := store.Exams.Create(exam)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	exam.ID = id
+	recordAudit(c, "create", "exam", exam.ID, nil, exam)
 	c.JSON(http.StatusCreated, gin.H{"message": "Exam created successfully"})
 }
 func AddClass(c *gin.Context){
@@ -575,11 +747,16 @@ func AddClass(c *gin.Context){
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required"})
 		return
 	}
-	_, err := db.Exec("INSERT INTO classes (name) VALUES (?)", class.Name)
+	if class.HomeroomTeacherID != nil && !requireTeacherAccount(c, *class.HomeroomTeacherID) {
+		return
+	}
+	id, err := store.Classes.Create(class)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	class.ID = id
+	recordAudit(c, "create", "class", class.ID, nil, class)
 	c.JSON(http.StatusCreated, gin.H{"message": "Class created successfully"})
 }
 func AddSubject(c *gin.Context){
@@ -592,11 +769,16 @@ func AddSubject(c *gin.Context){
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject name, class name, and teacher ID are required"})
 		return
 	}
-	_, err := db.Exec("INSERT INTO subjects (name, class_name, teacher_id) VALUES (?, ?, ?)", subject.Name, subject.ClassName, subject.TeacherID)
+	if !requireScaleExists(c, subject.GradingScaleID) {
+		return
+	}
+	id, err := store.Subjects.Create(subject)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	subject.ID = id
+	recordAudit(c, "create", "subject", subject.ID, nil, subject)
 	c.JSON(http.StatusCreated, gin.H{"message": "Subject created successfully"})
 }
 func AddClassMember(c *gin.Context){
@@ -609,10 +791,26 @@ func AddClassMember(c *gin.Context){
 		c.JSON(http.StatusBadRequest, gin.H{"message": "User ID and class name are required"})
 		return
 	}
-	_, err := db.Exec("INSERT INTO class_members (user_id, class_name) VALUES (?, ?)", classmember.UserID, classmember.ClassName)
+	if !resolveYearID(c, &classmember.AcademicYearID) {
+		return
+	}
+	if classmember.AcademicYearID != nil {
+		year, err := store.Terms.Year(*classmember.AcademicYearID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic year"})
+			return
+		}
+		if year.ArchivedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "Academic year " + year.Name + " is archived"})
+			return
+		}
+	}
+	id, err := store.Classes.AddMember(classmember)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	classmember.ID = id
+	recordAudit(c, "create", "class_member", classmember.ID, nil, classmember)
 	c.JSON(http.StatusCreated, gin.H{"message": "Class member added successfully"})
 }
\ No newline at end of file
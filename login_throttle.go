@@ -0,0 +1,287 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	loginMaxAccountFailures = 5                // Failures before an account is locked
+	loginMaxIPFailures      = 20               // Failures before a client IP is locked
+	loginLockoutDuration    = 15 * time.Minute // How long a lockout lasts
+	loginFailureWindow      = 15 * time.Minute // Failures older than this are forgotten
+	loginDelayAfter         = 3                // Failures before progressive delays start
+	loginMaxDelay           = 30 * time.Second // Upper bound of the progressive delay
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// loginThrottle is the failure counter for a single client IP or account
+type loginThrottle struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// throttleSubject normalises the account key so that case variations share one counter
+func throttleSubject(scope, subject string) string {
+	if scope == "account" || scope == "reset_account" {
+		return strings.ToLower(strings.TrimSpace(subject))
+	}
+	return subject
+}
+
+func getLoginThrottle(scope, subject string) (loginThrottle, error) {
+	var t loginThrottle
+	var lastFailureAt string
+	var lockedUntil sql.NullString
+	err := db.QueryRow("SELECT failures, last_failure_at, locked_until FROM login_throttle WHERE scope = ? AND subject = ?", scope, throttleSubject(scope, subject)).
+		Scan(&t.failures, &lastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return t, nil
+	}
+	if err != nil {
+		return t, err
+	}
+	t.lastFailureAt, _ = time.Parse(time.RFC3339, lastFailureAt)
+	if lockedUntil.Valid {
+		t.lockedUntil, _ = time.Parse(time.RFC3339, lockedUntil.String)
+	}
+	// Counters decay once the client has been quiet for a full window
+	if time.Since(t.lastFailureAt) > loginFailureWindow && time.Now().After(t.lockedUntil) {
+		t.failures = 0
+	}
+	return t, nil
+}
+
+// retryAfter returns how long the subject must wait before the next login attempt
+func (t loginThrottle) retryAfter(now time.Time) time.Duration {
+	if now.Before(t.lockedUntil) {
+		return t.lockedUntil.Sub(now)
+	}
+	if t.failures < loginDelayAfter {
+		return 0
+	}
+	delay := time.Second << uint(t.failures-loginDelayAfter)
+	if delay > loginMaxDelay || delay <= 0 {
+		delay = loginMaxDelay
+	}
+	if wait := t.lastFailureAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// checkLoginThrottle returns how long the caller must wait before trying to log in again, 0 if allowed
+func checkLoginThrottle(ip, email string) (time.Duration, error) {
+	now := time.Now()
+	ipThrottle, err := getLoginThrottle("ip", ip)
+	if err != nil {
+		return 0, err
+	}
+	accountThrottle, err := getLoginThrottle("account", email)
+	if err != nil {
+		return 0, err
+	}
+	wait := ipThrottle.retryAfter(now)
+	if w := accountThrottle.retryAfter(now); w > wait {
+		wait = w
+	}
+	return wait, nil
+}
+
+// incrementThrottle counts one more failure, or reset request, against a subject and returns the new count.
+// The counter starts over once it has decayed as in getLoginThrottle. The increment is a single statement,
+// so that parallel attempts cannot overwrite each other's counts.
+func incrementThrottle(scope, subject string, now time.Time) (int, error) {
+	var failures int
+	err := db.QueryRow(`INSERT INTO login_throttle (scope, subject, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT(scope, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttle.last_failure_at < ? AND (login_throttle.locked_until IS NULL OR login_throttle.locked_until <= ?)
+				THEN 1 ELSE login_throttle.failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures`,
+		scope, throttleSubject(scope, subject), formatTimestamp(now), formatTimestamp(now.Add(-loginFailureWindow)), formatTimestamp(now)).
+		Scan(&failures)
+	return failures, err
+}
+
+// lockThrottle locks a subject until the given time unless it is locked already, and reports whether it did.
+// Only one of several parallel attempts reaching the limit therefore starts the lockout.
+func lockThrottle(scope, subject string, now, until time.Time) (bool, error) {
+	result, err := db.Exec("UPDATE login_throttle SET locked_until = ? WHERE scope = ? AND subject = ? AND (locked_until IS NULL OR locked_until <= ?)",
+		formatTimestamp(until), scope, throttleSubject(scope, subject), formatTimestamp(now))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// bumpLoginFailure increments one counter and locks the subject once it reaches max failures
+func bumpLoginFailure(scope, subject, ip string, max int) error {
+	now := time.Now()
+	failures, err := incrementThrottle(scope, subject, now)
+	if err != nil || failures < max {
+		return err
+	}
+	until := now.Add(loginLockoutDuration)
+	locked, err := lockThrottle(scope, subject, now, until)
+	if err != nil || !locked {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO lockout_events (scope, subject, ip, failures, locked_at, locked_until) VALUES (?, ?, ?, ?, ?, ?)",
+		scope, throttleSubject(scope, subject), ip, failures, formatTimestamp(now), formatTimestamp(until))
+	return err
+}
+
+// recordLoginFailure counts a failed login against both the client IP and the account
+func recordLoginFailure(ip, email string) error {
+	if err := bumpLoginFailure("ip", ip, ip, loginMaxIPFailures); err != nil {
+		return err
+	}
+	return bumpLoginFailure("account", email, ip, loginMaxAccountFailures)
+}
+
+// clearLoginFailures resets the account counter after a successful login
+func clearLoginFailures(email string) error {
+	_, err := db.Exec("DELETE FROM login_throttle WHERE scope = 'account' AND subject = ?", throttleSubject("account", email))
+	return err
+}
+
+// throttleAttempt rejects the request with 429 while the client IP or account is locked out or delayed.
+// It is used by every endpoint that verifies a password or second factor and returns false once it has responded.
+func throttleAttempt(c *gin.Context, email string) bool {
+	wait, err := checkLoginThrottle(c.ClientIP(), email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking login attempts"})
+		return false
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait)
+		return false
+	}
+	return true
+}
+
+// failAttempt counts a wrong password or code towards the lockout and responds 401 with the given message
+func failAttempt(c *gin.Context, email, message string) {
+	if err := recordLoginFailure(c.ClientIP(), email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"message": message})
+}
+
+// checkDummyPassword spends the same bcrypt time as a real check so that unknown accounts are not revealed by latency
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("mercury-dummy-password")
+	})
+	CheckPasswordHash(password, dummyHash)
+}
+
+// tooManyAttempts responds with 429 and a Retry-After header
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"message": "Too many failed login attempts, try again later"})
+}
+
+func UnlockLogin(c *gin.Context) {
+	var request UnlockRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if request.Email == "" && request.IP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Email or IP is required"})
+		return
+	}
+
+	email, _ := c.Get("email")
+	var admin User
+	err := db.QueryRow("SELECT uid FROM users WHERE email = ?", email).Scan(&admin.UID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	now := formatTimestamp(time.Now())
+	for scope, subject := range map[string]string{"account": request.Email, "ip": request.IP} {
+		if subject == "" {
+			continue
+		}
+		subject = throttleSubject(scope, subject)
+		if _, err := tx.Exec("DELETE FROM login_throttle WHERE scope = ? AND subject = ?", scope, subject); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error unlocking login"})
+			return
+		}
+		_, err := tx.Exec("UPDATE lockout_events SET unlocked_at = ?, unlocked_by = ? WHERE scope = ? AND subject = ? AND unlocked_at IS NULL AND locked_until > ?",
+			now, admin.UID, scope, subject, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error unlocking login"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
+		return
+	}
+
+	target := request.Email
+	if target == "" {
+		target = request.IP
+	}
+	recordAuditAs(c, admin.UID, c.GetString("role"), "unlock", "login_throttle", target, nil, request)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked successfully"})
+}
+
+func GetLockoutEvents(c *gin.Context) {
+	query := "SELECT id, scope, subject, ip, failures, locked_at, locked_until, unlocked_at, unlocked_by FROM lockout_events"
+	var args []interface{}
+	if c.Query("active") == "true" {
+		query += " WHERE unlocked_at IS NULL AND locked_until > ?"
+		args = append(args, formatTimestamp(time.Now()))
+	}
+	query += " ORDER BY locked_at DESC LIMIT 500"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving lockout events"})
+		return
+	}
+	defer rows.Close()
+
+	var events []LockoutEvent
+	for rows.Next() {
+		var event LockoutEvent
+		var ip, unlockedAt sql.NullString
+		var unlockedBy sql.NullInt64
+		if err := rows.Scan(&event.ID, &event.Scope, &event.Subject, &ip, &event.Failures, &event.LockedAt, &event.LockedUntil, &unlockedAt, &unlockedBy); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning lockout event"})
+			return
+		}
+		event.IP = ip.String
+		event.UnlockedAt = unlockedAt.String
+		event.UnlockedBy = uint(unlockedBy.Int64)
+		events = append(events, event)
+	}
+
+	c.JSON(http.StatusOK, events)
+}
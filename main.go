@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"log"
 	"net/http"
 	"os"
@@ -13,13 +12,21 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	_ "modernc.org/sqlite"
 )
 
 var jwtKey []byte
-var db *sql.DB
+var db *DB
+var store *Store
 
-func init() {
+// trustedProxies are the addresses or CIDR ranges of the reverse proxies whose X-Forwarded-For header gives the client IP.
+// With none, the client IP used by the login throttle and the audit log is always the address of the connection.
+var trustedProxies []string
+
+// seedAdminEmail and seedAdminPassword are the credentials of the administrator created on an empty database
+var seedAdminEmail, seedAdminPassword string
+
+// loadConfig reads the server configuration and secrets from the environment
+func loadConfig() {
 	var err error
 
 	jwtKeyStr, exists := os.LookupEnv("JWT_KEY")
@@ -28,63 +35,110 @@ func init() {
 	}
 	jwtKey = []byte(jwtKeyStr)
 
-	adminEmail, exists := os.LookupEnv("ADMIN_EMAIL")
+	accessTokenTTL, err = durationFromEnv("ACCESS_TOKEN_TTL", accessTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	refreshTokenTTL, err = durationFromEnv("REFRESH_TOKEN_TTL", refreshTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwordResetTTL, err = durationFromEnv("PASSWORD_RESET_TTL", passwordResetTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwordResetURL = os.Getenv("PASSWORD_RESET_URL")
+	passwordResetMaxPerAccount, err = intFromEnv("PASSWORD_RESET_MAX_REQUESTS", passwordResetMaxPerAccount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwordResetMaxPerIP, err = intFromEnv("PASSWORD_RESET_MAX_IP_REQUESTS", passwordResetMaxPerIP)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	loginMaxAccountFailures, err = intFromEnv("LOGIN_MAX_FAILURES", loginMaxAccountFailures)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loginMaxIPFailures, err = intFromEnv("LOGIN_MAX_IP_FAILURES", loginMaxIPFailures)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loginLockoutDuration, err = durationFromEnv("LOGIN_LOCKOUT_DURATION", loginLockoutDuration)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+
+	gradeHistoryForStudents, err = boolFromEnv("GRADE_HISTORY_FOR_STUDENTS", gradeHistoryForStudents)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadReportTemplate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadAttendanceRules(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadCalendarConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	if issuer, exists := os.LookupEnv("TOTP_ISSUER"); exists {
+		totpIssuer = issuer
+	}
+
+	mailer, err = newMailerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	seedAdminEmail, exists = os.LookupEnv("ADMIN_EMAIL")
 	if !exists {
 		log.Fatal("ADMIN_EMAIL environment variable is not set")
 	}
-	adminPassword, exists := os.LookupEnv("ADMIN_PASSWORD")
+	seedAdminPassword, exists = os.LookupEnv("ADMIN_PASSWORD")
 	if !exists {
 		log.Fatal("ADMIN_PASSWORD environment variable is not set")
 	}
-	dbPath, exists := os.LookupEnv("DB_PATH")
-	if !exists {
-		dbPath = "./database.db"
-	}
+}
 
-	db, err = sql.Open("sqlite", dbPath)
+// openDatabase opens the database configured by DB_DRIVER, DB_PATH and DATABASE_URL. It needs no
+// other configuration, so that the migrate subcommand can run without the server's secrets.
+func openDatabase() {
+	var err error
+	db, err = openDB()
 	if err != nil {
 		log.Fatal(err)
 	}
+	store = newStore(db)
+}
 
-	if !TableExists("users") {
-		schema, err := os.ReadFile("schema.sql")
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		_, err = db.Exec("PRAGMA foreign_keys = ON")
-		if err != nil {
-			log.Fatal(err)
-		}
+// seedAdmin creates the initial administrator account when the database has none
+func seedAdmin() error {
+	count, err := store.Users.CountByRole("admin")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
 
-		_, err = db.Exec(string(schema))
-		if err != nil {
-			log.Fatal(err)
-		}
-		hashedPassword, err := HashPassword(adminPassword)
-		if err != nil {
-			log.Fatal(err)
-		}
-		tx, err := db.Begin()
-		if err != nil {
-			log.Fatal(err)
-		}
-		result, err := tx.Exec("INSERT INTO users (email, password, role) VALUES (?, ?, 'admin')", adminEmail, hashedPassword)
-		if err != nil {
-			log.Fatal(err)
-		}
-		adminUserID, err := result.LastInsertId()
-		if err != nil {
-			log.Fatal(err)
-		}
-		_, err = tx.Exec("INSERT INTO persons (user_id, first_name, last_name) VALUES (?, ?, ?)", adminUserID, "Admin", "User")
-		if err != nil {
-			log.Fatal(err)
-		}
-		if err := tx.Commit(); err != nil {
-			log.Fatal(err)
-		}
+	hashedPassword, err := HashPassword(seedAdminPassword)
+	if err != nil {
+		return err
 	}
+	_, err = store.Users.Create(User{Email: seedAdminEmail, Password: hashedPassword, Role: "admin"}, Person{FirstName: "Admin", LastName: "User"})
+	return err
 }
 
 func LoggerMiddleware() gin.HandlerFunc {
@@ -103,7 +157,27 @@ func LoggerMiddleware() gin.HandlerFunc {
 
 // main sets up and runs the web server
 func main() {
+	openDatabase()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	loadConfig()
+
+	if err := migrateUp(0); err != nil {
+		log.Fatal(err)
+	}
+	if err := seedAdmin(); err != nil {
+		log.Fatal(err)
+	}
+
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	r.Use(LoggerMiddleware())
 
@@ -118,54 +192,166 @@ func main() {
 
 	// Public routes
 	r.POST("/api/login", Login)
+	r.POST("/api/login/2fa", LoginTwoFactor)
+	r.POST("/api/refresh", RefreshToken)
+	r.POST("/api/password-reset/request", RequestPasswordReset)
+	r.POST("/api/password-reset/confirm", ConfirmPasswordReset)
 	r.GET("/api/ping", Ping)
 	r.GET("/api/lucky-number", GetLuckyNumber)
+	r.GET("/api/calendar/:file", GetCalendarFeed)
 
 	// Authenticated routes
 	auth := r.Group("/api").Use(TokenAuthMiddleware())
 	{
+		auth.POST("/logout", Logout)
+		auth.POST("/2fa/enroll", EnrollTwoFactor)
+		auth.POST("/2fa/confirm", ConfirmTwoFactor)
+		auth.POST("/2fa/disable", DisableTwoFactor)
+		auth.POST("/2fa/recovery-codes", RegenerateRecoveryCodes)
 		auth.PUT("/change-password", ChangePassword)
 		auth.DELETE("/delete-account", DeleteAccount)
 		auth.GET("/timetable", GetTimetable)
 		auth.GET("/user", GetUserInfo)
 		auth.GET("/exams", GetExams)
+		auth.GET("/grading-scales", GetGradingScales)
+		auth.GET("/academic-years", GetAcademicYears)
+		auth.GET("/holidays", GetHolidays)
+		auth.GET("/substitutions", GetSubstitutions)
+		auth.GET("/bell-schedules", GetBellSchedules)
+		auth.POST("/calendar-token", CreateCalendarToken)
+		auth.DELETE("/calendar-token", DeleteCalendarToken)
 	}
 
 	// Admin routes
 	admin := r.Group("/api/admin").Use(TokenAuthMiddleware(), AdminAuthMiddleware())
 	{
 		admin.POST("/register", RegisterUser)
+		admin.POST("/unlock", UnlockLogin)
+		admin.GET("/lockouts", GetLockoutEvents)
+		admin.GET("/audit", GetAuditLog)
+		admin.GET("/2fa-policy", GetMFAPolicy)
+		admin.PUT("/2fa-policy", SetMFAPolicy)
+		admin.POST("/2fa-reset", ResetTwoFactor)
 		admin.POST("/timetable", AddTimetableEntry)
+		admin.PUT("/timetable/:id", UpdateTimetableEntry)
+		admin.POST("/timetable-override", AddTimetableOverride)
+		admin.DELETE("/timetable-override/:id", DeleteTimetableOverride)
+		admin.POST("/bell-schedule", AddBellSchedule)
+		admin.PUT("/bell-schedule/default", SetDefaultBellSchedule)
+		admin.PUT("/bell-schedule/:id", UpdateBellSchedule)
+		admin.POST("/bell-schedule/:id/dates", AddBellScheduleDates)
+		admin.DELETE("/bell-schedule-dates/:id", DeleteBellScheduleDates)
+		admin.POST("/room", AddRoom)
+		admin.GET("/rooms", GetRooms)
+		admin.DELETE("/room/:id", DeleteRoom)
+		admin.PUT("/teaching-requirement", SetTeachingRequirement)
+		admin.GET("/teaching-requirements", GetTeachingRequirements)
+		admin.DELETE("/teaching-requirement/:id", DeleteTeachingRequirement)
+		admin.PUT("/teacher-availability", SetTeacherAvailability)
+		admin.GET("/teacher-availability", GetTeacherAvailability)
+		admin.DELETE("/teacher-availability/:id", DeleteTeacherAvailability)
+		admin.POST("/timetable-draft", GenerateTimetableDraft)
+		admin.GET("/timetable-drafts", GetTimetableDrafts)
+		admin.GET("/timetable-draft/:id", GetTimetableDraft)
+		admin.POST("/timetable-draft/:id/publish", PublishTimetableDraft)
+		admin.DELETE("/timetable-draft/:id", DeleteTimetableDraft)
+		admin.PUT("/timetable-draft-entry/:id", UpdateTimetableDraftEntry)
+		admin.DELETE("/timetable-draft-entry/:id", DeleteTimetableDraftEntry)
 		admin.POST("/class", AddClass)
+		admin.PUT("/class/:name/homeroom", SetClassHomeroom)
+		admin.POST("/academic-year", AddAcademicYear)
+		admin.POST("/term", AddTerm)
+		admin.PUT("/term/:id/proposal-deadline", SetTermProposalDeadline)
+		admin.POST("/holiday", AddHoliday)
+		admin.DELETE("/holiday/:id", DeleteHoliday)
+		admin.POST("/rollover", RolloverAcademicYear)
+		admin.GET("/subject-drafts", GetSubjectDrafts)
+		admin.PUT("/subject-draft/:id", SetSubjectDraftTeacher)
+		admin.DELETE("/subject-draft/:id", DeleteSubjectDraft)
+		admin.POST("/subject-drafts/apply", ApplySubjectDrafts)
 		admin.POST("/subject", AddSubject)
+		admin.PUT("/subject/:id/grading-scale", SetSubjectGradingScale)
+		admin.POST("/grading-scale", AddGradingScale)
+		admin.PUT("/grading-scale/default", SetDefaultGradingScale)
 		admin.POST("/class-member", AddClassMember)
+		admin.POST("/guardian", AddGuardian)
+		admin.DELETE("/guardian", RemoveGuardian)
+		admin.GET("/guardians", GetGuardians)
 
 		admin.POST("/grade", AddGrade)
+		admin.PUT("/grade/:id", UpdateGrade)
+		admin.DELETE("/grade/:id", DeleteGrade)
+		admin.GET("/grade/:id/history", GetGradeHistory)
+		admin.PUT("/term-grade", SetTermGrade)
+		admin.POST("/term-grade/:id/unlock", UnlockTermGrade)
+		admin.POST("/term-grades/approve", ApproveTermGrades)
+		admin.GET("/term-grades", GetClassTermGrades)
 		admin.POST("/attendance", AddAttendance)
+		admin.PUT("/attendance/lesson", TakeLessonAttendance)
+		admin.GET("/excuses", GetClassExcuses)
+		admin.POST("/excuse/:id/review", ReviewExcuse)
 		admin.POST("/exam", AddExam)
 		admin.GET("/class", GetClassMembers)
 		admin.GET("/student-grades", GetStudentGrades)
+		admin.GET("/student-averages", GetStudentAverages)
 		admin.GET("/student-attendance", GetStudentAttendance)
+		admin.GET("/attendance-stats/student/:student_id", GetStudentAttendanceStats)
+		admin.GET("/attendance-stats/class/:name", GetClassAttendanceStats)
+		admin.GET("/attendance-alerts", GetAttendanceAlerts)
 		admin.GET("/student-info", GetStudentInfo)
+		admin.GET("/report-card/:student_id", GetReportCard)
+		admin.GET("/report-cards", GetClassReportCards)
 	}
 
 	// Teacher routes
 	teacher := r.Group("/api/teacher").Use(TokenAuthMiddleware(), TeacherAuthMiddleware())
 	{
 		teacher.POST("/grade", AddGrade)
+		teacher.PUT("/grade/:id", UpdateGrade)
+		teacher.DELETE("/grade/:id", DeleteGrade)
+		teacher.PUT("/term-grade", SetTermGrade)
+		teacher.POST("/term-grades/approve", ApproveTermGrades)
+		teacher.GET("/term-grades", GetClassTermGrades)
 		teacher.POST("/attendance", AddAttendance)
+		teacher.PUT("/attendance/lesson", TakeLessonAttendance)
+		teacher.GET("/excuses", GetClassExcuses)
+		teacher.POST("/excuse/:id/review", ReviewExcuse)
 		teacher.POST("/exam", AddExam)
 		teacher.GET("/class", GetClassMembers)
 		teacher.GET("/student-grades", GetStudentGrades)
+		teacher.GET("/student-averages", GetStudentAverages)
 		teacher.GET("/student-attendance", GetStudentAttendance)
+		teacher.GET("/attendance-stats/student/:student_id", GetStudentAttendanceStats)
+		teacher.GET("/attendance-stats/class/:name", GetClassAttendanceStats)
+		teacher.GET("/attendance-alerts", GetAttendanceAlerts)
 		teacher.GET("student-info", GetStudentInfo)
 	}
 	// Student routes
 	student := r.Group("/api/student").Use(TokenAuthMiddleware(), StudentAuthMiddleware())
 	{
 		student.GET("/grades", GetGrades)
+		student.GET("/grades/:id/history", GetOwnGradeHistory)
+		student.GET("/averages", GetAverages)
+		student.GET("/term-grades", GetTermGrades)
 		student.GET("/subjects", GetSubjects)
 		student.GET("/attendance", GetAttendance)
+		student.GET("/attendance-stats", GetAttendanceStats)
+		student.POST("/excuse", SubmitExcuse)
+		student.GET("/excuses", GetExcuses)
+	}
+	// Parent routes
+	parent := r.Group("/api/parent").Use(TokenAuthMiddleware(), ParentAuthMiddleware())
+	{
+		parent.GET("/children", GetChildren)
+		parent.GET("/children/:student_id/grades", GetChildGrades)
+		parent.GET("/children/:student_id/averages", GetChildAverages)
+		parent.GET("/children/:student_id/term-grades", GetChildTermGrades)
+		parent.GET("/children/:student_id/attendance", GetChildAttendance)
+		parent.GET("/children/:student_id/attendance-stats", GetChildAttendanceStats)
+		parent.POST("/children/:student_id/excuse", SubmitChildExcuse)
+		parent.GET("/children/:student_id/excuses", GetChildExcuses)
+		parent.GET("/children/:student_id/exams", GetChildExams)
+		parent.GET("/children/:student_id/timetable", GetChildTimetable)
 	}
 
 	port, exists := os.LookupEnv("PORT")
@@ -3,6 +3,7 @@ package main
 import (
     "fmt"
     "net/http"
+    "strings"
 
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v4"
@@ -37,6 +38,20 @@ func ValidateToken(c *gin.Context) (string, string, error) {
         return "", "", fmt.Errorf("invalid token claims")
     }
 
+    // Reject tokens whose session was revoked by logout, password change or account deletion
+    active, err := sessionActive(claims.SessionID)
+    if err != nil || !active {
+        c.JSON(http.StatusUnauthorized, gin.H{"message": "Session revoked"})
+        return "", "", fmt.Errorf("session revoked")
+    }
+    c.Set("session_id", claims.SessionID)
+
+    // Until the user enrolls an authenticator required by policy, only enrollment and logout are reachable
+    if claims.MFASetup && !strings.HasPrefix(c.FullPath(), "/api/2fa/") && c.FullPath() != "/api/logout" {
+        c.JSON(http.StatusForbidden, gin.H{"message": "Two-factor authentication setup required"})
+        return "", "", fmt.Errorf("two-factor authentication setup required")
+    }
+
     return claims.Email, claims.Role, nil
 }
 
@@ -119,6 +134,39 @@ func TeacherAuthMiddleware() gin.HandlerFunc {
     }
 }
 
+// ParentAuthMiddleware restricts access to parent/guardian users
+func ParentAuthMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        email, role, err := ValidateToken(c)
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+            c.Abort()
+            return
+        }
+
+        if role != "parent" {
+            c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+            c.Abort()
+            return
+        }
+
+        var storedRole string
+        err = db.QueryRow("SELECT role FROM users WHERE email = ?", email).Scan(&storedRole)
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
+            c.Abort()
+            return
+        }
+        if storedRole != "parent" {
+            c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+            c.Abort()
+            return
+        }
+
+        c.Next()
+    }
+}
+
 func StudentAuthMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         email, role, err := ValidateToken(c)
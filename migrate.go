@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Each driver has its own migration directory with the same version numbers
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationName matches files such as 0002_sessions.up.sql
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with its up and down SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations reads the embedded migration files of the database driver ordered by version
+func loadMigrations() ([]Migration, error) {
+	dir := "migrations/" + db.driver
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations returns the applied versions with their apply time, creating schema_migrations if needed
+func appliedMigrations() (map[int]string, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY, -- Migration number
+    name TEXT NOT NULL, -- Migration name
+    applied_at TEXT NOT NULL -- Apply time in RFC 3339 format
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes one migration step in a transaction together with its schema_migrations bookkeeping.
+// On SQLite foreign key enforcement is switched off on the connection for the duration, because
+// SQLite can only change constraints by rebuilding a table.
+func runMigration(m Migration, up bool) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if db.driver == driverSQLite {
+		var foreignKeys int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = "+strconv.Itoa(foreignKeys))
+	}
+
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{Tx: sqlTx, driver: db.driver}
+	defer tx.Rollback()
+
+	if up {
+		if _, err := tx.Exec(m.Up); err != nil {
+			return fmt.Errorf("migration %04d_%s up: %w", m.Version, m.Name, err)
+		}
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, formatTimestamp(time.Now()))
+	} else {
+		if _, err := tx.Exec(m.Down); err != nil {
+			return fmt.Errorf("migration %04d_%s down: %w", m.Version, m.Name, err)
+		}
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateUp applies pending migrations in order, at most limit of them (all if limit is 0).
+// Databases created before migrations existed are brought up to date as well, since every
+// migration only creates what is missing.
+func migrateUp(limit int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if limit > 0 && count == limit {
+			break
+		}
+		if err := runMigration(m, true); err != nil {
+			return err
+		}
+		log.Printf("applied migration %04d_%s", m.Version, m.Name)
+		count++
+	}
+	return nil
+}
+
+// migrateDown reverts the most recently applied migrations, steps of them
+func migrateDown(steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := runMigration(m, false); err != nil {
+			return err
+		}
+		log.Printf("reverted migration %04d_%s", m.Version, m.Name)
+		steps--
+	}
+	return nil
+}
+
+// migrationStatus prints every known migration and whether it is applied
+func migrationStatus() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	known := map[int]bool{}
+	for _, m := range migrations {
+		known[m.Version] = true
+		status := "pending"
+		if appliedAt, ok := applied[m.Version]; ok {
+			status = "applied " + appliedAt
+		}
+		fmt.Printf("%04d_%-30s %s\n", m.Version, m.Name, status)
+	}
+	for version := range applied {
+		if !known[version] {
+			fmt.Printf("%04d %-31s applied, but unknown to this build\n", version, "")
+		}
+	}
+	return nil
+}
+
+// runMigrateCommand handles `migrate up [N]`, `migrate down [N]` and `migrate status`
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up [N] | down [N] | status")
+	}
+
+	n := 0
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(n)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		return migrateDown(n)
+	case "status":
+		return migrationStatus()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
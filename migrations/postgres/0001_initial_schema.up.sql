@@ -0,0 +1,120 @@
+-- Initial schema, the PostgreSQL counterpart of the SQLite baseline.
+-- Timestamps and dates stay TEXT in RFC 3339 / YYYY-MM-DD format and flags stay INTEGER 0/1,
+-- so that both drivers store identical values.
+
+-- Table storing system users (students, teachers, admins)
+CREATE TABLE IF NOT EXISTS users (
+    uid INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL, -- Unique email address
+    password TEXT NOT NULL, -- User password
+    role TEXT NOT NULL CONSTRAINT users_role_check CHECK(role IN ('student', 'teacher', 'admin')) -- User role
+);
+
+-- Table storing personal information for users
+CREATE TABLE IF NOT EXISTS persons (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(uid), -- Reference to user
+    first_name TEXT NOT NULL, -- First name
+    last_name TEXT NOT NULL, -- Last name
+    birth_date TEXT CHECK(birth_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Birth date in YYYY-MM-DD format
+    address TEXT, -- Address
+    phone TEXT -- Phone number
+);
+
+-- Table storing classes (student groups)
+CREATE TABLE IF NOT EXISTS classes (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE -- Unique class name (e.g., "1A", "2B")
+);
+
+-- Table storing school subjects
+CREATE TABLE IF NOT EXISTS subjects (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE, -- Unique subject name (e.g., "Mathematics")
+    class_name TEXT REFERENCES classes(name), -- Name of the class assigned to the subject
+    teacher_id INTEGER REFERENCES users(uid) -- ID of the teacher assigned to the subject
+);
+
+-- Table storing student-subject assignments
+CREATE TABLE IF NOT EXISTS students_subjects (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    UNIQUE(user_id, subject_id) -- Prevents duplicates
+);
+
+-- Table storing teacher-subject assignments
+CREATE TABLE IF NOT EXISTS teachers_subjects (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    UNIQUE(user_id, subject_id) -- Prevents duplicates
+);
+
+-- Table storing exam and exam dates
+CREATE TABLE IF NOT EXISTS exams (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    class_name TEXT NOT NULL REFERENCES classes(name), -- Class name
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    date TEXT NOT NULL CHECK(date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Date of the exam in YYYY-MM-DD format
+    type TEXT NOT NULL CHECK(type IN ('test', 'exam','homework','presentation','essay','analysis','written assignment','quiz','pop quiz','other')), -- Type of exam
+    description TEXT -- Description of the exam
+);
+
+-- Table storing grades, comments, and custom values
+CREATE TABLE IF NOT EXISTS grades (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    exam_id INTEGER REFERENCES exams(id), -- exam ID (optional)
+    grade TEXT NOT NULL CHECK(length(grade) <= 255), -- Numeric grade (e.g., "5", "4.5"), comment (e.g., "Missing homework"), or custom value (e.g., "Pass")
+    weight INTEGER NOT NULL, -- Weight of the grade (e.g., 1 for homework, 2 for exam)
+    grade_type TEXT NOT NULL CHECK(grade_type IN ('numeric', 'comment','behavior note','custom')), -- Type of entry: numeric, comment, or custom
+    date TEXT NOT NULL CHECK(date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$') -- Date of entry in YYYY-MM-DD format
+);
+
+-- Table storing class memberships for users (students and teachers)
+CREATE TABLE IF NOT EXISTS class_members (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- User ID
+    class_name TEXT NOT NULL REFERENCES classes(name), -- Class name
+    UNIQUE(user_id, class_name) -- Prevents duplicates
+);
+
+-- Table storing the timetable
+CREATE TABLE IF NOT EXISTS timetable (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    day TEXT NOT NULL CHECK(day IN ('Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday')), -- Day of the week
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    class_period INTEGER NOT NULL, -- Class period (number)
+    time_start TEXT NOT NULL CHECK(time_start ~ '^[0-2][0-9]:[0-5][0-9]$'), -- Start time in HH:MM format
+    time_end TEXT NOT NULL CHECK(time_end ~ '^[0-2][0-9]:[0-5][0-9]$'), -- End time in HH:MM format
+    room TEXT, -- Room number or name
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    class_name TEXT NOT NULL REFERENCES classes(name) -- Class name
+);
+
+-- Table storing frequency of student attendance
+CREATE TABLE IF NOT EXISTS attendance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    date TEXT NOT NULL CHECK(date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Date of attendance in YYYY-MM-DD format
+    status TEXT NOT NULL CHECK(status IN ('present', 'absent', 'late')) -- Attendance status
+);
+
+-- Indexes for foreign keys to improve query performance
+CREATE INDEX IF NOT EXISTS idx_subjects_teacher_id ON subjects(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_subjects_class_name ON subjects(class_name);
+CREATE INDEX IF NOT EXISTS idx_students_subjects_subject_id ON students_subjects(subject_id);
+CREATE INDEX IF NOT EXISTS idx_teachers_subjects_subject_id ON teachers_subjects(subject_id);
+CREATE INDEX IF NOT EXISTS idx_grades_user_id ON grades(user_id);
+CREATE INDEX IF NOT EXISTS idx_grades_subject_id ON grades(subject_id);
+CREATE INDEX IF NOT EXISTS idx_class_members_class_name ON class_members(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_teacher_id ON timetable(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_timetable_class_name ON timetable(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_subject_id ON timetable(subject_id);
+CREATE INDEX IF NOT EXISTS idx_attendance_user_id ON attendance(user_id);
+CREATE INDEX IF NOT EXISTS idx_exams_class_name ON exams(class_name);
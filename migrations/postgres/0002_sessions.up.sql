@@ -0,0 +1,14 @@
+-- Table storing login sessions backing refresh tokens
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- User ID
+    refresh_token_hash TEXT NOT NULL UNIQUE, -- SHA-256 hash of the current refresh token
+    created_at TEXT NOT NULL, -- Creation time in RFC 3339 format
+    expires_at TEXT NOT NULL, -- Refresh token expiry in RFC 3339 format
+    revoked_at TEXT, -- Revocation time, NULL while the session is active
+    ip TEXT, -- Client IP at login
+    user_agent TEXT, -- Client user agent at login
+    FOREIGN KEY(user_id) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
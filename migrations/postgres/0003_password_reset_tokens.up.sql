@@ -0,0 +1,12 @@
+-- Table storing single-use password reset tokens
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- User ID
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 hash of the token sent by e-mail
+    created_at TEXT NOT NULL, -- Creation time in RFC 3339 format
+    expires_at TEXT NOT NULL, -- Expiry time in RFC 3339 format
+    used_at TEXT, -- Time the token was used or superseded, NULL while valid
+    FOREIGN KEY(user_id) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
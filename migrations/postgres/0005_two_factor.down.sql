@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mfa_policy;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
@@ -0,0 +1,26 @@
+-- Table storing TOTP authenticators (RFC 6238)
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id INTEGER PRIMARY KEY, -- User ID
+    secret TEXT NOT NULL, -- Base32-encoded shared secret
+    enabled INTEGER NOT NULL DEFAULT 0, -- 1 once the user confirmed a code
+    last_step INTEGER NOT NULL DEFAULT 0, -- Last accepted time step, prevents code replay
+    created_at TEXT NOT NULL, -- Enrollment start in RFC 3339 format
+    enabled_at TEXT, -- Confirmation time in RFC 3339 format
+    FOREIGN KEY(user_id) REFERENCES users(uid)
+);
+
+-- Table storing single-use two-factor recovery codes
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- User ID
+    code_hash TEXT NOT NULL, -- SHA-256 hash of the normalised code
+    used_at TEXT, -- Time the code was used, NULL while unused
+    UNIQUE(user_id, code_hash),
+    FOREIGN KEY(user_id) REFERENCES users(uid)
+);
+
+-- Table storing roles for which two-factor authentication is mandatory
+CREATE TABLE IF NOT EXISTS mfa_policy (
+    role TEXT PRIMARY KEY, -- User role
+    required INTEGER NOT NULL DEFAULT 0 -- 1 if users with the role must enroll
+);
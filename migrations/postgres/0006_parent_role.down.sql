@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS guardians;
+
+-- Fails while parent accounts still exist
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK(role IN ('student', 'teacher', 'admin'));
@@ -0,0 +1,14 @@
+-- Allow the parent role
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK(role IN ('student', 'teacher', 'admin', 'parent'));
+
+-- Table storing links between parents/guardians and students
+CREATE TABLE IF NOT EXISTS guardians (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    guardian_id INTEGER NOT NULL REFERENCES users(uid), -- Parent ID
+    student_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    relationship TEXT, -- Relationship to the student (e.g., "mother", "legal guardian")
+    UNIQUE(guardian_id, student_id) -- Prevents duplicates
+);
+
+CREATE INDEX IF NOT EXISTS idx_guardians_student_id ON guardians(student_id);
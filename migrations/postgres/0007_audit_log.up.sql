@@ -0,0 +1,18 @@
+-- Table storing the audit log of write operations
+-- actor_id has no foreign key so that entries outlive deleted accounts
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    actor_id INTEGER, -- User ID of the actor, NULL if unknown
+    actor_role TEXT, -- Role of the actor at the time of the change
+    action TEXT NOT NULL, -- Action performed (e.g., "create", "delete")
+    entity_type TEXT NOT NULL, -- Type of the changed entity (e.g., "grade")
+    entity_id TEXT NOT NULL, -- ID of the changed entity
+    before_json TEXT, -- Entity state before the change as JSON
+    after_json TEXT, -- Entity state after the change as JSON
+    ip TEXT, -- Client IP address
+    created_at TEXT NOT NULL -- Time of the change in RFC 3339 format
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
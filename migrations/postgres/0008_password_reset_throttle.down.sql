@@ -0,0 +1,3 @@
+DELETE FROM login_throttle WHERE scope NOT IN ('ip', 'account');
+ALTER TABLE login_throttle DROP CONSTRAINT IF EXISTS login_throttle_scope_check;
+ALTER TABLE login_throttle ADD CONSTRAINT login_throttle_scope_check CHECK(scope IN ('ip', 'account'));
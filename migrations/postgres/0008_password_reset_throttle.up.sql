@@ -0,0 +1,3 @@
+-- Reset requests are rate-limited with the login_throttle counters under their own scopes
+ALTER TABLE login_throttle DROP CONSTRAINT IF EXISTS login_throttle_scope_check;
+ALTER TABLE login_throttle ADD CONSTRAINT login_throttle_scope_check CHECK(scope IN ('ip', 'account', 'reset_ip', 'reset_account'));
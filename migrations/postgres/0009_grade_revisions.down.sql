@@ -0,0 +1 @@
+DROP TABLE IF EXISTS grade_revisions;
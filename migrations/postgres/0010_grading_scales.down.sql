@@ -0,0 +1,3 @@
+ALTER TABLE subjects DROP COLUMN IF EXISTS grading_scale_id;
+DROP TABLE IF EXISTS grading_scale_values;
+DROP TABLE IF EXISTS grading_scales;
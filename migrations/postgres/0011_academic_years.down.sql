@@ -0,0 +1,4 @@
+ALTER TABLE timetable DROP COLUMN IF EXISTS term_id;
+ALTER TABLE class_members DROP COLUMN IF EXISTS academic_year_id;
+DROP TABLE IF EXISTS terms;
+DROP TABLE IF EXISTS academic_years;
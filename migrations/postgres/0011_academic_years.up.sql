@@ -0,0 +1,28 @@
+-- Table storing school years (e.g., "2026/2027")
+CREATE TABLE IF NOT EXISTS academic_years (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE, -- Unique year name
+    start_date TEXT NOT NULL CHECK(start_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- First day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Last day in YYYY-MM-DD format
+    CHECK(end_date > start_date)
+);
+
+-- Table storing terms (e.g., semesters) of a school year
+-- Grades, attendance and exams belong to the term their date falls in
+CREATE TABLE IF NOT EXISTS terms (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    academic_year_id INTEGER NOT NULL REFERENCES academic_years(id), -- School year the term belongs to
+    name TEXT NOT NULL, -- Term name, unique within the year (e.g., "Semester 1")
+    start_date TEXT NOT NULL CHECK(start_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- First day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Last day in YYYY-MM-DD format
+    CHECK(end_date > start_date),
+    UNIQUE(academic_year_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_terms_dates ON terms(start_date, end_date);
+
+-- School year of a class membership, NULL for memberships made before years existed
+ALTER TABLE class_members ADD COLUMN academic_year_id INTEGER REFERENCES academic_years(id);
+
+-- Term a timetable entry applies to, NULL for every term
+ALTER TABLE timetable ADD COLUMN term_id INTEGER REFERENCES terms(id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS subject_drafts;
+
+DROP INDEX IF EXISTS idx_class_members_academic_year_id;
+
+-- Memberships repeating a class name in another year do not fit the old constraint; the latest one is kept
+DELETE FROM class_members WHERE id NOT IN (SELECT MAX(id) FROM class_members GROUP BY user_id, class_name);
+ALTER TABLE class_members DROP CONSTRAINT IF EXISTS class_members_user_id_class_name_academic_year_id_key;
+ALTER TABLE class_members ADD CONSTRAINT class_members_user_id_class_name_key UNIQUE(user_id, class_name);
+
+ALTER TABLE academic_years DROP COLUMN IF EXISTS archived_at;
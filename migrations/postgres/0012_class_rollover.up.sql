@@ -0,0 +1,21 @@
+-- Time a school year was archived by the end-of-year rollover, NULL while it is open
+ALTER TABLE academic_years ADD COLUMN archived_at TEXT;
+
+-- A student may belong to the same class name in different years (e.g., when repeating a year)
+ALTER TABLE class_members DROP CONSTRAINT IF EXISTS class_members_user_id_class_name_key;
+ALTER TABLE class_members ADD CONSTRAINT class_members_user_id_class_name_academic_year_id_key UNIQUE(user_id, class_name, academic_year_id);
+
+CREATE INDEX IF NOT EXISTS idx_class_members_academic_year_id ON class_members(academic_year_id);
+
+-- Table storing subject/teacher assignments proposed for the next year by the rollover
+-- They become subjects only when an admin applies them
+CREATE TABLE IF NOT EXISTS subject_drafts (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    academic_year_id INTEGER NOT NULL REFERENCES academic_years(id), -- School year the assignment is proposed for
+    source_subject_id INTEGER REFERENCES subjects(id), -- Subject of the finished year it was copied from
+    name TEXT NOT NULL, -- Subject name, unique within the year
+    class_name TEXT NOT NULL REFERENCES classes(name), -- Class the subject will be taught in
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Proposed teacher
+    grading_scale_id INTEGER REFERENCES grading_scales(id), -- Grading scale copied from the source subject
+    UNIQUE(academic_year_id, name)
+);
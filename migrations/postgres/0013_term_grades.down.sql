@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS term_grades;
+ALTER TABLE terms DROP COLUMN IF EXISTS proposal_deadline;
+ALTER TABLE classes DROP COLUMN IF EXISTS homeroom_teacher_id;
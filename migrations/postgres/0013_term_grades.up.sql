@@ -0,0 +1,24 @@
+-- Homeroom teacher of a class, who approves its term grades
+ALTER TABLE classes ADD COLUMN homeroom_teacher_id INTEGER REFERENCES users(uid);
+
+-- Last day teachers may propose term grades in YYYY-MM-DD format, NULL for no deadline
+ALTER TABLE terms ADD COLUMN proposal_deadline TEXT;
+
+-- Table storing proposed and final grades of a student in a subject for a term
+-- The final grade of the last term of a year is the annual grade
+CREATE TABLE IF NOT EXISTS term_grades (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    term_id INTEGER NOT NULL REFERENCES terms(id), -- Term ID
+    proposed_grade TEXT, -- Grade proposed by the subject teacher before the deadline
+    proposed_at TEXT, -- Time the grade was proposed (RFC 3339)
+    final_grade TEXT, -- Final grade entered by the subject teacher
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher who last changed the grades
+    updated_at TEXT NOT NULL, -- Time of the last change (RFC 3339)
+    approved_by INTEGER REFERENCES users(uid), -- Homeroom teacher or admin who approved the final grade
+    approved_at TEXT, -- Time of approval (RFC 3339); approved grades are locked
+    UNIQUE(user_id, subject_id, term_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_term_grades_term_id ON term_grades(term_id);
@@ -0,0 +1,7 @@
+-- Excused absences become plain absences again
+ALTER TABLE attendance DROP COLUMN IF EXISTS excuse_id;
+UPDATE attendance SET status = 'absent' WHERE status = 'excused';
+ALTER TABLE attendance DROP CONSTRAINT IF EXISTS attendance_status_check;
+ALTER TABLE attendance ADD CONSTRAINT attendance_status_check CHECK(status IN ('present', 'absent', 'late'));
+
+DROP TABLE IF EXISTS excuses;
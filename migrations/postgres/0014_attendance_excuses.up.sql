@@ -0,0 +1,22 @@
+-- Table storing requests to justify a student's absences, submitted by the student or a parent
+CREATE TABLE IF NOT EXISTS excuses (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(uid), -- Student ID
+    submitted_by INTEGER NOT NULL REFERENCES users(uid), -- Student or parent who submitted the excuse
+    reason TEXT NOT NULL, -- Reason given for the absence
+    start_date TEXT NOT NULL CHECK(start_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- First excused day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Last excused day in YYYY-MM-DD format
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'approved', 'rejected')), -- Review state
+    created_at TEXT NOT NULL, -- Submission time (RFC 3339)
+    reviewed_by INTEGER REFERENCES users(uid), -- Homeroom teacher or admin who reviewed the excuse
+    reviewed_at TEXT, -- Review time (RFC 3339)
+    review_comment TEXT, -- Optional comment of the reviewer
+    CHECK(end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_excuses_user_id ON excuses(user_id);
+
+-- Absences can be excused; excuse_id records the approved excuse that changed the record
+ALTER TABLE attendance DROP CONSTRAINT IF EXISTS attendance_status_check;
+ALTER TABLE attendance ADD CONSTRAINT attendance_status_check CHECK(status IN ('present', 'absent', 'late', 'excused'));
+ALTER TABLE attendance ADD COLUMN excuse_id INTEGER REFERENCES excuses(id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_attendance_lesson;
+ALTER TABLE attendance DROP COLUMN IF EXISTS class_period;
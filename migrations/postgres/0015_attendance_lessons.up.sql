@@ -0,0 +1,5 @@
+-- Lesson period an attendance record belongs to, NULL for records taken per day
+ALTER TABLE attendance ADD COLUMN class_period INTEGER;
+
+-- Retaking attendance for a lesson corrects its records instead of duplicating them
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendance_lesson ON attendance(user_id, subject_id, date, class_period);
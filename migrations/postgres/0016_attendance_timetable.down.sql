@@ -0,0 +1 @@
+ALTER TABLE attendance DROP COLUMN IF EXISTS timetable_id;
@@ -0,0 +1,2 @@
+-- Timetable entry of the lesson an attendance record was taken for, NULL when no entry is scheduled
+ALTER TABLE attendance ADD COLUMN timetable_id INTEGER REFERENCES timetable(id);
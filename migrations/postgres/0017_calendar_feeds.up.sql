@@ -0,0 +1,16 @@
+-- Table storing days without lessons, such as public holidays and school breaks
+CREATE TABLE IF NOT EXISTS holidays (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL, -- Holiday name (e.g., "Christmas break")
+    start_date TEXT NOT NULL CHECK(start_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- First day off in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Last day off in YYYY-MM-DD format
+    CHECK(end_date >= start_date)
+);
+
+-- Table storing the secret tokens of users' calendar feed URLs, one per user
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL UNIQUE REFERENCES users(uid), -- User ID
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 hash of the token in the feed URL
+    created_at TEXT NOT NULL -- Creation time in RFC 3339 format
+);
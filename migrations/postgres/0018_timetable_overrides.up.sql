@@ -0,0 +1,18 @@
+-- Table storing changes to the weekly timetable on a single date, such as cancelled lessons and substitutions
+CREATE TABLE IF NOT EXISTS timetable_overrides (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    timetable_id INTEGER NOT NULL REFERENCES timetable(id), -- Timetable entry of the changed lesson
+    date TEXT NOT NULL CHECK(date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Date of the lesson in YYYY-MM-DD format
+    cancelled INTEGER NOT NULL DEFAULT 0 CHECK(cancelled IN (0, 1)), -- 1 when the lesson does not take place
+    substitute_teacher_id INTEGER REFERENCES users(uid), -- Teacher taking the lesson instead, NULL for the usual teacher
+    room TEXT, -- Room the lesson is moved to, NULL for the usual room
+    class_period INTEGER, -- Period the lesson is moved to, NULL for the usual period
+    time_start TEXT, -- Start time the lesson is moved to in HH:MM format, NULL for the usual time
+    time_end TEXT, -- End time the lesson is moved to in HH:MM format, NULL for the usual time
+    note TEXT, -- Note shown with the change (e.g., "Teacher ill")
+    created_by INTEGER NOT NULL REFERENCES users(uid), -- Admin who entered the change
+    created_at TEXT NOT NULL, -- Creation time (RFC 3339)
+    UNIQUE(timetable_id, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_timetable_overrides_date ON timetable_overrides(date);
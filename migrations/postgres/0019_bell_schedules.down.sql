@@ -0,0 +1,13 @@
+-- Entries following the bell schedule get the times of the default schedule back
+UPDATE timetable SET
+    time_start = COALESCE(time_start, (SELECT bell_periods.time_start FROM bell_periods JOIN bell_schedules ON bell_schedules.id = bell_periods.schedule_id
+        WHERE bell_schedules.is_default = 1 AND bell_periods.class_period = timetable.class_period), '00:00'),
+    time_end = COALESCE(time_end, (SELECT bell_periods.time_end FROM bell_periods JOIN bell_schedules ON bell_schedules.id = bell_periods.schedule_id
+        WHERE bell_schedules.is_default = 1 AND bell_periods.class_period = timetable.class_period), '00:00');
+ALTER TABLE timetable ALTER COLUMN time_start SET NOT NULL;
+ALTER TABLE timetable ALTER COLUMN time_end SET NOT NULL;
+
+DROP TABLE IF EXISTS bell_schedule_dates;
+DROP TABLE IF EXISTS bell_periods;
+DROP INDEX IF EXISTS idx_bell_schedules_default;
+DROP TABLE IF EXISTS bell_schedules;
@@ -0,0 +1,46 @@
+-- Table storing bell schedules; the default one applies unless a date is assigned another (e.g., shortened days)
+CREATE TABLE IF NOT EXISTS bell_schedules (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE, -- Schedule name (e.g., "Standard", "Shortened lessons")
+    is_default INTEGER NOT NULL DEFAULT 0 CHECK(is_default IN (0, 1)) -- 1 for the schedule used on ordinary days
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bell_schedules_default ON bell_schedules(is_default) WHERE is_default = 1;
+
+-- Table storing the start and end times of each class period in a bell schedule
+CREATE TABLE IF NOT EXISTS bell_periods (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    schedule_id INTEGER NOT NULL REFERENCES bell_schedules(id), -- Bell schedule ID
+    class_period INTEGER NOT NULL CHECK(class_period > 0), -- Class period number
+    time_start TEXT NOT NULL CHECK(time_start ~ '^[0-2][0-9]:[0-5][0-9]$'), -- Start time in HH:MM format
+    time_end TEXT NOT NULL CHECK(time_end ~ '^[0-2][0-9]:[0-5][0-9]$'), -- End time in HH:MM format
+    CHECK(time_end > time_start),
+    UNIQUE(schedule_id, class_period)
+);
+
+-- Table storing the dates on which a bell schedule other than the default one applies
+CREATE TABLE IF NOT EXISTS bell_schedule_dates (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    schedule_id INTEGER NOT NULL REFERENCES bell_schedules(id), -- Bell schedule ID
+    start_date TEXT NOT NULL CHECK(start_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- First day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date ~ '^[0-9]{4}-[0-1][0-9]-[0-3][0-9]$'), -- Last day in YYYY-MM-DD format
+    CHECK(end_date >= start_date)
+);
+
+-- Timetable entries take their times from the bell schedule; time_start and time_end are kept only for entries with their own times
+ALTER TABLE timetable ALTER COLUMN time_start DROP NOT NULL;
+ALTER TABLE timetable ALTER COLUMN time_end DROP NOT NULL;
+
+-- The default schedule is built from the most common times of each period in the existing timetable
+INSERT INTO bell_schedules (name, is_default)
+    SELECT 'Standard', 1 WHERE EXISTS (SELECT 1 FROM timetable WHERE class_period > 0 AND time_end > time_start);
+INSERT INTO bell_periods (schedule_id, class_period, time_start, time_end)
+    SELECT (SELECT id FROM bell_schedules WHERE is_default = 1), class_period, time_start, time_end FROM (
+        SELECT class_period, time_start, time_end,
+            ROW_NUMBER() OVER (PARTITION BY class_period ORDER BY COUNT(*) DESC, time_start, time_end) AS position
+        FROM timetable WHERE class_period > 0 AND time_end > time_start
+        GROUP BY class_period, time_start, time_end
+    ) AS common WHERE position = 1;
+UPDATE timetable SET time_start = NULL, time_end = NULL
+    WHERE EXISTS (SELECT 1 FROM bell_periods WHERE bell_periods.class_period = timetable.class_period
+        AND bell_periods.time_start = timetable.time_start AND bell_periods.time_end = timetable.time_end);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_timetable_draft_entries_draft;
+DROP TABLE IF EXISTS timetable_draft_entries;
+DROP TABLE IF EXISTS timetable_drafts;
+DROP TABLE IF EXISTS teacher_availability;
+DROP TABLE IF EXISTS teaching_requirements;
+DROP TABLE IF EXISTS rooms;
@@ -0,0 +1,54 @@
+-- Table storing the rooms lessons can be held in, used by the timetable generator
+CREATE TABLE IF NOT EXISTS rooms (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE, -- Room number or name, as written in timetable.room
+    capacity INTEGER NOT NULL CHECK(capacity > 0), -- Number of students the room holds
+    room_type TEXT NOT NULL DEFAULT '' -- Kind of room (e.g., "lab", "gym"), empty for an ordinary classroom
+);
+
+-- Table storing how many lessons of a subject its class has each week
+CREATE TABLE IF NOT EXISTS teaching_requirements (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    subject_id INTEGER NOT NULL UNIQUE REFERENCES subjects(id), -- Subject, which gives the class and the teacher
+    hours_per_week INTEGER NOT NULL CHECK(hours_per_week > 0), -- Lessons per week
+    max_per_day INTEGER CHECK(max_per_day > 0), -- Most lessons of the subject on one day, NULL to spread them over the week
+    room_type TEXT NOT NULL DEFAULT '' -- Kind of room the lessons need, empty for any room
+);
+
+-- Table storing when teachers cannot or would rather not teach
+CREATE TABLE IF NOT EXISTS teacher_availability (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    day TEXT NOT NULL, -- Day of the week (e.g., "Monday")
+    class_period INTEGER NOT NULL DEFAULT 0 CHECK(class_period >= 0), -- Class period number, 0 for the whole day
+    preference TEXT NOT NULL CHECK(preference IN ('unavailable', 'avoid', 'prefer')), -- Whether the teacher cannot, would rather not or would rather teach then
+    UNIQUE(teacher_id, day, class_period)
+);
+
+-- Table storing timetables drafted by the generator for admins to review before publishing
+CREATE TABLE IF NOT EXISTS timetable_drafts (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    term_id INTEGER NOT NULL REFERENCES terms(id), -- Term the timetable is drafted for
+    days TEXT NOT NULL, -- Comma-separated days of the week lessons were placed on
+    max_lessons_per_day INTEGER NOT NULL CHECK(max_lessons_per_day > 0), -- Most lessons a class has on one day
+    allow_gaps INTEGER NOT NULL DEFAULT 0 CHECK(allow_gaps IN (0, 1)), -- 1 when students may have free periods between lessons
+    created_by INTEGER NOT NULL REFERENCES users(uid), -- Admin who generated the draft
+    created_at TEXT NOT NULL, -- Creation time (RFC 3339)
+    published_by INTEGER REFERENCES users(uid), -- Admin who published the draft, NULL while it is a draft
+    published_at TEXT -- Publication time (RFC 3339), NULL while it is a draft
+);
+
+-- Table storing the lessons of a drafted timetable
+CREATE TABLE IF NOT EXISTS timetable_draft_entries (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    draft_id INTEGER NOT NULL REFERENCES timetable_drafts(id), -- Draft the lesson belongs to
+    subject_id INTEGER NOT NULL REFERENCES subjects(id), -- Subject ID
+    day TEXT, -- Day of the week, NULL for a lesson the generator could not place
+    class_period INTEGER, -- Class period number, NULL for a lesson the generator could not place
+    room TEXT, -- Room number or name, NULL for none
+    teacher_id INTEGER NOT NULL REFERENCES users(uid), -- Teacher ID
+    class_name TEXT NOT NULL REFERENCES classes(name), -- Class name
+    CHECK((day IS NULL) = (class_period IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_timetable_draft_entries_draft ON timetable_draft_entries(draft_id);
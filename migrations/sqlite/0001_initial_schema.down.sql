@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS exams;
+DROP TABLE IF EXISTS attendance;
+DROP TABLE IF EXISTS timetable;
+DROP TABLE IF EXISTS class_members;
+DROP TABLE IF EXISTS grades;
+DROP TABLE IF EXISTS teachers_subjects;
+DROP TABLE IF EXISTS students_subjects;
+DROP TABLE IF EXISTS subjects;
+DROP TABLE IF EXISTS classes;
+DROP TABLE IF EXISTS persons;
+DROP TABLE IF EXISTS users;
@@ -1,4 +1,5 @@
--- schema.sql
+-- Initial schema, matching the schema.sql bootstrap that existing deployments were created with
+
 -- Table storing system users (students, teachers, admins)
 CREATE TABLE IF NOT EXISTS users (
     uid INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -125,19 +126,19 @@ CREATE TABLE IF NOT EXISTS exams(
 );
 
 -- Indexes for foreign keys to improve query performance
-CREATE INDEX idx_persons_user_id ON persons(user_id);
-CREATE INDEX idx_subjects_teacher_id ON subjects(teacher_id);
-CREATE INDEX idx_subjects_class_name ON subjects(class_name);
-CREATE INDEX idx_students_subjects_user_id ON students_subjects(user_id);
-CREATE INDEX idx_students_subjects_subject_id ON students_subjects(subject_id);
-CREATE INDEX idx_teachers_subjects_user_id ON teachers_subjects(user_id);
-CREATE INDEX idx_teachers_subjects_subject_id ON teachers_subjects(subject_id);
-CREATE INDEX idx_grades_user_id ON grades(user_id);
-CREATE INDEX idx_grades_subject_id ON grades(subject_id);
-CREATE INDEX idx_class_members_user_id ON class_members(user_id);
-CREATE INDEX idx_class_members_class_name ON class_members(class_name);
-CREATE INDEX idx_timetable_teacher_id ON timetable(teacher_id);
-CREATE INDEX idx_timetable_class_name ON timetable(class_name);
-CREATE INDEX idx_timetable_subject_id ON timetable(subject_id);
-CREATE INDEX idx_attendance_user_id ON attendance(user_id);
-CREATE INDEX idx_exams_class_name ON exams(class_name);
+CREATE INDEX IF NOT EXISTS idx_persons_user_id ON persons(user_id);
+CREATE INDEX IF NOT EXISTS idx_subjects_teacher_id ON subjects(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_subjects_class_name ON subjects(class_name);
+CREATE INDEX IF NOT EXISTS idx_students_subjects_user_id ON students_subjects(user_id);
+CREATE INDEX IF NOT EXISTS idx_students_subjects_subject_id ON students_subjects(subject_id);
+CREATE INDEX IF NOT EXISTS idx_teachers_subjects_user_id ON teachers_subjects(user_id);
+CREATE INDEX IF NOT EXISTS idx_teachers_subjects_subject_id ON teachers_subjects(subject_id);
+CREATE INDEX IF NOT EXISTS idx_grades_user_id ON grades(user_id);
+CREATE INDEX IF NOT EXISTS idx_grades_subject_id ON grades(subject_id);
+CREATE INDEX IF NOT EXISTS idx_class_members_user_id ON class_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_class_members_class_name ON class_members(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_teacher_id ON timetable(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_timetable_class_name ON timetable(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_subject_id ON timetable(subject_id);
+CREATE INDEX IF NOT EXISTS idx_attendance_user_id ON attendance(user_id);
+CREATE INDEX IF NOT EXISTS idx_exams_class_name ON exams(class_name);
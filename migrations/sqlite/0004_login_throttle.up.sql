@@ -0,0 +1,25 @@
+-- Table storing failed login counters per client IP and per account
+CREATE TABLE IF NOT EXISTS login_throttle (
+    scope TEXT NOT NULL CHECK(scope IN ('ip', 'account')), -- Counter type
+    subject TEXT NOT NULL, -- Client IP or lower-cased account e-mail
+    failures INTEGER NOT NULL, -- Consecutive failed attempts
+    last_failure_at TEXT NOT NULL, -- Time of the last failure in RFC 3339 format
+    locked_until TEXT, -- End of the current lockout, NULL if not locked
+    PRIMARY KEY(scope, subject)
+);
+
+-- Table storing login lockout events
+CREATE TABLE IF NOT EXISTS lockout_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL CHECK(scope IN ('ip', 'account')), -- Locked subject type
+    subject TEXT NOT NULL, -- Client IP or lower-cased account e-mail
+    ip TEXT, -- Client IP of the attempt that triggered the lockout
+    failures INTEGER NOT NULL, -- Failed attempts counted at lockout time
+    locked_at TEXT NOT NULL, -- Lockout start in RFC 3339 format
+    locked_until TEXT NOT NULL, -- Lockout end in RFC 3339 format
+    unlocked_at TEXT, -- Time an admin lifted the lockout
+    unlocked_by INTEGER, -- Admin ID
+    FOREIGN KEY(unlocked_by) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lockout_events_subject ON lockout_events(scope, subject);
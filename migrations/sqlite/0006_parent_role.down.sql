@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS guardians;
+
+-- Fails while parent accounts still exist
+CREATE TABLE users_new (
+    uid INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT UNIQUE NOT NULL, -- Unique email address
+    password TEXT NOT NULL, -- User password
+    role TEXT NOT NULL CHECK(role IN ('student', 'teacher', 'admin')), -- User role
+    UNIQUE(email)
+);
+INSERT INTO users_new (uid, email, password, role) SELECT uid, email, password, role FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
@@ -0,0 +1,25 @@
+-- SQLite cannot alter a CHECK constraint, so users is rebuilt with the parent role allowed
+CREATE TABLE users_new (
+    uid INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT UNIQUE NOT NULL, -- Unique email address
+    password TEXT NOT NULL, -- User password
+    role TEXT NOT NULL CHECK(role IN ('student', 'teacher', 'admin', 'parent')), -- User role
+    UNIQUE(email)
+);
+INSERT INTO users_new (uid, email, password, role) SELECT uid, email, password, role FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+-- Table storing links between parents/guardians and students
+CREATE TABLE IF NOT EXISTS guardians (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    guardian_id INTEGER NOT NULL, -- Parent ID
+    student_id INTEGER NOT NULL, -- Student ID
+    relationship TEXT, -- Relationship to the student (e.g., "mother", "legal guardian")
+    UNIQUE(guardian_id, student_id), -- Prevents duplicates
+    FOREIGN KEY(guardian_id) REFERENCES users(uid),
+    FOREIGN KEY(student_id) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guardians_guardian_id ON guardians(guardian_id);
+CREATE INDEX IF NOT EXISTS idx_guardians_student_id ON guardians(student_id);
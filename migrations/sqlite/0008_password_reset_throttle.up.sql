@@ -0,0 +1,13 @@
+-- Reset requests are rate-limited with the login_throttle counters under their own scopes.
+-- SQLite cannot alter a CHECK constraint, so the table is rebuilt.
+CREATE TABLE login_throttle_new (
+    scope TEXT NOT NULL CHECK(scope IN ('ip', 'account', 'reset_ip', 'reset_account')), -- Counter type
+    subject TEXT NOT NULL, -- Client IP or lower-cased account e-mail
+    failures INTEGER NOT NULL, -- Consecutive failed attempts, or reset requests for the reset scopes
+    last_failure_at TEXT NOT NULL, -- Time of the last failure in RFC 3339 format
+    locked_until TEXT, -- End of the current lockout, NULL if not locked
+    PRIMARY KEY(scope, subject)
+);
+INSERT INTO login_throttle_new SELECT scope, subject, failures, last_failure_at, locked_until FROM login_throttle;
+DROP TABLE login_throttle;
+ALTER TABLE login_throttle_new RENAME TO login_throttle;
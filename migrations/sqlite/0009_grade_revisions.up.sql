@@ -0,0 +1,20 @@
+-- Table storing the previous values of edited and deleted grades
+-- grade_id has no foreign key so that the history outlives a deleted grade
+CREATE TABLE IF NOT EXISTS grade_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    grade_id INTEGER NOT NULL, -- Grade ID
+    action TEXT NOT NULL CHECK(action IN ('update', 'delete')), -- Change made to the grade
+    user_id INTEGER NOT NULL, -- Student ID before the change
+    subject_id INTEGER NOT NULL, -- Subject ID before the change
+    teacher_id INTEGER NOT NULL, -- Teacher ID before the change
+    grade TEXT NOT NULL, -- Grade value before the change
+    weight INTEGER, -- Weight before the change
+    grade_type TEXT NOT NULL, -- Grade type before the change
+    date TEXT NOT NULL, -- Date of entry before the change
+    editor_id INTEGER NOT NULL, -- Teacher or admin who made the change
+    reason TEXT NOT NULL, -- Reason given for the change
+    created_at TEXT NOT NULL, -- Time of the change in RFC 3339 format
+    FOREIGN KEY(editor_id) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_grade_revisions_grade_id ON grade_revisions(grade_id);
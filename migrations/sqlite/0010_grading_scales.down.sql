@@ -0,0 +1,14 @@
+CREATE TABLE subjects_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE, -- Unique subject name (e.g., "Mathematics")
+    class_name TEXT, -- Name of the class assigned to the subject
+    teacher_id INTEGER, -- ID of the teacher assigned to the subject
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(class_name) REFERENCES classes(name)
+);
+INSERT INTO subjects_new (id, name, class_name, teacher_id) SELECT id, name, class_name, teacher_id FROM subjects;
+DROP TABLE subjects;
+ALTER TABLE subjects_new RENAME TO subjects;
+
+DROP TABLE IF EXISTS grading_scale_values;
+DROP TABLE IF EXISTS grading_scales;
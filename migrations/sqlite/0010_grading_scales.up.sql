@@ -0,0 +1,59 @@
+-- Table storing grading scales (e.g., Polish 1-6, percentages, A-F, pass/fail)
+-- A 'list' scale accepts the values in grading_scale_values, a 'range' scale any number between min_value and max_value
+CREATE TABLE IF NOT EXISTS grading_scales (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE, -- Unique scale name
+    kind TEXT NOT NULL CHECK(kind IN ('list', 'range')), -- How values are checked
+    min_value REAL, -- Lowest value of a 'range' scale
+    max_value REAL, -- Highest value of a 'range' scale
+    is_default INTEGER NOT NULL DEFAULT 0 CHECK(is_default IN (0, 1)) -- 1 for the scale used by subjects without their own
+);
+
+-- At most one scale is the default
+CREATE UNIQUE INDEX IF NOT EXISTS idx_grading_scales_default ON grading_scales(is_default) WHERE is_default = 1;
+
+-- Table storing the values accepted by 'list' scales
+CREATE TABLE IF NOT EXISTS grading_scale_values (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scale_id INTEGER NOT NULL, -- Scale the value belongs to
+    value TEXT NOT NULL, -- Grade as entered (e.g., "4+", "A", "pass")
+    numeric_value REAL, -- Equivalent used in averages, NULL when the value is not averaged
+    UNIQUE(scale_id, value),
+    FOREIGN KEY(scale_id) REFERENCES grading_scales(id) ON DELETE CASCADE
+);
+
+-- Scale of each subject, NULL to use the default scale
+ALTER TABLE subjects ADD COLUMN grading_scale_id INTEGER REFERENCES grading_scales(id);
+
+-- Built-in scales; none of them is the default until an admin picks one
+INSERT INTO grading_scales (name, kind, min_value, max_value, is_default) VALUES
+    ('Polish 1-6', 'list', NULL, NULL, 0),
+    ('Percentage', 'range', 0, 100, 0),
+    ('A-F', 'list', NULL, NULL, 0),
+    ('Pass/Fail', 'list', NULL, NULL, 0);
+
+INSERT INTO grading_scale_values (scale_id, value, numeric_value) VALUES
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '1', 1),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '1+', 1.5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '2-', 1.75),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '2', 2),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '2+', 2.5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '3-', 2.75),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '3', 3),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '3+', 3.5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '4-', 3.75),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '4', 4),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '4+', 4.5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '5-', 4.75),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '5', 5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '5+', 5.5),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '6-', 5.75),
+    ((SELECT id FROM grading_scales WHERE name = 'Polish 1-6'), '6', 6),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'A', 5),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'B', 4),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'C', 3),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'D', 2),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'E', 1),
+    ((SELECT id FROM grading_scales WHERE name = 'A-F'), 'F', 0),
+    ((SELECT id FROM grading_scales WHERE name = 'Pass/Fail'), 'pass', NULL),
+    ((SELECT id FROM grading_scales WHERE name = 'Pass/Fail'), 'fail', NULL);
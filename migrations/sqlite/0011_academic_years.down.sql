@@ -0,0 +1,33 @@
+CREATE TABLE timetable_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    day TEXT NOT NULL CHECK(day IN ('Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday')), -- Day of the week
+    subject_id INTEGER NOT NULL, -- Subject ID
+    class_period INTEGER NOT NULL, -- Class period (number)
+    time_start TEXT NOT NULL CHECK(time_start GLOB '[0-2][0-9]:[0-5][0-9]'), -- Start time in HH:MM format
+    time_end TEXT NOT NULL CHECK(time_end GLOB '[0-2][0-9]:[0-5][0-9]'), -- End time in HH:MM format
+    room TEXT, -- Room number or name
+    teacher_id INTEGER NOT NULL, -- Teacher ID
+    class_name TEXT NOT NULL, -- Class name
+    FOREIGN KEY(class_name) REFERENCES classes(name),
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id)
+);
+INSERT INTO timetable_new (id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name)
+    SELECT id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name FROM timetable;
+DROP TABLE timetable;
+ALTER TABLE timetable_new RENAME TO timetable;
+
+CREATE TABLE class_members_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- User ID
+    class_name TEXT NOT NULL, -- Class name
+    UNIQUE(user_id, class_name), -- Prevents duplicates
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(class_name) REFERENCES classes(name)
+);
+INSERT INTO class_members_new (id, user_id, class_name) SELECT id, user_id, class_name FROM class_members;
+DROP TABLE class_members;
+ALTER TABLE class_members_new RENAME TO class_members;
+
+DROP TABLE IF EXISTS terms;
+DROP TABLE IF EXISTS academic_years;
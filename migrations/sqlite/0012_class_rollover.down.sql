@@ -0,0 +1,22 @@
+DROP TABLE IF EXISTS subject_drafts;
+
+-- Memberships repeating a class name in another year do not fit the old constraint; the latest one is kept
+CREATE TABLE class_members_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- User ID
+    class_name TEXT NOT NULL, -- Class name
+    academic_year_id INTEGER REFERENCES academic_years(id), -- School year of the membership, NULL for memberships made before years existed
+    UNIQUE(user_id, class_name), -- Prevents duplicates
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(class_name) REFERENCES classes(name)
+);
+INSERT INTO class_members_new (id, user_id, class_name, academic_year_id)
+    SELECT id, user_id, class_name, academic_year_id FROM class_members
+    WHERE id IN (SELECT MAX(id) FROM class_members GROUP BY user_id, class_name);
+DROP TABLE class_members;
+ALTER TABLE class_members_new RENAME TO class_members;
+
+CREATE INDEX IF NOT EXISTS idx_class_members_user_id ON class_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_class_members_class_name ON class_members(class_name);
+
+ALTER TABLE academic_years DROP COLUMN archived_at;
@@ -0,0 +1,40 @@
+-- Time a school year was archived by the end-of-year rollover, NULL while it is open
+ALTER TABLE academic_years ADD COLUMN archived_at TEXT;
+
+-- A student may belong to the same class name in different years (e.g., when repeating a year)
+CREATE TABLE class_members_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- User ID
+    class_name TEXT NOT NULL, -- Class name
+    academic_year_id INTEGER, -- School year of the membership, NULL for memberships made before years existed
+    UNIQUE(user_id, class_name, academic_year_id), -- Prevents duplicates
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(class_name) REFERENCES classes(name),
+    FOREIGN KEY(academic_year_id) REFERENCES academic_years(id)
+);
+INSERT INTO class_members_new (id, user_id, class_name, academic_year_id)
+    SELECT id, user_id, class_name, academic_year_id FROM class_members;
+DROP TABLE class_members;
+ALTER TABLE class_members_new RENAME TO class_members;
+
+CREATE INDEX IF NOT EXISTS idx_class_members_user_id ON class_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_class_members_class_name ON class_members(class_name);
+CREATE INDEX IF NOT EXISTS idx_class_members_academic_year_id ON class_members(academic_year_id);
+
+-- Table storing subject/teacher assignments proposed for the next year by the rollover
+-- They become subjects only when an admin applies them
+CREATE TABLE IF NOT EXISTS subject_drafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    academic_year_id INTEGER NOT NULL, -- School year the assignment is proposed for
+    source_subject_id INTEGER, -- Subject of the finished year it was copied from
+    name TEXT NOT NULL, -- Subject name, unique within the year
+    class_name TEXT NOT NULL, -- Class the subject will be taught in
+    teacher_id INTEGER NOT NULL, -- Proposed teacher
+    grading_scale_id INTEGER, -- Grading scale copied from the source subject
+    UNIQUE(academic_year_id, name),
+    FOREIGN KEY(academic_year_id) REFERENCES academic_years(id),
+    FOREIGN KEY(source_subject_id) REFERENCES subjects(id),
+    FOREIGN KEY(class_name) REFERENCES classes(name),
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(grading_scale_id) REFERENCES grading_scales(id)
+);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS term_grades;
+
+ALTER TABLE terms DROP COLUMN proposal_deadline;
+
+CREATE TABLE classes_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE -- Unique class name (e.g., "1A", "2B")
+);
+INSERT INTO classes_new (id, name) SELECT id, name FROM classes;
+DROP TABLE classes;
+ALTER TABLE classes_new RENAME TO classes;
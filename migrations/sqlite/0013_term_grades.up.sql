@@ -0,0 +1,29 @@
+-- Homeroom teacher of a class, who approves its term grades
+ALTER TABLE classes ADD COLUMN homeroom_teacher_id INTEGER REFERENCES users(uid);
+
+-- Last day teachers may propose term grades in YYYY-MM-DD format, NULL for no deadline
+ALTER TABLE terms ADD COLUMN proposal_deadline TEXT;
+
+-- Table storing proposed and final grades of a student in a subject for a term
+-- The final grade of the last term of a year is the annual grade
+CREATE TABLE IF NOT EXISTS term_grades (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Student ID
+    subject_id INTEGER NOT NULL, -- Subject ID
+    term_id INTEGER NOT NULL, -- Term ID
+    proposed_grade TEXT, -- Grade proposed by the subject teacher before the deadline
+    proposed_at TEXT, -- Time the grade was proposed (RFC 3339)
+    final_grade TEXT, -- Final grade entered by the subject teacher
+    teacher_id INTEGER NOT NULL, -- Teacher who last changed the grades
+    updated_at TEXT NOT NULL, -- Time of the last change (RFC 3339)
+    approved_by INTEGER, -- Homeroom teacher or admin who approved the final grade
+    approved_at TEXT, -- Time of approval (RFC 3339); approved grades are locked
+    UNIQUE(user_id, subject_id, term_id),
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id),
+    FOREIGN KEY(term_id) REFERENCES terms(id),
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(approved_by) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_term_grades_term_id ON term_grades(term_id);
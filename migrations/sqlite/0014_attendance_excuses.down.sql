@@ -0,0 +1,18 @@
+-- Excused absences become plain absences again
+CREATE TABLE attendance_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Student ID
+    subject_id INTEGER NOT NULL, -- Subject ID
+    date TEXT NOT NULL CHECK(date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- Date of attendance in YYYY-MM-DD format
+    status TEXT NOT NULL CHECK(status IN ('present', 'absent', 'late')), -- Attendance status
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id)
+);
+INSERT INTO attendance_new (id, user_id, subject_id, date, status)
+    SELECT id, user_id, subject_id, date, CASE status WHEN 'excused' THEN 'absent' ELSE status END FROM attendance;
+DROP TABLE attendance;
+ALTER TABLE attendance_new RENAME TO attendance;
+
+CREATE INDEX IF NOT EXISTS idx_attendance_user_id ON attendance(user_id);
+
+DROP TABLE IF EXISTS excuses;
@@ -0,0 +1,39 @@
+-- Table storing requests to justify a student's absences, submitted by the student or a parent
+CREATE TABLE IF NOT EXISTS excuses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Student ID
+    submitted_by INTEGER NOT NULL, -- Student or parent who submitted the excuse
+    reason TEXT NOT NULL, -- Reason given for the absence
+    start_date TEXT NOT NULL CHECK(start_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- First excused day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- Last excused day in YYYY-MM-DD format
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'approved', 'rejected')), -- Review state
+    created_at TEXT NOT NULL, -- Submission time (RFC 3339)
+    reviewed_by INTEGER, -- Homeroom teacher or admin who reviewed the excuse
+    reviewed_at TEXT, -- Review time (RFC 3339)
+    review_comment TEXT, -- Optional comment of the reviewer
+    CHECK(end_date >= start_date),
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(submitted_by) REFERENCES users(uid),
+    FOREIGN KEY(reviewed_by) REFERENCES users(uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_excuses_user_id ON excuses(user_id);
+
+-- Absences can be excused; excuse_id records the approved excuse that changed the record
+CREATE TABLE attendance_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- Student ID
+    subject_id INTEGER NOT NULL, -- Subject ID
+    date TEXT NOT NULL CHECK(date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- Date of attendance in YYYY-MM-DD format
+    status TEXT NOT NULL CHECK(status IN ('present', 'absent', 'late', 'excused')), -- Attendance status
+    excuse_id INTEGER, -- Approved excuse that justified the absence
+    FOREIGN KEY(user_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id),
+    FOREIGN KEY(excuse_id) REFERENCES excuses(id)
+);
+INSERT INTO attendance_new (id, user_id, subject_id, date, status)
+    SELECT id, user_id, subject_id, date, status FROM attendance;
+DROP TABLE attendance;
+ALTER TABLE attendance_new RENAME TO attendance;
+
+CREATE INDEX IF NOT EXISTS idx_attendance_user_id ON attendance(user_id);
@@ -0,0 +1,17 @@
+-- Table storing days without lessons, such as public holidays and school breaks
+CREATE TABLE IF NOT EXISTS holidays (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL, -- Holiday name (e.g., "Christmas break")
+    start_date TEXT NOT NULL CHECK(start_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- First day off in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- Last day off in YYYY-MM-DD format
+    CHECK(end_date >= start_date)
+);
+
+-- Table storing the secret tokens of users' calendar feed URLs, one per user
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL UNIQUE, -- User ID
+    token_hash TEXT NOT NULL UNIQUE, -- SHA-256 hash of the token in the feed URL
+    created_at TEXT NOT NULL, -- Creation time in RFC 3339 format
+    FOREIGN KEY(user_id) REFERENCES users(uid)
+);
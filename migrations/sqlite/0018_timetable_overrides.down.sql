@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_timetable_overrides_date;
+DROP TABLE IF EXISTS timetable_overrides;
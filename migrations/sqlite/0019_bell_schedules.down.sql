@@ -0,0 +1,34 @@
+-- Entries following the bell schedule get the times of the default schedule back
+CREATE TABLE timetable_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    day TEXT NOT NULL CHECK(day IN ('Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday')), -- Day of the week
+    subject_id INTEGER NOT NULL, -- Subject ID
+    class_period INTEGER NOT NULL, -- Class period (number)
+    time_start TEXT NOT NULL CHECK(time_start GLOB '[0-2][0-9]:[0-5][0-9]'), -- Start time in HH:MM format
+    time_end TEXT NOT NULL CHECK(time_end GLOB '[0-2][0-9]:[0-5][0-9]'), -- End time in HH:MM format
+    room TEXT, -- Room number or name
+    teacher_id INTEGER NOT NULL, -- Teacher ID
+    class_name TEXT NOT NULL, -- Class name
+    term_id INTEGER REFERENCES terms(id), -- Term the entry applies to, NULL for every term
+    FOREIGN KEY(class_name) REFERENCES classes(name),
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id)
+);
+INSERT INTO timetable_new (id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name, term_id)
+    SELECT id, day, subject_id, class_period,
+        COALESCE(time_start, (SELECT bell_periods.time_start FROM bell_periods JOIN bell_schedules ON bell_schedules.id = bell_periods.schedule_id
+            WHERE bell_schedules.is_default = 1 AND bell_periods.class_period = timetable.class_period), '00:00'),
+        COALESCE(time_end, (SELECT bell_periods.time_end FROM bell_periods JOIN bell_schedules ON bell_schedules.id = bell_periods.schedule_id
+            WHERE bell_schedules.is_default = 1 AND bell_periods.class_period = timetable.class_period), '00:00'),
+        room, teacher_id, class_name, term_id FROM timetable;
+DROP TABLE timetable;
+ALTER TABLE timetable_new RENAME TO timetable;
+
+CREATE INDEX IF NOT EXISTS idx_timetable_teacher_id ON timetable(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_timetable_class_name ON timetable(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_subject_id ON timetable(subject_id);
+
+DROP TABLE IF EXISTS bell_schedule_dates;
+DROP TABLE IF EXISTS bell_periods;
+DROP INDEX IF EXISTS idx_bell_schedules_default;
+DROP TABLE IF EXISTS bell_schedules;
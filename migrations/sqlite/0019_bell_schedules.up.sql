@@ -0,0 +1,69 @@
+-- Table storing bell schedules; the default one applies unless a date is assigned another (e.g., shortened days)
+CREATE TABLE IF NOT EXISTS bell_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE, -- Schedule name (e.g., "Standard", "Shortened lessons")
+    is_default INTEGER NOT NULL DEFAULT 0 CHECK(is_default IN (0, 1)) -- 1 for the schedule used on ordinary days
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bell_schedules_default ON bell_schedules(is_default) WHERE is_default = 1;
+
+-- Table storing the start and end times of each class period in a bell schedule
+CREATE TABLE IF NOT EXISTS bell_periods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER NOT NULL, -- Bell schedule ID
+    class_period INTEGER NOT NULL CHECK(class_period > 0), -- Class period number
+    time_start TEXT NOT NULL CHECK(time_start GLOB '[0-2][0-9]:[0-5][0-9]'), -- Start time in HH:MM format
+    time_end TEXT NOT NULL CHECK(time_end GLOB '[0-2][0-9]:[0-5][0-9]'), -- End time in HH:MM format
+    CHECK(time_end > time_start),
+    UNIQUE(schedule_id, class_period),
+    FOREIGN KEY(schedule_id) REFERENCES bell_schedules(id)
+);
+
+-- Table storing the dates on which a bell schedule other than the default one applies
+CREATE TABLE IF NOT EXISTS bell_schedule_dates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER NOT NULL, -- Bell schedule ID
+    start_date TEXT NOT NULL CHECK(start_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- First day in YYYY-MM-DD format
+    end_date TEXT NOT NULL CHECK(end_date GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'), -- Last day in YYYY-MM-DD format
+    CHECK(end_date >= start_date),
+    FOREIGN KEY(schedule_id) REFERENCES bell_schedules(id)
+);
+
+-- Timetable entries take their times from the bell schedule; time_start and time_end are kept only for entries with their own times
+CREATE TABLE timetable_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    day TEXT NOT NULL CHECK(day IN ('Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday')), -- Day of the week
+    subject_id INTEGER NOT NULL, -- Subject ID
+    class_period INTEGER NOT NULL, -- Class period (number)
+    time_start TEXT CHECK(time_start GLOB '[0-2][0-9]:[0-5][0-9]'), -- Own start time in HH:MM format, NULL for the bell schedule
+    time_end TEXT CHECK(time_end GLOB '[0-2][0-9]:[0-5][0-9]'), -- Own end time in HH:MM format, NULL for the bell schedule
+    room TEXT, -- Room number or name
+    teacher_id INTEGER NOT NULL, -- Teacher ID
+    class_name TEXT NOT NULL, -- Class name
+    term_id INTEGER REFERENCES terms(id), -- Term the entry applies to, NULL for every term
+    FOREIGN KEY(class_name) REFERENCES classes(name),
+    FOREIGN KEY(teacher_id) REFERENCES users(uid),
+    FOREIGN KEY(subject_id) REFERENCES subjects(id)
+);
+INSERT INTO timetable_new (id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name, term_id)
+    SELECT id, day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name, term_id FROM timetable;
+DROP TABLE timetable;
+ALTER TABLE timetable_new RENAME TO timetable;
+
+CREATE INDEX IF NOT EXISTS idx_timetable_teacher_id ON timetable(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_timetable_class_name ON timetable(class_name);
+CREATE INDEX IF NOT EXISTS idx_timetable_subject_id ON timetable(subject_id);
+
+-- The default schedule is built from the most common times of each period in the existing timetable
+INSERT INTO bell_schedules (name, is_default)
+    SELECT 'Standard', 1 WHERE EXISTS (SELECT 1 FROM timetable WHERE class_period > 0 AND time_end > time_start);
+INSERT INTO bell_periods (schedule_id, class_period, time_start, time_end)
+    SELECT (SELECT id FROM bell_schedules WHERE is_default = 1), class_period, time_start, time_end FROM (
+        SELECT class_period, time_start, time_end,
+            ROW_NUMBER() OVER (PARTITION BY class_period ORDER BY COUNT(*) DESC, time_start, time_end) AS position
+        FROM timetable WHERE class_period > 0 AND time_end > time_start
+        GROUP BY class_period, time_start, time_end
+    ) AS common WHERE position = 1;
+UPDATE timetable SET time_start = NULL, time_end = NULL
+    WHERE EXISTS (SELECT 1 FROM bell_periods WHERE bell_periods.class_period = timetable.class_period
+        AND bell_periods.time_start = timetable.time_start AND bell_periods.time_end = timetable.time_end);
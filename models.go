@@ -1,15 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// User represents a user in the system (students, teachers, admins)
+// User represents a user in the system (students, teachers, admins, parents)
 type User struct {
 	UID      uint   `json:"uid"`
 	Email    string `json:"email"`
 	Password string `json:"password"` // User password, excluded from JSON
-	Role     string `json:"role"`     // User role: "student", "teacher", or "admin"
+	Role     string `json:"role"`     // User role: "student", "teacher", "admin", or "parent"
 }
 
 // Person represents personal information for a user
@@ -25,16 +27,18 @@ type Person struct {
 
 // Class represents a school class (group of students)
 type Class struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"` // Unique class name (e.g., "1A", "2B")
+	ID                uint   `json:"id"`
+	Name              string `json:"name"`                // Unique class name (e.g., "1A", "2B")
+	HomeroomTeacherID *uint  `json:"homeroom_teacher_id"` // Reference to users(uid), the teacher approving the class's term grades
 }
 
 // Subject represents a school subject
 type Subject struct {
-	ID        uint   `json:"id"`
-	Name      string `json:"name"`       // Unique subject name (e.g., "Mathematics")
-	ClassName string `json:"class_name"` // Reference to classes(name)
-	TeacherID uint   `json:"teacher_id"` // Reference to users(uid)
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`             // Unique subject name (e.g., "Mathematics")
+	ClassName      string `json:"class_name"`       // Reference to classes(name)
+	TeacherID      uint   `json:"teacher_id"`       // Reference to users(uid)
+	GradingScaleID *uint  `json:"grading_scale_id"` // Reference to grading_scales(id), null to use the default scale
 }
 
 // StudentSubject represents a student-subject assignment
@@ -56,16 +60,101 @@ type Grade struct {
 	ID        uint   `json:"id"`
 	UserID    uint   `json:"user_id"`    // Reference to users(uid)
 	SubjectID uint   `json:"subject_id"` // Reference to subjects(id)
+	TeacherID uint   `json:"teacher_id"` // Reference to users(uid) of the teacher who entered the grade
 	Grade     string `json:"grade"`      // Numeric grade, comment, or custom value
 	GradeType string `json:"grade_type"` // Type: "numeric", "comment", or "custom"
+	Weight    int    `json:"weight"`     // Weight in averages (e.g., 1 for homework, 2 for exam), 1 when omitted
 	Date      string `json:"date"`       // Date of entry in YYYY-MM-DD format
 }
 
+// GradeChange represents a request to edit or delete a grade
+type GradeChange struct {
+	Grade     string `json:"grade"`      // New value, for edits
+	GradeType string `json:"grade_type"` // New type, for edits
+	Weight    int    `json:"weight"`     // New weight, for edits; the current one is kept when omitted
+	Date      string `json:"date"`       // New date of entry, for edits
+	Reason    string `json:"reason"`     // Why the grade is changed, required
+}
+
+// GradeRevision represents the value a grade had before it was edited or deleted
+type GradeRevision struct {
+	ID        uint   `json:"id"`
+	GradeID   uint   `json:"grade_id"`   // Reference to grades(id), the grade may no longer exist
+	Action    string `json:"action"`     // Change made: "update" or "delete"
+	UserID    uint   `json:"user_id"`    // Student before the change
+	SubjectID uint   `json:"subject_id"` // Subject before the change
+	TeacherID uint   `json:"teacher_id"` // Teacher before the change
+	Grade     string `json:"grade"`      // Value before the change
+	GradeType string `json:"grade_type"` // Type before the change
+	Weight    int    `json:"weight"`     // Weight before the change
+	Date      string `json:"date"`       // Date of entry before the change
+	EditorID  uint   `json:"editor_id"`  // Reference to users(uid) of the teacher or admin who made the change
+	Reason    string `json:"reason"`     // Reason given for the change
+	CreatedAt string `json:"created_at"` // Time of the change (RFC 3339)
+}
+
+// SubjectAverage represents a student's weighted grade average in one subject
+type SubjectAverage struct {
+	SubjectID   uint    `json:"subject_id"`   // Reference to subjects(id)
+	Average     float64 `json:"average"`      // Weighted mean, rounded to two decimals
+	Count       int     `json:"count"`        // Number of grades counted
+	TotalWeight int     `json:"total_weight"` // Sum of the weights of the counted grades
+	Lowest      float64 `json:"lowest"`       // Lowest counted grade
+	Highest     float64 `json:"highest"`      // Highest counted grade
+}
+
+// GradeAverages represents a student's weighted grade averages
+type GradeAverages struct {
+	UserID   uint             `json:"user_id"`  // Reference to users(uid) of the student
+	Subjects []SubjectAverage `json:"subjects"` // Per subject, ordered by subject ID
+	Overall  *float64         `json:"overall"`  // Weighted mean over all counted grades, null when there are none or the subjects use different scales
+}
+
+// GradingScale represents a set of accepted grades and their numeric equivalents
+type GradingScale struct {
+	ID        uint                `json:"id"`
+	Name      string              `json:"name"`       // Unique scale name (e.g., "Polish 1-6")
+	Kind      string              `json:"kind"`       // "list" (fixed values) or "range" (any number between MinValue and MaxValue)
+	MinValue  *float64            `json:"min_value"`  // Lowest value of a "range" scale
+	MaxValue  *float64            `json:"max_value"`  // Highest value of a "range" scale
+	IsDefault bool                `json:"is_default"` // Used by subjects without their own scale
+	Values    []GradingScaleValue `json:"values"`     // Accepted values of a "list" scale
+}
+
+// GradingScaleValue represents one accepted value of a "list" grading scale
+type GradingScaleValue struct {
+	Value        string   `json:"value"`         // Grade as entered (e.g., "4+", "A", "pass")
+	NumericValue *float64 `json:"numeric_value"` // Equivalent used in averages, null when not averaged
+}
+
+// GradingScaleAssignment represents a request to pick the grading scale of a subject or of the school
+type GradingScaleAssignment struct {
+	GradingScaleID *uint `json:"grading_scale_id"` // Reference to grading_scales(id), null to clear
+}
+
+// Guardian represents a link between a parent/guardian and a student
+type Guardian struct {
+	ID           uint   `json:"id"`
+	GuardianID   uint   `json:"guardian_id"`            // Reference to users(uid) with role "parent"
+	StudentID    uint   `json:"student_id"`             // Reference to users(uid) with role "student"
+	Relationship string `json:"relationship,omitempty"` // Relationship to the student (e.g., "mother", "legal guardian")
+}
+
+// LinkedStudent represents a student as seen by one of their guardians
+type LinkedStudent struct {
+	StudentID    uint   `json:"student_id"`             // Reference to users(uid)
+	FirstName    string `json:"first_name"`             // First name
+	LastName     string `json:"last_name"`              // Last name
+	ClassName    string `json:"class_name,omitempty"`   // Reference to classes(name)
+	Relationship string `json:"relationship,omitempty"` // Relationship to the student
+}
+
 // ClassMember represents a user (student or teacher) assigned to a class
 type ClassMember struct {
-	ID        uint   `json:"id"`
-	UserID    uint   `json:"user_id"`    // Reference to users(uid)
-	ClassName string `json:"class_name"` // Reference to classes(name)
+	ID             uint   `json:"id"`
+	UserID         uint   `json:"user_id"`          // Reference to users(uid)
+	ClassName      string `json:"class_name"`       // Reference to classes(name)
+	AcademicYearID *uint  `json:"academic_year_id"` // Reference to academic_years(id), the current year when omitted
 }
 
 // TimetableEntry represents a single timetable entry
@@ -74,11 +163,46 @@ type TimetableEntry struct {
 	Day         string `json:"day"`          // Day of the week (e.g., "Monday")
 	SubjectID   uint   `json:"subject_id"`   // Reference to subjects(id)
 	ClassPeriod uint   `json:"class_period"` // Class period number (e.g., 1, 2, 3)
-	StartTime   string `json:"start_time"`   // Start time in HH:MM format
-	EndTime     string `json:"end_time"`     // End time in HH:MM format
+	StartTime   string `json:"start_time"`   // Start time in HH:MM format, from the default bell schedule unless the entry has its own
+	EndTime     string `json:"end_time"`     // End time in HH:MM format, from the default bell schedule unless the entry has its own
+	CustomTimes bool   `json:"custom_times"` // The entry keeps its own times instead of following the bell schedule
 	Room        string `json:"room"`         // Room number or name
 	TeacherID   uint   `json:"teacher_id"`   // Reference to users(uid)
 	ClassName   string `json:"class_name"`   // Reference to classes(name)
+	TermID      *uint  `json:"term_id"`      // Reference to terms(id), null for every term
+}
+
+// TimetableConflict represents an existing timetable entry that a new or edited entry would clash with
+type TimetableConflict struct {
+	Entry   TimetableEntry `json:"entry"`   // The clashing entry
+	Reasons []string       `json:"reasons"` // What both entries would book at once: "teacher", "room" or "class"
+}
+
+// TimetableOverride represents a change to one lesson of the weekly timetable on a single date
+type TimetableOverride struct {
+	ID                  uint    `json:"id"`
+	TimetableID         uint    `json:"timetable_id"`          // Reference to timetable(id)
+	Date                string  `json:"date"`                  // Date of the lesson in YYYY-MM-DD format, on the day of the entry
+	Cancelled           bool    `json:"cancelled"`             // The lesson does not take place
+	SubstituteTeacherID *uint   `json:"substitute_teacher_id"` // Reference to users(uid), null for the usual teacher
+	Room                *string `json:"room"`                  // Room the lesson is moved to, null for the usual room
+	ClassPeriod         *uint   `json:"class_period"`          // Period the lesson is moved to, null for the usual period
+	StartTime           *string `json:"start_time"`            // Start time the lesson is moved to in HH:MM format, null for the usual time
+	EndTime             *string `json:"end_time"`              // End time the lesson is moved to in HH:MM format, null for the usual time
+	Note                string  `json:"note"`                  // Note shown with the change (e.g., "Teacher ill")
+	CreatedBy           uint    `json:"created_by"`            // Reference to users(uid), the admin who entered the change
+	CreatedAt           string  `json:"created_at"`            // Creation time in RFC 3339 format
+}
+
+// ScheduledLesson represents a timetable entry held on a date, with the override of that date applied
+type ScheduledLesson struct {
+	TimetableEntry
+	Date       string          `json:"date"`        // Date of the lesson in YYYY-MM-DD format
+	Cancelled  bool            `json:"cancelled"`   // The lesson does not take place, because of an override or a holiday
+	Changes    []string        `json:"changes"`     // What differs from the weekly timetable: "cancelled", "holiday", "teacher", "room", "time"
+	OverrideID *uint           `json:"override_id"` // Reference to timetable_overrides(id), null without an override
+	Note       string          `json:"note"`        // Note of the override, name of the holiday or of the bell schedule of the day
+	Original   *TimetableEntry `json:"original"`    // The entry as in the weekly timetable, null when the lesson is unchanged
 }
 
 // AccessRequest represents a login request
@@ -90,11 +214,82 @@ type AccessRequest struct {
 
 // Claims represents JWT claims for authentication
 type Claims struct {
-	Email string `json:"email"` // User email
-	Role  string `json:"role"`  // User role
+	Email     string `json:"email"`               // User email
+	Role      string `json:"role"`                // User role
+	SessionID uint   `json:"sid"`                 // Reference to sessions(id)
+	MFASetup  bool   `json:"mfa_setup,omitempty"` // Token only allows enrolling two-factor authentication
 	jwt.StandardClaims
 }
 
+// Session represents a login session backing a refresh token
+type Session struct {
+	ID        uint   `json:"id"`
+	UserID    uint   `json:"user_id"`              // Reference to users(uid)
+	CreatedAt string `json:"created_at"`           // Creation time in RFC 3339 format
+	ExpiresAt string `json:"expires_at"`           // Refresh token expiry in RFC 3339 format
+	RevokedAt string `json:"revoked_at,omitempty"` // Revocation time, empty while active
+	IP        string `json:"ip,omitempty"`         // Client IP at login
+	UserAgent string `json:"user_agent,omitempty"` // Client user agent at login
+}
+
+// RefreshRequest represents a token refresh request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"` // Refresh token issued at login
+}
+
+// TwoFactorRequest represents a second-factor verification, enrollment or removal request
+type TwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"` // Token returned by the password step of login
+	Code           string `json:"code"`            // 6-digit TOTP code
+	RecoveryCode   string `json:"recovery_code"`   // Single-use recovery code, used instead of a TOTP code
+	Password       string `json:"password"`        // Current password, required to disable two-factor authentication
+}
+
+// MFAPolicy represents whether two-factor authentication is mandatory for a role
+type MFAPolicy struct {
+	Role     string `json:"role"`     // User role
+	Required bool   `json:"required"` // Whether users with the role must enroll
+}
+
+// LockoutEvent represents a temporary login lockout of an account or client IP
+type LockoutEvent struct {
+	ID          uint   `json:"id"`
+	Scope       string `json:"scope"`                 // Locked subject type: "account" or "ip"
+	Subject     string `json:"subject"`               // Account e-mail or client IP
+	IP          string `json:"ip,omitempty"`          // Client IP of the attempt that triggered the lockout
+	Failures    int    `json:"failures"`              // Failed attempts counted at lockout time
+	LockedAt    string `json:"locked_at"`             // Lockout start in RFC 3339 format
+	LockedUntil string `json:"locked_until"`          // Lockout end in RFC 3339 format
+	UnlockedAt  string `json:"unlocked_at,omitempty"` // Time an admin lifted the lockout
+	UnlockedBy  uint   `json:"unlocked_by,omitempty"` // Reference to users(uid) of the admin
+}
+
+// UnlockRequest represents an admin request to lift a login lockout
+type UnlockRequest struct {
+	Email string `json:"email"` // Account e-mail to unlock
+	IP    string `json:"ip"`    // Client IP to unlock
+}
+
+// PasswordResetToken represents a single-use password reset token
+type PasswordResetToken struct {
+	ID        uint   `json:"id"`
+	UserID    uint   `json:"user_id"`           // Reference to users(uid)
+	CreatedAt string `json:"created_at"`        // Creation time in RFC 3339 format
+	ExpiresAt string `json:"expires_at"`        // Expiry time in RFC 3339 format
+	UsedAt    string `json:"used_at,omitempty"` // Time the token was used or superseded
+}
+
+// PasswordResetRequest represents a request to send a password reset e-mail
+type PasswordResetRequest struct {
+	Email string `json:"email"` // Account e-mail address
+}
+
+// PasswordResetConfirm represents a request to set a new password with a reset token
+type PasswordResetConfirm struct {
+	Token       string `json:"token"`        // Token received by e-mail
+	NewPassword string `json:"new_password"` // New password
+}
+
 // Input represents a password change request
 type Input struct {
 	OldPassword string `json:"old_password"` // Current password
@@ -102,11 +297,112 @@ type Input struct {
 }
 
 type Attendance struct {
-	ID        uint   `json:"id"`
-	UserID    uint   `json:"user_id"`    // Reference to users(uid)
-	SubjectID uint   `json:"subject_id"` // Reference to subjects(id)
-	Date      string `json:"date"`       // Date of attendance in YYYY-MM-DD format
-	Status    string `json:"status"`     // Attendance status: "present", "absent", or "late"
+	ID          uint    `json:"id"`
+	UserID      uint    `json:"user_id"`      // Reference to users(uid)
+	SubjectID   uint    `json:"subject_id"`   // Reference to subjects(id)
+	Date        string  `json:"date"`         // Date of attendance in YYYY-MM-DD format
+	Status      string  `json:"status"`       // Attendance status: "present", "absent", "late", or "excused"
+	ExcuseID    *uint   `json:"excuse_id"`    // Reference to excuses(id) of the approved excuse that justified the absence
+	ClassPeriod *uint   `json:"class_period"` // Lesson period of the record, null for records taken per day
+	TimetableID *uint   `json:"timetable_id"` // Reference to timetable(id) of the lesson, null when none is scheduled
+	StartTime   *string `json:"start_time"`   // Start time of the lesson in HH:MM format, from the timetable entry (read-only)
+	EndTime     *string `json:"end_time"`     // End time of the lesson in HH:MM format, from the timetable entry (read-only)
+}
+
+// LessonAttendance represents the attendance of a whole class taken for one lesson
+type LessonAttendance struct {
+	TimetableID *uint                    `json:"timetable_id"` // Reference to timetable(id) of the lesson; replaces subject ID and period
+	SubjectID   uint                     `json:"subject_id"`   // Reference to subjects(id), when no timetable entry is given
+	ClassPeriod uint                     `json:"class_period"` // Lesson period, when no timetable entry is given
+	Date        string                   `json:"date"`         // Date of the lesson in YYYY-MM-DD format
+	Records     []LessonAttendanceRecord `json:"records"`      // Statuses of students; class members left out are present
+}
+
+// LessonAttendanceRecord represents the status of one student in LessonAttendance
+type LessonAttendanceRecord struct {
+	UserID uint   `json:"user_id"` // Reference to users(uid)
+	Status string `json:"status"`  // "present", "absent", or "late"; absences covered by an approved excuse are stored as excused
+}
+
+// AttendanceCount is the number of attendance records of a student in a subject with one status
+type AttendanceCount struct {
+	UserID    uint   // Reference to users(uid)
+	SubjectID uint   // Reference to subjects(id)
+	Status    string // Attendance status
+	Count     int    // Number of records
+}
+
+// AttendanceRate summarises attendance records
+type AttendanceRate struct {
+	Lessons int      `json:"lessons"` // Number of recorded lessons
+	Present int      `json:"present"` // Lessons recorded as present
+	Late    int      `json:"late"`    // Lessons recorded as late
+	Absent  int      `json:"absent"`  // Lessons recorded as absent
+	Excused int      `json:"excused"` // Lessons recorded as excused
+	Percent *float64 `json:"percent"` // Share of lessons attended (present or late), null without records
+}
+
+// SubjectAttendanceRate represents attendance in one subject
+type SubjectAttendanceRate struct {
+	SubjectID uint           `json:"subject_id"` // Reference to subjects(id)
+	Name      string         `json:"name"`       // Subject name
+	Rate      AttendanceRate `json:"rate"`
+}
+
+// StudentAttendanceStats represents a student's attendance over a date range, in total and per subject
+type StudentAttendanceStats struct {
+	UserID    uint                    `json:"user_id"` // Reference to users(uid)
+	FirstName string                  `json:"first_name"`
+	LastName  string                  `json:"last_name"`
+	ClassName string                  `json:"class_name"` // Class of the student at the end of the range
+	From      *string                 `json:"from"`       // First day of the range in YYYY-MM-DD format, null for no limit
+	To        *string                 `json:"to"`         // Last day of the range in YYYY-MM-DD format, null for no limit
+	Rate      AttendanceRate          `json:"rate"`       // All lessons of the student
+	Subjects  []SubjectAttendanceRate `json:"subjects"`   // Lessons per subject, by name
+}
+
+// ClassAttendanceStats represents the attendance of a class over a date range
+type ClassAttendanceStats struct {
+	ClassName string                   `json:"class_name"` // Reference to classes(name)
+	From      *string                  `json:"from"`       // First day of the range in YYYY-MM-DD format, null for no limit
+	To        *string                  `json:"to"`         // Last day of the range in YYYY-MM-DD format, null for no limit
+	Rate      AttendanceRate           `json:"rate"`       // All lessons of the class's students
+	Subjects  []SubjectAttendanceRate  `json:"subjects"`   // Lessons of the class's students per subject, by name
+	Students  []StudentAttendanceStats `json:"students"`   // Each student's attendance, by last name
+}
+
+// AttendanceAlert flags a student whose attendance broke an attendance rule
+type AttendanceAlert struct {
+	UserID      uint           `json:"user_id"` // Reference to users(uid)
+	FirstName   string         `json:"first_name"`
+	LastName    string         `json:"last_name"`
+	ClassName   string         `json:"class_name"`   // Reference to classes(name)
+	Rule        string         `json:"rule"`         // Name of the broken rule
+	MinPercent  float64        `json:"min_percent"`  // Threshold of the rule
+	SubjectID   *uint          `json:"subject_id"`   // Subject below the threshold, null for rules over all lessons
+	SubjectName *string        `json:"subject_name"` // Name of that subject
+	Rate        AttendanceRate `json:"rate"`         // Attendance the rule was checked against
+}
+
+// Excuse represents a request to justify a student's absences in a date range
+type Excuse struct {
+	ID            uint    `json:"id"`
+	UserID        uint    `json:"user_id"`        // Reference to users(uid) of the student
+	SubmittedBy   uint    `json:"submitted_by"`   // Reference to users(uid) of the student or parent who submitted it
+	Reason        string  `json:"reason"`         // Reason given for the absence
+	StartDate     string  `json:"start_date"`     // First excused day in YYYY-MM-DD format
+	EndDate       string  `json:"end_date"`       // Last excused day in YYYY-MM-DD format
+	Status        string  `json:"status"`         // "pending", "approved" or "rejected"
+	CreatedAt     string  `json:"created_at"`     // Submission time (RFC 3339)
+	ReviewedBy    *uint   `json:"reviewed_by"`    // Reference to users(uid) of the reviewer, null while pending
+	ReviewedAt    *string `json:"reviewed_at"`    // Review time (RFC 3339), null while pending
+	ReviewComment *string `json:"review_comment"` // Optional comment of the reviewer
+}
+
+// ExcuseReview represents the decision on a pending excuse
+type ExcuseReview struct {
+	Status  string  `json:"status"`  // "approved" or "rejected"
+	Comment *string `json:"comment"` // Optional comment shown to the student and parents
 }
 
 // Exam represents a exam or test
@@ -119,3 +415,230 @@ type Exam struct {
 	Type        string `json:"type"`        // Type of exam (e.g., "exam", "test", "quiz")
 	Description string `json:"description"` // Description of the exam
 }
+
+// AcademicYear represents a school year
+type AcademicYear struct {
+	ID         uint    `json:"id"`
+	Name       string  `json:"name"`        // Unique year name (e.g., "2026/2027")
+	StartDate  string  `json:"start_date"`  // First day in YYYY-MM-DD format
+	EndDate    string  `json:"end_date"`    // Last day in YYYY-MM-DD format
+	ArchivedAt *string `json:"archived_at"` // Time the year was archived by the rollover (RFC 3339), null while open
+	Terms      []Term  `json:"terms"`       // Terms of the year in date order
+}
+
+// Term represents a part of a school year, such as a semester
+type Term struct {
+	ID               uint    `json:"id"`
+	AcademicYearID   uint    `json:"academic_year_id"`  // Reference to academic_years(id)
+	Name             string  `json:"name"`              // Term name, unique within the year (e.g., "Semester 1")
+	StartDate        string  `json:"start_date"`        // First day in YYYY-MM-DD format
+	EndDate          string  `json:"end_date"`          // Last day in YYYY-MM-DD format
+	ProposalDeadline *string `json:"proposal_deadline"` // Last day to propose term grades in YYYY-MM-DD format, null for none
+}
+
+// BellSchedule represents the times of the class periods of a school day
+type BellSchedule struct {
+	ID        uint                `json:"id"`
+	Name      string              `json:"name"`       // Unique schedule name (e.g., "Standard", "Shortened lessons")
+	IsDefault bool                `json:"is_default"` // Used on every day not assigned another schedule
+	Periods   []BellPeriod        `json:"periods"`    // Class periods in period order
+	Dates     []BellScheduleDates `json:"dates"`      // Days the schedule is used on instead of the default one
+}
+
+// BellPeriod represents the times of one class period in a bell schedule
+type BellPeriod struct {
+	ClassPeriod uint   `json:"class_period"` // Class period number (e.g., 1, 2, 3)
+	StartTime   string `json:"start_time"`   // Start time in HH:MM format
+	EndTime     string `json:"end_time"`     // End time in HH:MM format
+}
+
+// BellScheduleDates represents days on which a bell schedule replaces the default one, such as shortened days
+type BellScheduleDates struct {
+	ID         uint   `json:"id"`
+	ScheduleID uint   `json:"schedule_id"` // Reference to bell_schedules(id)
+	StartDate  string `json:"start_date"`  // First day in YYYY-MM-DD format
+	EndDate    string `json:"end_date"`    // Last day in YYYY-MM-DD format, the start date for a single day
+}
+
+// BellScheduleAssignment represents a request to pick the default bell schedule
+type BellScheduleAssignment struct {
+	BellScheduleID uint `json:"bell_schedule_id"` // Reference to bell_schedules(id)
+}
+
+// Room represents a room lessons can be held in
+type Room struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`      // Unique room number or name, as written in timetable entries
+	Capacity uint   `json:"capacity"`  // Number of students the room holds
+	RoomType string `json:"room_type"` // Kind of room (e.g., "lab", "gym"), empty for an ordinary classroom
+}
+
+// TeachingRequirement represents how many lessons of a subject its class has each week
+type TeachingRequirement struct {
+	ID           uint   `json:"id"`
+	SubjectID    uint   `json:"subject_id"`     // Reference to subjects(id), which gives the class and the teacher
+	HoursPerWeek uint   `json:"hours_per_week"` // Lessons per week
+	MaxPerDay    *uint  `json:"max_per_day"`    // Most lessons of the subject on one day, null to spread them evenly over the week
+	RoomType     string `json:"room_type"`      // Kind of room the lessons need, empty for any room
+}
+
+// TeacherAvailability represents a period in which a teacher cannot, would rather not or would rather teach
+type TeacherAvailability struct {
+	ID          uint   `json:"id"`
+	TeacherID   uint   `json:"teacher_id"`   // Reference to users(uid)
+	Day         string `json:"day"`          // Day of the week (e.g., "Monday")
+	ClassPeriod uint   `json:"class_period"` // Class period number, 0 for the whole day
+	Preference  string `json:"preference"`   // "unavailable", "avoid" or "prefer"
+}
+
+// TimetableGeneration represents a request to draft the timetable of a term from the teaching requirements
+type TimetableGeneration struct {
+	TermID           *uint    `json:"term_id"`             // Reference to terms(id), the current term when omitted
+	ClassNames       []string `json:"class_names"`         // Classes to schedule, every class with teaching requirements when omitted
+	Days             []string `json:"days"`                // Days lessons are held on, Monday to Friday when omitted
+	MaxLessonsPerDay uint     `json:"max_lessons_per_day"` // Most lessons a class has on one day, every period of the default bell schedule when omitted
+	AllowGaps        bool     `json:"allow_gaps"`          // Whether students may have free periods between lessons
+}
+
+// TimetableDraft represents a timetable drafted by the generator, reviewed by admins before it is published
+type TimetableDraft struct {
+	ID               uint                  `json:"id"`
+	TermID           uint                  `json:"term_id"`             // Reference to terms(id)
+	Days             []string              `json:"days"`                // Days lessons are held on
+	MaxLessonsPerDay uint                  `json:"max_lessons_per_day"` // Most lessons a class has on one day
+	AllowGaps        bool                  `json:"allow_gaps"`          // Whether students may have free periods between lessons
+	CreatedBy        uint                  `json:"created_by"`          // Reference to users(uid), the admin who generated the draft
+	CreatedAt        string                `json:"created_at"`          // Creation time (RFC 3339)
+	PublishedBy      *uint                 `json:"published_by"`        // Reference to users(uid), null while unpublished
+	PublishedAt      *string               `json:"published_at"`        // Publication time (RFC 3339), null while unpublished
+	Entries          []TimetableDraftEntry `json:"entries,omitempty"`   // Drafted lessons, the unplaced ones without day and period
+	Violations       []DraftViolation      `json:"violations"`          // Constraints the draft breaks, hard ones block publishing; null in lists and once published
+}
+
+// TimetableDraftEntry represents a lesson of a drafted timetable
+type TimetableDraftEntry struct {
+	ID          uint   `json:"id"`
+	DraftID     uint   `json:"draft_id"`     // Reference to timetable_drafts(id)
+	SubjectID   uint   `json:"subject_id"`   // Reference to subjects(id)
+	Day         string `json:"day"`          // Day of the week, empty for a lesson the generator could not place
+	ClassPeriod uint   `json:"class_period"` // Class period number, 0 for a lesson the generator could not place
+	Room        string `json:"room"`         // Room number or name, empty when no rooms are set up
+	TeacherID   uint   `json:"teacher_id"`   // Reference to users(uid)
+	ClassName   string `json:"class_name"`   // Reference to classes(name)
+}
+
+// DraftViolation represents a constraint broken by a drafted timetable
+type DraftViolation struct {
+	Constraint string `json:"constraint"` // "unplaced", "conflict", "gap", "teacher_avoid" or "teacher_prefer"
+	Hard       bool   `json:"hard"`       // Whether the draft cannot be published until it is resolved
+	EntryID    *uint  `json:"entry_id"`   // Reference to timetable_draft_entries(id), null for a class's day or a teacher's preferred period
+	Message    string `json:"message"`    // Description of the violation
+}
+
+// Holiday represents days without lessons, such as a public holiday or a school break
+type Holiday struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`       // Holiday name (e.g., "Christmas break")
+	StartDate string `json:"start_date"` // First day off in YYYY-MM-DD format
+	EndDate   string `json:"end_date"`   // Last day off in YYYY-MM-DD format, the start date for a single day
+}
+
+// TermGrade represents the proposed and final grade of a student in a subject for a term
+type TermGrade struct {
+	ID            uint    `json:"id"`
+	UserID        uint    `json:"user_id"`        // Reference to users(uid), the student
+	SubjectID     uint    `json:"subject_id"`     // Reference to subjects(id)
+	TermID        uint    `json:"term_id"`        // Reference to terms(id), the current term when omitted
+	ProposedGrade *string `json:"proposed_grade"` // Grade proposed before the term's deadline
+	ProposedAt    *string `json:"proposed_at"`    // Time of the proposal (RFC 3339)
+	FinalGrade    *string `json:"final_grade"`    // Final grade, shown to students and parents once approved
+	TeacherID     uint    `json:"teacher_id"`     // Reference to users(uid), the teacher who last changed the grades
+	UpdatedAt     string  `json:"updated_at"`     // Time of the last change (RFC 3339)
+	ApprovedBy    *uint   `json:"approved_by"`    // Reference to users(uid), the homeroom teacher or admin who approved it
+	ApprovedAt    *string `json:"approved_at"`    // Time of approval (RFC 3339); approved grades are locked
+}
+
+// TermGradeApproval represents the approval of a class's final term grades
+type TermGradeApproval struct {
+	ClassName string `json:"class_name"` // Reference to classes(name)
+	TermID    *uint  `json:"term_id"`    // Reference to terms(id), the current term when omitted
+	SubjectID *uint  `json:"subject_id"` // Reference to subjects(id), every subject when omitted
+}
+
+// ReportCard represents the data printed on a student's end-of-year report card
+type ReportCard struct {
+	Student           Person              // Personal details of the student
+	ClassName         string              // Class of the student in the year, empty when unknown
+	Year              AcademicYear        // School year the card covers
+	Subjects          []ReportCardSubject // Grades per subject, ordered by name
+	AttendancePercent *float64            // Share of attended lessons in percent, nil without attendance records
+	BehaviorNotes     []Grade             // Behavior notes entered during the year, oldest first
+}
+
+// ReportCardSubject represents the grades of one subject on a report card
+type ReportCardSubject struct {
+	Name       string   // Subject name
+	Average    *float64 // Weighted average of the year's grades, nil when there are none
+	FinalGrade *string  // Approved final grade of the year's last term, nil when not yet approved
+}
+
+// RolloverRequest represents an end-of-year promotion of students into the next school year
+type RolloverRequest struct {
+	FromYearID   uint               `json:"from_year_id"`  // Reference to academic_years(id), the year being archived
+	ToYearID     uint               `json:"to_year_id"`    // Reference to academic_years(id), the year students move into
+	MaxLevel     int                `json:"max_level"`     // Highest class level; classes promoted past it graduate (0 for no limit)
+	ClassMap     map[string]string  `json:"class_map"`     // Next class per class, overriding the naming rule ("" graduates the class)
+	Overrides    []RolloverOverride `json:"overrides"`     // Per-student exceptions to the class moves
+	CopySubjects bool               `json:"copy_subjects"` // Whether to draft next-year subject/teacher assignments
+	DryRun       bool               `json:"dry_run"`       // Whether to only return the plan without changing anything
+}
+
+// RolloverOverride represents a per-student exception to the rollover
+type RolloverOverride struct {
+	UserID    uint   `json:"user_id"`    // Reference to users(uid)
+	Action    string `json:"action"`     // "repeat", "leave" or "move"
+	ClassName string `json:"class_name"` // Target class for "move"
+}
+
+// RolloverMove represents where the rollover puts one student
+type RolloverMove struct {
+	UserID    uint   `json:"user_id"`    // Reference to users(uid)
+	FromClass string `json:"from_class"` // Class in the finished year
+	ToClass   string `json:"to_class"`   // Class in the next year, empty when the student leaves
+	Action    string `json:"action"`     // "promote", "repeat", "move", "leave" or "graduate"
+}
+
+// RolloverPlan represents the changes made by a rollover
+type RolloverPlan struct {
+	FromYearID    uint           `json:"from_year_id"`
+	ToYearID      uint           `json:"to_year_id"`
+	NewClasses    []string       `json:"new_classes"`    // Classes created for the next year
+	Moves         []RolloverMove `json:"moves"`          // One entry per student membership of the finished year
+	SubjectDrafts []SubjectDraft `json:"subject_drafts"` // Drafted subject/teacher assignments
+	Summary       map[string]int `json:"summary"`        // Number of moves per action
+}
+
+// SubjectDraft represents a subject/teacher assignment proposed for the next school year
+type SubjectDraft struct {
+	ID              uint   `json:"id"`
+	AcademicYearID  uint   `json:"academic_year_id"`  // Reference to academic_years(id)
+	SourceSubjectID *uint  `json:"source_subject_id"` // Reference to subjects(id) it was copied from
+	Name            string `json:"name"`              // Subject name, unique within the year
+	ClassName       string `json:"class_name"`        // Reference to classes(name)
+	TeacherID       uint   `json:"teacher_id"`        // Reference to users(uid)
+	GradingScaleID  *uint  `json:"grading_scale_id"`  // Reference to grading_scales(id)
+}
+
+// AuditEntry represents a recorded write operation
+type AuditEntry struct {
+	ID         uint            `json:"id"`
+	ActorID    uint            `json:"actor_id"`         // Reference to users(uid), 0 if unknown
+	ActorRole  string          `json:"actor_role"`       // Role of the actor at the time of the change
+	Action     string          `json:"action"`           // Action performed (e.g., "create", "delete")
+	EntityType string          `json:"entity_type"`      // Type of the changed entity (e.g., "grade")
+	EntityID   string          `json:"entity_id"`        // ID of the changed entity
+	Before     json.RawMessage `json:"before,omitempty"` // Entity state before the change
+	After      json.RawMessage `json:"after,omitempty"`  // Entity state after the change
+	IP         string          `json:"ip"`               // Client IP address
+	CreatedAt  string          `json:"created_at"`       // Time of the change (RFC 3339)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AddGuardian(c *gin.Context) {
+	var guardian Guardian
+	if err := c.ShouldBindJSON(&guardian); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if guardian.GuardianID == 0 || guardian.StudentID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Guardian ID and student ID are required"})
+		return
+	}
+
+	var guardianRole, studentRole string
+	err := db.QueryRow("SELECT role FROM users WHERE uid = ?", guardian.GuardianID).Scan(&guardianRole)
+	if err != nil || guardianRole != "parent" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Guardian must be a user with the parent role"})
+		return
+	}
+	err = db.QueryRow("SELECT role FROM users WHERE uid = ?", guardian.StudentID).Scan(&studentRole)
+	if err != nil || studentRole != "student" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Student must be a user with the student role"})
+		return
+	}
+
+	id, err := db.InsertID("id", "INSERT INTO guardians (guardian_id, student_id, relationship) VALUES (?, ?, ?)", guardian.GuardianID, guardian.StudentID, guardian.Relationship)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	guardian.ID = uint(id)
+	recordAudit(c, "create", "guardian", guardian.ID, nil, guardian)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Guardian linked successfully"})
+}
+
+func RemoveGuardian(c *gin.Context) {
+	var guardian Guardian
+	if err := c.ShouldBindJSON(&guardian); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if guardian.GuardianID == 0 || guardian.StudentID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Guardian ID and student ID are required"})
+		return
+	}
+
+	var relationship sql.NullString
+	err := db.QueryRow("SELECT id, relationship FROM guardians WHERE guardian_id = ? AND student_id = ?", guardian.GuardianID, guardian.StudentID).
+		Scan(&guardian.ID, &relationship)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Guardian link not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving guardian"})
+		return
+	}
+	guardian.Relationship = relationship.String
+
+	result, err := db.Exec("DELETE FROM guardians WHERE id = ?", guardian.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error removing guardian"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Guardian link not found"})
+		return
+	}
+
+	recordAudit(c, "delete", "guardian", guardian.ID, guardian, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Guardian unlinked successfully"})
+}
+
+func GetGuardians(c *gin.Context) {
+	query := "SELECT id, guardian_id, student_id, relationship FROM guardians"
+	var args []interface{}
+	if guardianID := c.Query("guardian_id"); guardianID != "" {
+		query += " WHERE guardian_id = ?"
+		args = append(args, guardianID)
+	} else if studentID := c.Query("student_id"); studentID != "" {
+		query += " WHERE student_id = ?"
+		args = append(args, studentID)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving guardians"})
+		return
+	}
+	defer rows.Close()
+
+	var guardians []Guardian
+	for rows.Next() {
+		var guardian Guardian
+		var relationship sql.NullString
+		if err := rows.Scan(&guardian.ID, &guardian.GuardianID, &guardian.StudentID, &relationship); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning guardian"})
+			return
+		}
+		guardian.Relationship = relationship.String
+		guardians = append(guardians, guardian)
+	}
+
+	c.JSON(http.StatusOK, guardians)
+}
+
+func GetChildren(c *gin.Context) {
+	email, _ := c.Get("email")
+	var user User
+	err := db.QueryRow("SELECT uid FROM users WHERE email = ?", email).Scan(&user.UID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	rows, err := db.Query(`SELECT guardians.student_id, persons.first_name, persons.last_name, guardians.relationship
+		FROM guardians
+		INNER JOIN persons ON persons.user_id = guardians.student_id
+		WHERE guardians.guardian_id = ?`, user.UID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving children"})
+		return
+	}
+	defer rows.Close()
+
+	var children []LinkedStudent
+	for rows.Next() {
+		var child LinkedStudent
+		var relationship sql.NullString
+		if err := rows.Scan(&child.StudentID, &child.FirstName, &child.LastName, &relationship); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error scanning child"})
+			return
+		}
+		child.Relationship = relationship.String
+		children = append(children, child)
+	}
+	rows.Close()
+
+	// A student has a class per school year; the current one is shown
+	for i := range children {
+		className, err := store.Classes.ClassOf(children[i].StudentID, today())
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+			return
+		}
+		children[i].ClassName = className
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
+// linkedStudentID resolves the :student_id path parameter and checks it belongs to the logged-in parent.
+// It writes the error response itself and returns false when access is denied.
+func linkedStudentID(c *gin.Context) (uint, bool) {
+	studentID, err := strconv.ParseUint(c.Param("student_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid student ID"})
+		return 0, false
+	}
+
+	email, _ := c.Get("email")
+	var linkID uint
+	err = db.QueryRow("SELECT guardians.id FROM guardians INNER JOIN users ON users.uid = guardians.guardian_id WHERE users.email = ? AND guardians.student_id = ?",
+		email, studentID).Scan(&linkID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return 0, false
+	}
+	return uint(studentID), true
+}
+
+func GetChildGrades(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	grades, err := store.Grades.ListByStudent(studentID, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving grades"})
+		return
+	}
+	c.JSON(http.StatusOK, grades)
+}
+
+func GetChildAttendance(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	attendance, err := store.Attendance.ListByStudent(studentID, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving attendance"})
+		return
+	}
+	c.JSON(http.StatusOK, attendance)
+}
+
+func GetChildExams(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	className, err := store.Classes.ClassOf(studentID, today())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Student is not assigned to a class"})
+		return
+	}
+	exams, err := store.Exams.ListByClass(className, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving exams"})
+		return
+	}
+	c.JSON(http.StatusOK, exams)
+}
+
+func GetChildTimetable(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	week, ok := weekParam(c)
+	if !ok {
+		return
+	}
+	if week != nil {
+		term = nil
+	}
+	className, err := store.Classes.ClassOf(studentID, today())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Student is not assigned to a class"})
+		return
+	}
+	timetable, err := store.Timetable.ListByClass(className, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+		return
+	}
+	if week != nil {
+		lessons, err := weekTimetable(timetable, *week, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+			return
+		}
+		c.JSON(http.StatusOK, lessons)
+		return
+	}
+	c.JSON(http.StatusOK, timetable)
+}
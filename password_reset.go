@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	mailer                     Mailer
+	passwordResetTTL           = time.Hour
+	passwordResetURL           string      // Link template with a %s placeholder for the token, empty to send the bare token
+	passwordResetMaxPerAccount = 3         // Reset requests per address before further ones are refused
+	passwordResetMaxPerIP      = 10        // Reset requests per client IP before further ones are refused
+	passwordResetCooldown      = time.Hour // How long further requests are refused once a limit is reached
+)
+
+// passwordResetBody renders the e-mail sent to a user who asked for a password reset
+func passwordResetBody(token string) string {
+	var b strings.Builder
+	b.WriteString("A password reset was requested for your Mercury account.\n\n")
+	if passwordResetURL != "" {
+		fmt.Fprintf(&b, "Open the following link to choose a new password:\n%s\n\n", fmt.Sprintf(passwordResetURL, token))
+	} else {
+		fmt.Fprintf(&b, "Use the following code to choose a new password:\n%s\n\n", token)
+	}
+	fmt.Fprintf(&b, "The code expires in %s and can be used only once. If you did not request a reset, ignore this message.\n", passwordResetTTL)
+	return b.String()
+}
+
+// allowPasswordReset counts a reset request against the client IP and the address and returns how long
+// the caller must wait once either has used up its allowance, 0 if the request may proceed.
+// The counters live in login_throttle under the reset_ip and reset_account scopes.
+func allowPasswordReset(ip, email string) (time.Duration, error) {
+	now := time.Now()
+	limits := []struct {
+		scope, subject string
+		max            int
+	}{
+		{"reset_ip", ip, passwordResetMaxPerIP},
+		{"reset_account", email, passwordResetMaxPerAccount},
+	}
+
+	for _, limit := range limits {
+		t, err := getLoginThrottle(limit.scope, limit.subject)
+		if err != nil {
+			return 0, err
+		}
+		if now.Before(t.lockedUntil) {
+			return t.lockedUntil.Sub(now), nil
+		}
+	}
+
+	for _, limit := range limits {
+		requests, err := incrementThrottle(limit.scope, limit.subject, now)
+		if err != nil {
+			return 0, err
+		}
+		if requests < limit.max {
+			continue
+		}
+		if _, err := lockThrottle(limit.scope, limit.subject, now, now.Add(passwordResetCooldown)); err != nil {
+			return 0, err
+		}
+		if requests > limit.max {
+			// Parallel requests used up the allowance after the check above
+			t, err := getLoginThrottle(limit.scope, limit.subject)
+			if err != nil {
+				return 0, err
+			}
+			return t.lockedUntil.Sub(now), nil
+		}
+	}
+	return 0, nil
+}
+
+func RequestPasswordReset(c *gin.Context) {
+	var request PasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	// Unknown addresses are counted too, so the limit reveals nothing about which accounts exist
+	wait, err := allowPasswordReset(c.ClientIP(), request.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking reset requests"})
+		return
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": "Too many password reset requests, try again later"})
+		return
+	}
+
+	// The token and e-mail are handled off the request path, so that neither the response
+	// nor its latency reveals whether the address belongs to an account
+	go sendPasswordReset(c.Copy(), request.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a password reset e-mail has been sent"})
+}
+
+// sendPasswordReset issues a reset token for the account with the given address, if any, and mails it.
+// It runs in the background, so failures are only logged.
+func sendPasswordReset(c *gin.Context, email string) {
+	var user User
+	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("password reset lookup for %s failed: %v", email, err)
+		}
+		return
+	}
+
+	token, err := generateToken(32)
+	if err != nil {
+		log.Printf("password reset token for %s failed: %v", user.Email, err)
+		return
+	}
+
+	now := time.Now()
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("password reset token for %s failed: %v", user.Email, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Only the most recently requested token stays valid
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL", formatTimestamp(now), user.UID)
+	if err == nil {
+		_, err = tx.Exec("INSERT INTO password_reset_tokens (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+			user.UID, hashToken(token), formatTimestamp(now), formatTimestamp(now.Add(passwordResetTTL)))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("password reset token for %s failed: %v", user.Email, err)
+		return
+	}
+	// Anyone can ask for a reset, so the requester is recorded only by IP
+	recordAuditAs(c, 0, "", "request_password_reset", "user", user.UID, nil, nil)
+
+	if err := mailer.Send(user.Email, "Mercury password reset", passwordResetBody(token)); err != nil {
+		log.Printf("password reset mail to %s failed: %v", user.Email, err)
+	}
+}
+
+func ConfirmPasswordReset(c *gin.Context) {
+	var request PasswordResetConfirm
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if request.Token == "" || request.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Token and new password are required"})
+		return
+	}
+
+	var resetToken PasswordResetToken
+	err := db.QueryRow("SELECT id, user_id, expires_at FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL", hashToken(request.Token)).
+		Scan(&resetToken.ID, &resetToken.UserID, &resetToken.ExpiresAt)
+	if err != nil || resetToken.ExpiresAt <= formatTimestamp(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired token"})
+		return
+	}
+
+	hashedPassword, err := HashPassword(request.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error hashing new password"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Claim the token first so that two concurrent confirmations cannot both succeed
+	result, err := tx.Exec("UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", formatTimestamp(time.Now()), resetToken.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating reset token"})
+		return
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired token"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET password = ? WHERE uid = ?", hashedPassword, resetToken.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating password"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
+		return
+	}
+
+	if err := revokeUserSessions(resetToken.UserID, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error revoking sessions"})
+		return
+	}
+
+	var role string
+	db.QueryRow("SELECT role FROM users WHERE uid = ?", resetToken.UserID).Scan(&role)
+	recordAuditAs(c, resetToken.UserID, role, "reset_password", "user", resetToken.UserID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+)
+
+// ReportTemplate configures the layout of the printed report cards.
+// It is read from the JSON file named by REPORT_TEMPLATE; omitted fields keep their defaults.
+type ReportTemplate struct {
+	Title         string       `json:"title"`          // Document heading
+	SchoolName    string       `json:"school_name"`    // Printed in the header
+	SchoolAddress string       `json:"school_address"` // Printed below the school name
+	LogoPath      string       `json:"logo_path"`      // PNG or JPEG file shown in the top left corner, empty for none
+	FontPath      string       `json:"font_path"`      // TrueType font with the Polish characters
+	BoldFontPath  string       `json:"bold_font_path"` // Bold variant of the font
+	Footer        string       `json:"footer"`         // Text above the signature lines
+	Labels        ReportLabels `json:"labels"`         // Captions of the fields and columns
+}
+
+// ReportLabels holds the captions printed on a report card
+type ReportLabels struct {
+	Student       string `json:"student"`
+	BirthDate     string `json:"birth_date"`
+	Class         string `json:"class"`
+	Year          string `json:"year"`
+	Subject       string `json:"subject"`
+	Average       string `json:"average"`
+	FinalGrade    string `json:"final_grade"`
+	Attendance    string `json:"attendance"`
+	BehaviorNotes string `json:"behavior_notes"`
+	Homeroom      string `json:"homeroom_signature"`
+	Principal     string `json:"principal_signature"`
+}
+
+// reportTemplate is the layout used for report cards, set by loadReportTemplate
+var reportTemplate = ReportTemplate{
+	Title:        "Świadectwo szkolne",
+	SchoolName:   "Mercury",
+	FontPath:     "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	BoldFontPath: "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
+	Labels: ReportLabels{
+		Student:       "Uczeń",
+		BirthDate:     "Data urodzenia",
+		Class:         "Klasa",
+		Year:          "Rok szkolny",
+		Subject:       "Przedmiot",
+		Average:       "Średnia",
+		FinalGrade:    "Ocena roczna",
+		Attendance:    "Frekwencja",
+		BehaviorNotes: "Uwagi o zachowaniu",
+		Homeroom:      "Wychowawca",
+		Principal:     "Dyrektor",
+	},
+}
+
+// loadReportTemplate reads the report card template named by REPORT_TEMPLATE, keeping the defaults when unset
+func loadReportTemplate() error {
+	path, exists := os.LookupEnv("REPORT_TEMPLATE")
+	if !exists {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid REPORT_TEMPLATE: %w", err)
+	}
+	if err := json.Unmarshal(data, &reportTemplate); err != nil {
+		return fmt.Errorf("invalid REPORT_TEMPLATE: %w", err)
+	}
+	return nil
+}
+
+// reportFonts holds the font files of the template, read once per request
+type reportFonts struct {
+	regular, bold []byte
+}
+
+func loadReportFonts() (reportFonts, error) {
+	var fonts reportFonts
+	var err error
+	if fonts.regular, err = os.ReadFile(reportTemplate.FontPath); err != nil {
+		return fonts, err
+	}
+	fonts.bold, err = os.ReadFile(reportTemplate.BoldFontPath)
+	return fonts, err
+}
+
+// buildReportCard gathers a student's personal details, grades, attendance and behavior notes for a school year
+func buildReportCard(userID uint, year AcademicYear) (ReportCard, error) {
+	card := ReportCard{Year: year, Subjects: []ReportCardSubject{}, BehaviorNotes: []Grade{}}
+	var err error
+	if card.Student, err = store.Users.Person(userID); err != nil {
+		return card, err
+	}
+	card.ClassName, err = store.Classes.ClassOf(userID, year.EndDate)
+	if err != nil && err != sql.ErrNoRows {
+		return card, err
+	}
+	whole := &Term{StartDate: year.StartDate, EndDate: year.EndDate}
+
+	grades, err := store.Grades.ListByStudent(userID, whole)
+	if err != nil {
+		return card, err
+	}
+	averages, err := gradeAverages(userID, grades, scaleGradeValues())
+	if err != nil {
+		return card, err
+	}
+	var finals []TermGrade
+	if len(year.Terms) > 0 {
+		// The final grade of the last term is the annual grade
+		if finals, err = store.TermGrades.ListByStudent(userID, &year.Terms[len(year.Terms)-1]); err != nil {
+			return card, err
+		}
+	}
+
+	subjects := map[uint]*ReportCardSubject{}
+	subject := func(id uint) (*ReportCardSubject, error) {
+		if s, ok := subjects[id]; ok {
+			return s, nil
+		}
+		found, err := store.Subjects.ByID(id)
+		if err != nil {
+			return nil, err
+		}
+		subjects[id] = &ReportCardSubject{Name: found.Name}
+		return subjects[id], nil
+	}
+	if card.ClassName != "" {
+		classSubjects, err := store.Subjects.ListByClass(card.ClassName)
+		if err != nil {
+			return card, err
+		}
+		for _, s := range classSubjects {
+			subjects[s.ID] = &ReportCardSubject{Name: s.Name}
+		}
+	}
+	for _, average := range averages.Subjects {
+		s, err := subject(average.SubjectID)
+		if err != nil {
+			return card, err
+		}
+		value := average.Average
+		s.Average = &value
+	}
+	for _, final := range finals {
+		if final.ApprovedAt == nil || final.FinalGrade == nil {
+			continue
+		}
+		s, err := subject(final.SubjectID)
+		if err != nil {
+			return card, err
+		}
+		s.FinalGrade = final.FinalGrade
+	}
+	for _, s := range subjects {
+		card.Subjects = append(card.Subjects, *s)
+	}
+	sort.Slice(card.Subjects, func(i, j int) bool { return card.Subjects[i].Name < card.Subjects[j].Name })
+
+	for _, grade := range grades {
+		if grade.GradeType == "behavior note" {
+			card.BehaviorNotes = append(card.BehaviorNotes, grade)
+		}
+	}
+	sort.SliceStable(card.BehaviorNotes, func(i, j int) bool { return card.BehaviorNotes[i].Date < card.BehaviorNotes[j].Date })
+
+	attendance, err := store.Attendance.ListByStudent(userID, whole)
+	if err != nil {
+		return card, err
+	}
+	if len(attendance) > 0 {
+		attended := 0
+		for _, record := range attendance {
+			if record.Status == "present" || record.Status == "late" {
+				attended++
+			}
+		}
+		percent := math.Round(float64(attended)*1000/float64(len(attendance))) / 10
+		card.AttendancePercent = &percent
+	}
+	return card, nil
+}
+
+// writeReportCard renders a report card as a one-page A4 PDF
+func writeReportCard(w io.Writer, card ReportCard, fonts reportFonts) error {
+	t := reportTemplate
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(t.Title, true)
+	pdf.SetCreator(t.SchoolName, true)
+	pdf.AddUTF8FontFromBytes("report", "", fonts.regular)
+	pdf.AddUTF8FontFromBytes("report", "B", fonts.bold)
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	width := pageWidth - left - right
+
+	if t.LogoPath != "" {
+		pdf.ImageOptions(t.LogoPath, left, 10, 25, 0, false, fpdf.ImageOptions{ReadDpi: true}, 0, "")
+	}
+	pdf.SetFont("report", "B", 12)
+	pdf.CellFormat(width, 6, t.SchoolName, "", 1, "R", false, 0, "")
+	if t.SchoolAddress != "" {
+		pdf.SetFont("report", "", 9)
+		pdf.CellFormat(width, 5, t.SchoolAddress, "", 1, "R", false, 0, "")
+	}
+	pdf.SetY(45)
+	pdf.SetFont("report", "B", 20)
+	pdf.CellFormat(width, 10, t.Title, "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	field := func(label, value string) {
+		pdf.SetFont("report", "", 11)
+		pdf.CellFormat(45, 7, label+":", "", 0, "", false, 0, "")
+		pdf.SetFont("report", "B", 11)
+		pdf.CellFormat(width-45, 7, value, "", 1, "", false, 0, "")
+	}
+	field(t.Labels.Student, card.Student.FirstName+" "+card.Student.LastName)
+	if card.Student.BirthDate != "" {
+		field(t.Labels.BirthDate, card.Student.BirthDate)
+	}
+	if card.ClassName != "" {
+		field(t.Labels.Class, card.ClassName)
+	}
+	field(t.Labels.Year, card.Year.Name)
+	pdf.Ln(6)
+
+	pdf.SetFont("report", "B", 11)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(width-70, 8, t.Labels.Subject, "1", 0, "", true, 0, "")
+	pdf.CellFormat(30, 8, t.Labels.Average, "1", 0, "C", true, 0, "")
+	pdf.CellFormat(40, 8, t.Labels.FinalGrade, "1", 1, "C", true, 0, "")
+	pdf.SetFont("report", "", 11)
+	for _, subject := range card.Subjects {
+		average, final := "–", "–"
+		if subject.Average != nil {
+			average = strconv.FormatFloat(*subject.Average, 'f', 2, 64)
+		}
+		if subject.FinalGrade != nil {
+			final = *subject.FinalGrade
+		}
+		pdf.CellFormat(width-70, 7, subject.Name, "1", 0, "", false, 0, "")
+		pdf.CellFormat(30, 7, average, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(40, 7, final, "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	attendance := "–"
+	if card.AttendancePercent != nil {
+		attendance = strconv.FormatFloat(*card.AttendancePercent, 'f', 1, 64) + "%"
+	}
+	field(t.Labels.Attendance, attendance)
+	if len(card.BehaviorNotes) > 0 {
+		pdf.Ln(4)
+		pdf.SetFont("report", "B", 11)
+		pdf.CellFormat(width, 7, t.Labels.BehaviorNotes, "", 1, "", false, 0, "")
+		pdf.SetFont("report", "", 10)
+		for _, note := range card.BehaviorNotes {
+			pdf.MultiCell(width, 5, note.Date+" – "+note.Grade, "", "", false)
+		}
+	}
+
+	pdf.Ln(10)
+	if t.Footer != "" {
+		pdf.SetFont("report", "", 10)
+		pdf.MultiCell(width, 5, t.Footer, "", "", false)
+		pdf.Ln(10)
+	}
+	pdf.Ln(15)
+	pdf.SetFont("report", "", 9)
+	pdf.CellFormat(width/2, 5, "....................................", "", 0, "C", false, 0, "")
+	pdf.CellFormat(width/2, 5, "....................................", "", 1, "C", false, 0, "")
+	pdf.CellFormat(width/2, 5, t.Labels.Homeroom, "", 0, "C", false, 0, "")
+	pdf.CellFormat(width/2, 5, t.Labels.Principal, "", 1, "C", false, 0, "")
+	return pdf.Output(w)
+}
+
+// reportYear loads the school year selected with ?academic_year_id=, defaulting to the current year, with its terms.
+// It writes the error response itself and returns false when the year cannot be loaded.
+func reportYear(c *gin.Context) (AcademicYear, bool) {
+	yearID, ok := yearParam(c)
+	if !ok {
+		return AcademicYear{}, false
+	}
+	if yearID == nil {
+		// A report card covers one year, so ?academic_year_id=all falls back to the current one as well
+		current, err := store.Terms.CurrentYear(today())
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic year"})
+			return AcademicYear{}, false
+		}
+		// Without any years the zero ID matches none of them below
+		yearID = &current.ID
+	}
+	years, err := store.Terms.Years()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic years"})
+		return AcademicYear{}, false
+	}
+	for _, year := range years {
+		if year.ID == *yearID {
+			return year, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"message": "Academic year not found"})
+	return AcademicYear{}, false
+}
+
+// reportFileName returns a file name for a student's report card, such as "Kowalska_Zofia_12.pdf"
+func reportFileName(card ReportCard, userID uint) string {
+	name := strings.Join(strings.Fields(card.Student.LastName+" "+card.Student.FirstName), "_")
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf("%s_%d.pdf", name, userID)
+}
+
+func GetReportCard(c *gin.Context) {
+	studentID, err := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid student ID"})
+		return
+	}
+	student, err := store.Users.ByID(uint(studentID))
+	if err == sql.ErrNoRows || (err == nil && student.Role != "student") {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Student not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving student"})
+		return
+	}
+	year, ok := reportYear(c)
+	if !ok {
+		return
+	}
+
+	card, err := buildReportCard(student.UID, year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving report card data"})
+		return
+	}
+	fonts, err := loadReportFonts()
+	if err != nil {
+		log.Printf("report card font: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error generating report card"})
+		return
+	}
+	var pdf bytes.Buffer
+	if err := writeReportCard(&pdf, card, fonts); err != nil {
+		log.Printf("report card: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error generating report card"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"report-card-%d.pdf\"", student.UID))
+	c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+}
+
+func GetClassReportCards(c *gin.Context) {
+	className := c.Query("class_name")
+	if className == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required"})
+		return
+	}
+	year, ok := reportYear(c)
+	if !ok {
+		return
+	}
+	members, err := store.Classes.Members(className, &year.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
+		return
+	}
+	fonts, err := loadReportFonts()
+	if err != nil {
+		log.Printf("report card font: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error generating report card"})
+		return
+	}
+
+	var archive bytes.Buffer
+	files := zip.NewWriter(&archive)
+	count := 0
+	for _, member := range members {
+		user, err := store.Users.ByID(member.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving student"})
+			return
+		}
+		if user.Role != "student" {
+			continue
+		}
+		card, err := buildReportCard(user.UID, year)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving report card data"})
+			return
+		}
+		// The card is for this class even if the student has since moved on
+		card.ClassName = className
+		file, err := files.Create(reportFileName(card, user.UID))
+		if err == nil {
+			err = writeReportCard(file, card, fonts)
+		}
+		if err != nil {
+			log.Printf("report card: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error generating report card"})
+			return
+		}
+		count++
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No students in class"})
+		return
+	}
+	if err := files.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error generating report card"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"report-cards-%s.zip\"", strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r == '/' {
+			return '_'
+		}
+		return r
+	}, className)))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rolloverActions lists the actions a per-student rollover override can take
+var rolloverActions = []string{"repeat", "leave", "move"}
+
+func validRolloverAction(action string) bool {
+	for _, a := range rolloverActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// classLevel matches the level number in a class name
+var classLevel = regexp.MustCompile(`\d+`)
+
+// nextClassName applies the rollover naming rule: the first number in a class name goes up by one,
+// so "1A" becomes "2A". A class promoted past maxLevel graduates and gets an empty name.
+// ok is false when the name has no number.
+func nextClassName(name string, maxLevel int) (next string, ok bool) {
+	loc := classLevel.FindStringIndex(name)
+	if loc == nil {
+		return "", false
+	}
+	level, err := strconv.Atoi(name[loc[0]:loc[1]])
+	if err != nil {
+		return "", false
+	}
+	level++
+	if maxLevel > 0 && level > maxLevel {
+		return "", true
+	}
+	return name[:loc[0]] + strconv.Itoa(level) + name[loc[1]:], true
+}
+
+// draftSubjectName names a subject copied to the next class: the old class name in it is replaced
+// ("Mathematics 1A" becomes "Mathematics 2A"), otherwise the new class name is appended.
+func draftSubjectName(name, from, to string) string {
+	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(from) + `\b`)
+	if pattern.MatchString(name) {
+		return pattern.ReplaceAllLiteralString(name, to)
+	}
+	return name + " " + to
+}
+
+// rolloverYear loads a school year taking part in a rollover.
+// It writes the error response itself and returns false when the year cannot be loaded.
+func rolloverYear(c *gin.Context, id uint) (AcademicYear, bool) {
+	year, err := store.Terms.Year(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Academic year not found"})
+		return year, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic year"})
+		return year, false
+	}
+	return year, true
+}
+
+// requireOpenYear rejects changes dated in an archived school year.
+// It writes the error response itself and returns false when the date is closed.
+func requireOpenYear(c *gin.Context, date string) bool {
+	year, err := store.Terms.CurrentYear(date)
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic year"})
+		return false
+	}
+	if year.ArchivedAt != nil && date <= year.EndDate {
+		c.JSON(http.StatusConflict, gin.H{"message": "Academic year " + year.Name + " is archived"})
+		return false
+	}
+	return true
+}
+
+// planRollover works out the classes, moves and subject drafts of a rollover without changing anything.
+// It writes the error response itself and returns false when the request cannot be carried out.
+func planRollover(c *gin.Context, req RolloverRequest) (RolloverPlan, bool) {
+	var plan RolloverPlan
+	from, ok := rolloverYear(c, req.FromYearID)
+	if !ok {
+		return plan, false
+	}
+	to, ok := rolloverYear(c, req.ToYearID)
+	if !ok {
+		return plan, false
+	}
+	if to.StartDate <= from.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The next academic year must start after the finished one"})
+		return plan, false
+	}
+	if from.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "Academic year " + from.Name + " is already archived"})
+		return plan, false
+	}
+	if to.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "Academic year " + to.Name + " is archived"})
+		return plan, false
+	}
+
+	assigned, err := store.Classes.Students(to.ID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
+		return plan, false
+	}
+	if len(assigned) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "Students are already assigned to classes in " + to.Name})
+		return plan, false
+	}
+	members, err := store.Classes.Students(from.ID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
+		return plan, false
+	}
+	classes, err := store.Classes.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving classes"})
+		return plan, false
+	}
+	exists := map[string]bool{}
+	for _, class := range classes {
+		exists[class.Name] = true
+	}
+	for name := range req.ClassMap {
+		if !exists[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class " + name + " does not exist"})
+			return plan, false
+		}
+	}
+
+	// The next class of every class with students, in the order the classes are listed
+	next := map[string]string{}
+	var fromClasses []string
+	for _, member := range members {
+		if _, seen := next[member.ClassName]; seen {
+			continue
+		}
+		target, mapped := req.ClassMap[member.ClassName]
+		if !mapped {
+			target, ok = nextClassName(member.ClassName, req.MaxLevel)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Class " + member.ClassName + " has no level number; add it to class_map"})
+				return plan, false
+			}
+		}
+		next[member.ClassName] = target
+		fromClasses = append(fromClasses, member.ClassName)
+	}
+	sources := map[string]string{}
+	for _, name := range fromClasses {
+		target := next[name]
+		if target == "" {
+			continue
+		}
+		if other, taken := sources[target]; taken {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Classes " + other + " and " + name + " both roll over to " + target})
+			return plan, false
+		}
+		sources[target] = name
+	}
+
+	inYear := map[uint]bool{}
+	for _, member := range members {
+		inYear[member.UserID] = true
+	}
+	overrides := map[uint]RolloverOverride{}
+	for _, override := range req.Overrides {
+		if !validRolloverAction(override.Action) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Override action must be repeat, leave, or move"})
+			return plan, false
+		}
+		if override.Action == "move" && override.ClassName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required to move a student"})
+			return plan, false
+		}
+		if override.Action == "move" && !exists[override.ClassName] && sources[override.ClassName] == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class " + override.ClassName + " does not exist"})
+			return plan, false
+		}
+		if !inYear[override.UserID] {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Student %d has no class in %s", override.UserID, from.Name)})
+			return plan, false
+		}
+		if _, duplicate := overrides[override.UserID]; duplicate {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Student %d has more than one override", override.UserID)})
+			return plan, false
+		}
+		overrides[override.UserID] = override
+	}
+
+	plan = RolloverPlan{
+		FromYearID:    from.ID,
+		ToYearID:      to.ID,
+		NewClasses:    []string{},
+		Moves:         []RolloverMove{},
+		SubjectDrafts: []SubjectDraft{},
+		Summary:       map[string]int{},
+	}
+	created := map[string]bool{}
+	for _, member := range members {
+		move := RolloverMove{UserID: member.UserID, FromClass: member.ClassName}
+		if override, ok := overrides[member.UserID]; ok {
+			move.Action = override.Action
+			switch override.Action {
+			case "repeat":
+				move.ToClass = member.ClassName
+			case "move":
+				move.ToClass = override.ClassName
+			}
+		} else if next[member.ClassName] == "" {
+			move.Action = "graduate"
+		} else {
+			move.Action = "promote"
+			move.ToClass = next[member.ClassName]
+		}
+		if move.ToClass != "" && !exists[move.ToClass] && !created[move.ToClass] {
+			created[move.ToClass] = true
+			plan.NewClasses = append(plan.NewClasses, move.ToClass)
+		}
+		plan.Moves = append(plan.Moves, move)
+		plan.Summary[move.Action]++
+	}
+
+	if req.CopySubjects {
+		// Teachers follow their class, so a class's subjects are drafted for the class it becomes
+		drafted := map[string]string{}
+		for _, name := range fromClasses {
+			target := next[name]
+			if target == "" {
+				continue
+			}
+			subjects, err := store.Subjects.ListByClass(name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subjects"})
+				return plan, false
+			}
+			for _, subject := range subjects {
+				draft := SubjectDraft{
+					AcademicYearID:  to.ID,
+					SourceSubjectID: &subject.ID,
+					Name:            draftSubjectName(subject.Name, name, target),
+					ClassName:       target,
+					TeacherID:       subject.TeacherID,
+					GradingScaleID:  subject.GradingScaleID,
+				}
+				if other, taken := drafted[draft.Name]; taken {
+					c.JSON(http.StatusConflict, gin.H{"message": "Subjects " + other + " and " + subject.Name + " would both become " + draft.Name})
+					return plan, false
+				}
+				drafted[draft.Name] = subject.Name
+				plan.SubjectDrafts = append(plan.SubjectDrafts, draft)
+			}
+		}
+	}
+	return plan, true
+}
+
+func RolloverAcademicYear(c *gin.Context) {
+	var req RolloverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if req.FromYearID == 0 || req.ToYearID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "From and to academic year IDs are required"})
+		return
+	}
+	if req.MaxLevel < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Max level cannot be negative"})
+		return
+	}
+
+	plan, ok := planRollover(c, req)
+	if !ok {
+		return
+	}
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"message": "Dry run, nothing was changed", "plan": plan})
+		return
+	}
+
+	err := store.Terms.Rollover(plan, formatTimestamp(time.Now()))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"message": "Academic year is already archived"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error rolling over academic year"})
+		return
+	}
+	recordAudit(c, "rollover", "academic_year", plan.FromYearID, nil, plan)
+	c.JSON(http.StatusOK, gin.H{"message": "Rollover completed successfully", "plan": plan})
+}
+
+// subjectDraftParam loads the subject draft named by the :id path parameter.
+// It writes the error response itself and returns false when the draft cannot be loaded.
+func subjectDraftParam(c *gin.Context) (SubjectDraft, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid subject draft ID"})
+		return SubjectDraft{}, false
+	}
+	draft, err := store.Subjects.Draft(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Subject draft not found"})
+		return draft, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject draft"})
+		return draft, false
+	}
+	return draft, true
+}
+
+func GetSubjectDrafts(c *gin.Context) {
+	yearID, err := strconv.ParseUint(c.Query("academic_year_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid academic_year_id"})
+		return
+	}
+	drafts, err := store.Subjects.Drafts(uint(yearID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject drafts"})
+		return
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+func SetSubjectDraftTeacher(c *gin.Context) {
+	draft, ok := subjectDraftParam(c)
+	if !ok {
+		return
+	}
+	var change SubjectDraft
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if change.TeacherID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Teacher ID is required"})
+		return
+	}
+	if !requireTeacherAccount(c, change.TeacherID) {
+		return
+	}
+
+	if err := store.Subjects.SetDraftTeacher(draft.ID, change.TeacherID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating subject draft"})
+		return
+	}
+	updated := draft
+	updated.TeacherID = change.TeacherID
+	recordAudit(c, "update", "subject_draft", draft.ID, draft, updated)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject draft updated successfully"})
+}
+
+func DeleteSubjectDraft(c *gin.Context) {
+	draft, ok := subjectDraftParam(c)
+	if !ok {
+		return
+	}
+	if err := store.Subjects.DeleteDraft(draft.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting subject draft"})
+		return
+	}
+	recordAudit(c, "delete", "subject_draft", draft.ID, draft, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject draft deleted successfully"})
+}
+
+func ApplySubjectDrafts(c *gin.Context) {
+	var req SubjectDraft
+	if err := c.ShouldBindJSON(&req); err != nil || req.AcademicYearID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Academic year ID is required"})
+		return
+	}
+	drafts, err := store.Subjects.Drafts(req.AcademicYearID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject drafts"})
+		return
+	}
+	if len(drafts) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No subject drafts for this academic year"})
+		return
+	}
+
+	applied, err := store.Subjects.ApplyDrafts(req.AcademicYearID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error applying subject drafts"})
+		return
+	}
+	recordAudit(c, "apply", "subject_drafts", req.AcademicYearID, drafts, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject drafts applied successfully", "applied": applied})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// generateToken returns a random hex-encoded token of n bytes
+func generateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hash of an opaque token as stored in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signAccessToken issues a short-lived JWT bound to the given session.
+// While two-factor enrollment is pending the token only opens the enrollment endpoints.
+func signAccessToken(userID uint, email, role string, sessionID uint) (string, error) {
+	mfaSetup, err := mfaSetupPending(userID, role)
+	if err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
+		MFASetup:  mfaSetup,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(accessTokenTTL).Unix(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
+}
+
+// createSession stores a new session for the user and returns its ID and refresh token
+func createSession(c *gin.Context, userID uint) (uint, string, error) {
+	refreshToken, err := generateToken(32)
+	if err != nil {
+		return 0, "", err
+	}
+	now := time.Now()
+	sessionID, err := db.InsertID("id", "INSERT INTO sessions (user_id, refresh_token_hash, created_at, expires_at, ip, user_agent) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, hashToken(refreshToken), formatTimestamp(now), formatTimestamp(now.Add(refreshTokenTTL)), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return 0, "", err
+	}
+	return uint(sessionID), refreshToken, nil
+}
+
+// issueTokens opens a new session for the user and responds with an access and refresh token pair
+func issueTokens(c *gin.Context, userID uint, email, role string) {
+	sessionID, refreshToken, err := createSession(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create session"})
+		return
+	}
+	accessToken, err := signAccessToken(userID, email, role, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+		return
+	}
+	recordAuditAs(c, userID, role, "login", "session", sessionID, nil, gin.H{"user_id": userID, "user_agent": c.Request.UserAgent()})
+	c.JSON(http.StatusOK, gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// sessionActive reports whether the session exists, is not revoked and has not expired
+func sessionActive(sessionID uint) (bool, error) {
+	var expiresAt string
+	err := db.QueryRow("SELECT expires_at FROM sessions WHERE id = ? AND revoked_at IS NULL", sessionID).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return expiresAt > formatTimestamp(time.Now()), nil
+}
+
+// revokeSession marks a single session as revoked
+func revokeSession(sessionID uint) error {
+	_, err := db.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", formatTimestamp(time.Now()), sessionID)
+	return err
+}
+
+// revokeUserSessions revokes every active session of a user except the one given (0 revokes all)
+func revokeUserSessions(userID, exceptSessionID uint) error {
+	_, err := db.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL", formatTimestamp(time.Now()), userID, exceptSessionID)
+	return err
+}
@@ -0,0 +1,304 @@
+package main
+
+// Store groups the repositories of the school data aggregates.
+// Handlers go through it instead of writing SQL, so that the backing database can change.
+type Store struct {
+	Users      UserStore
+	Grades     GradeStore
+	Attendance AttendanceStore
+	Excuses    ExcuseStore
+	Timetable  TimetableStore
+	Exams      ExamStore
+	Classes    ClassStore
+	Subjects   SubjectStore
+	Scales     GradingScaleStore
+	Terms      TermStore
+	TermGrades TermGradeStore
+	Bells      BellScheduleStore
+	Planning   PlanningStore
+	Drafts     TimetableDraftStore
+}
+
+// UserStore persists accounts and their personal details.
+// Lookups of a missing user return sql.ErrNoRows.
+type UserStore interface {
+	// Create stores a user with its personal details and returns the new user ID
+	Create(user User, person Person) (uint, error)
+	// ByEmail returns a user including the password hash
+	ByEmail(email string) (User, error)
+	// ByID returns a user including the password hash
+	ByID(uid uint) (User, error)
+	// Person returns the personal details of a user
+	Person(userID uint) (Person, error)
+	// CountByRole returns the number of users with a role
+	CountByRole(role string) (int, error)
+	// SetPassword replaces the password hash of a user
+	SetPassword(userID uint, hash string) error
+	// Delete removes a user together with their sessions, tokens, authenticators, guardian links, availability and details.
+	// It returns errUserHasRecords while grades, attendance, classes, lessons or other school records refer to the user.
+	Delete(userID uint) error
+}
+
+// The list methods below take the term to read; a nil term reads every term.
+
+// GradeStore persists grades
+type GradeStore interface {
+	Create(grade Grade) (uint, error)
+	// ByID returns a grade, or sql.ErrNoRows
+	ByID(id uint) (Grade, error)
+	ListByStudent(userID uint, term *Term) ([]Grade, error)
+	// Update saves the previous value as a revision and stores the new one
+	Update(grade Grade, editorID uint, reason string) error
+	// Delete saves the last value as a revision and removes the grade
+	Delete(id, editorID uint, reason string) error
+	// Revisions returns the previous values of a grade, oldest first
+	Revisions(gradeID uint) ([]GradeRevision, error)
+}
+
+// AttendanceStore persists attendance records
+type AttendanceStore interface {
+	Create(attendance Attendance) (uint, error)
+	ListByStudent(userID uint, term *Term) ([]Attendance, error)
+	// ListByLesson returns the records taken for a lesson, identified by subject, date and period
+	ListByLesson(subjectID uint, date string, classPeriod uint) ([]Attendance, error)
+	// SaveLesson stores the records of a lesson in one transaction: records with an ID update their status,
+	// excuse and timetable entry, the others are inserted. It returns the records with their IDs.
+	SaveLesson(records []Attendance) ([]Attendance, error)
+	// Counts returns the number of records per student, subject and status dated between from and to.
+	// An empty from or to leaves that end of the range open.
+	Counts(userIDs []uint, from, to string) ([]AttendanceCount, error)
+}
+
+// ExcuseStore persists requests to justify absences.
+// Lookups of a missing excuse return sql.ErrNoRows. Lists are newest first; an empty status lists every status.
+type ExcuseStore interface {
+	Create(excuse Excuse) (uint, error)
+	ByID(id uint) (Excuse, error)
+	ListByStudent(userID uint) ([]Excuse, error)
+	// ListByClass returns the excuses of the students who were members of a class
+	// in the school year current on each excuse's first day
+	ListByClass(className, status string) ([]Excuse, error)
+	// ListByHomeroom returns the excuses of the students of the classes a teacher is homeroom teacher of,
+	// counting each excuse's students in the school year current on its first day
+	ListByHomeroom(teacherID uint, status string) ([]Excuse, error)
+	// Covering returns an approved excuse of a student that covers date
+	Covering(userID uint, date string) (Excuse, error)
+	// Approve approves a pending excuse and marks the student's absences in its date range as excused,
+	// returning how many were changed. It returns sql.ErrNoRows when the excuse is no longer pending.
+	Approve(id, reviewerID uint, comment *string, at string) (int, error)
+	// Reject rejects a pending excuse, or returns sql.ErrNoRows when it is no longer pending
+	Reject(id, reviewerID uint, comment *string, at string) error
+}
+
+// TimetableStore persists timetable entries
+type TimetableStore interface {
+	Create(entry TimetableEntry) (uint, error)
+	// ByID returns a timetable entry, or sql.ErrNoRows
+	ByID(id uint) (TimetableEntry, error)
+	// Update stores the fields of an entry, or returns sql.ErrNoRows
+	Update(entry TimetableEntry) error
+	// ListByDay returns the entries of every class and term held on day
+	ListByDay(day string) ([]TimetableEntry, error)
+	// Scheduled returns the entry of a subject held on day in a period during term, or sql.ErrNoRows
+	Scheduled(subjectID uint, day string, classPeriod uint, term *Term) (TimetableEntry, error)
+	ListByClass(className string, term *Term) ([]TimetableEntry, error)
+	ListByTeacher(teacherID uint, term *Term) ([]TimetableEntry, error)
+	// CreateOverride stores a change to a lesson on one date; a second change of the same lesson and date fails
+	CreateOverride(override TimetableOverride) (uint, error)
+	// OverrideByID returns a lesson change, or sql.ErrNoRows
+	OverrideByID(id uint) (TimetableOverride, error)
+	// DeleteOverride removes a lesson change, or returns sql.ErrNoRows
+	DeleteOverride(id uint) error
+	// Overrides returns the lesson changes dated from..to inclusive, in date order
+	Overrides(from, to string) ([]TimetableOverride, error)
+	// BellPeriodsInUse returns the class periods of the entries that take their times from the bell schedule
+	BellPeriodsInUse() ([]uint, error)
+}
+
+// ExamStore persists exams
+type ExamStore interface {
+	Create(exam Exam) (uint, error)
+	ListByClass(className string, term *Term) ([]Exam, error)
+	ListByTeacher(teacherID uint, term *Term) ([]Exam, error)
+}
+
+// ClassStore persists classes and their members
+type ClassStore interface {
+	Create(class Class) (uint, error)
+	List() ([]Class, error)
+	// ByName returns a class, or sql.ErrNoRows
+	ByName(name string) (Class, error)
+	// SetHomeroom assigns the homeroom teacher of a class, nil for none, or returns sql.ErrNoRows
+	SetHomeroom(className string, teacherID *uint) error
+	AddMember(member ClassMember) (uint, error)
+	// Members returns the members of a class in a year, including memberships without a year; nil reads every year
+	Members(className string, yearID *uint) ([]ClassMember, error)
+	// Students returns the class memberships of students in a year, with undated also those without a year
+	Students(yearID uint, undated bool) ([]ClassMember, error)
+	// ClassOf returns the class a student belongs to in the school year current on date, or sql.ErrNoRows.
+	// Without a membership in a started year, it falls back to upcoming years, then to memberships without a year.
+	ClassOf(userID uint, date string) (string, error)
+}
+
+// SubjectStore persists subjects
+type SubjectStore interface {
+	Create(subject Subject) (uint, error)
+	ListByClass(className string) ([]Subject, error)
+	// ByID returns a subject, or sql.ErrNoRows
+	ByID(id uint) (Subject, error)
+	// SetGradingScale assigns a scale to a subject, nil for the default scale.
+	// It returns sql.ErrNoRows when the subject does not exist.
+	SetGradingScale(subjectID uint, scaleID *uint) error
+	// Drafts returns the subject/teacher assignments drafted for a year by the rollover
+	Drafts(yearID uint) ([]SubjectDraft, error)
+	// Draft returns a drafted assignment, or sql.ErrNoRows
+	Draft(id uint) (SubjectDraft, error)
+	// SetDraftTeacher changes the proposed teacher, or returns sql.ErrNoRows
+	SetDraftTeacher(id, teacherID uint) error
+	// DeleteDraft discards a drafted assignment, or returns sql.ErrNoRows
+	DeleteDraft(id uint) error
+	// ApplyDrafts turns the drafts of a year into subjects and removes them.
+	// A draft named like an existing subject reassigns that subject. It returns the number of drafts applied.
+	ApplyDrafts(yearID uint) (int, error)
+}
+
+// TermStore persists school years, their terms and holidays.
+// Lookups of a missing year or term return sql.ErrNoRows.
+type TermStore interface {
+	CreateYear(year AcademicYear) (uint, error)
+	// Years returns every school year with its terms, in date order
+	Years() ([]AcademicYear, error)
+	// Year returns a school year without its terms
+	Year(id uint) (AcademicYear, error)
+	CreateTerm(term Term) (uint, error)
+	ByID(id uint) (Term, error)
+	// SetProposalDeadline changes the last day to propose term grades, nil for none
+	SetProposalDeadline(id uint, deadline *string) error
+	// Current returns the term containing date, or else the latest term that started before it
+	Current(date string) (Term, error)
+	// Containing returns the term containing date, or sql.ErrNoRows when no term does
+	Containing(date string) (Term, error)
+	// CurrentYear returns the year containing date, or else the latest year that started before it
+	CurrentYear(date string) (AcademicYear, error)
+	// Rollover applies a rollover plan in one transaction: it creates the new classes, dates the memberships
+	// without a year into the finished year, adds the next-year memberships and drafts and archives the finished year
+	Rollover(plan RolloverPlan, archivedAt string) error
+	CreateHoliday(holiday Holiday) (uint, error)
+	// Holidays returns the holidays sharing a day with the inclusive range from..to, in date order.
+	// Empty bounds leave the range open.
+	Holidays(from, to string) ([]Holiday, error)
+	// DeleteHoliday removes a holiday, or returns sql.ErrNoRows
+	DeleteHoliday(id uint) error
+}
+
+// TermGradeStore persists proposed and final term grades.
+// Lookups of a missing term grade return sql.ErrNoRows.
+type TermGradeStore interface {
+	// Find returns the term grade of a student in a subject and term
+	Find(userID, subjectID, termID uint) (TermGrade, error)
+	ByID(id uint) (TermGrade, error)
+	Create(grade TermGrade) (uint, error)
+	// Update stores the grades of a term grade; it returns sql.ErrNoRows once the grade is approved
+	Update(grade TermGrade) error
+	ListByStudent(userID uint, term *Term) ([]TermGrade, error)
+	// ListByClass returns the term grades of the students the class has in the term's year; with every term,
+	// each grade is matched with the class's students in the year of its own term
+	ListByClass(className string, term *Term) ([]TermGrade, error)
+	// Approve locks the final grades of a class's students in a term, of one subject or all when subjectID is nil.
+	// Grades without a final grade stay open. It returns the number approved.
+	Approve(className string, termID uint, subjectID *uint, approverID uint, at string) (int, error)
+	// Unlock removes the approval of a term grade
+	Unlock(id uint) error
+}
+
+// GradingScaleStore persists grading scales.
+// Lookups of a missing scale return sql.ErrNoRows.
+type GradingScaleStore interface {
+	// Create stores a scale with its values; a default scale replaces the previous default
+	Create(scale GradingScale) (uint, error)
+	List() ([]GradingScale, error)
+	ByID(id uint) (GradingScale, error)
+	// SetDefault makes a scale the default, nil to have none
+	SetDefault(id *uint) error
+	// ForSubject returns the scale of a subject, or the default scale when it has none
+	ForSubject(subjectID uint) (GradingScale, error)
+}
+
+// BellScheduleStore persists bell schedules with their periods and the days they replace the default schedule on.
+// Lookups of a missing schedule return sql.ErrNoRows.
+type BellScheduleStore interface {
+	// Create stores a schedule with its periods; a default schedule replaces the previous default
+	Create(schedule BellSchedule) (uint, error)
+	// List returns every schedule with its periods and dates
+	List() ([]BellSchedule, error)
+	ByID(id uint) (BellSchedule, error)
+	// Update replaces the name and the periods of a schedule
+	Update(schedule BellSchedule) error
+	// SetDefault makes a schedule the default one
+	SetDefault(id uint) error
+	// AddDates assigns days to a schedule
+	AddDates(dates BellScheduleDates) (uint, error)
+	// DeleteDates removes days assigned to a schedule, or returns sql.ErrNoRows
+	DeleteDates(id uint) error
+}
+
+// PlanningStore persists what the timetable generator plans from: rooms, weekly teaching requirements and teacher availability.
+// Deleting a missing row returns sql.ErrNoRows.
+type PlanningStore interface {
+	CreateRoom(room Room) (uint, error)
+	// Rooms returns every room in name order
+	Rooms() ([]Room, error)
+	DeleteRoom(id uint) error
+	// SetRequirement stores the requirement of a subject, replacing its previous one, and returns its ID
+	SetRequirement(requirement TeachingRequirement) (uint, error)
+	Requirements() ([]TeachingRequirement, error)
+	DeleteRequirement(id uint) error
+	// SetAvailability stores the availability of a teacher in a period, replacing the previous one, and returns its ID
+	SetAvailability(availability TeacherAvailability) (uint, error)
+	// Availability returns the availability of a teacher, of every teacher when teacherID is 0
+	Availability(teacherID uint) ([]TeacherAvailability, error)
+	DeleteAvailability(id uint) error
+}
+
+// TimetableDraftStore persists timetables drafted by the generator.
+// Lookups of a missing draft or drafted lesson return sql.ErrNoRows.
+type TimetableDraftStore interface {
+	// Create stores a draft with its lessons
+	Create(draft TimetableDraft) (uint, error)
+	// List returns the drafts without their lessons, newest first
+	List() ([]TimetableDraft, error)
+	// ByID returns a draft with its lessons
+	ByID(id uint) (TimetableDraft, error)
+	EntryByID(id uint) (TimetableDraftEntry, error)
+	// UpdateEntry stores the day, period and room of a drafted lesson
+	UpdateEntry(entry TimetableDraftEntry) error
+	DeleteEntry(id uint) error
+	// Delete removes a draft with its lessons
+	Delete(id uint) error
+	// Publish adds the placed lessons of a draft to the timetable of its term, following the bell schedule, and marks
+	// the draft published in one transaction. check runs inside it while other writers are kept out of the timetable
+	// and its planning data; an error from check rolls the publication back and is returned. It returns the number
+	// of entries added, or sql.ErrNoRows when the draft is already published.
+	Publish(id, publishedBy uint, at string, check func() error) (int, error)
+}
+
+// newStore returns the SQL stores on db. They serve SQLite and PostgreSQL alike,
+// as DB hides the placeholder and generated key differences between the two.
+func newStore(db *DB) *Store {
+	return &Store{
+		Users:      sqlUserStore{db},
+		Grades:     sqlGradeStore{db},
+		Attendance: sqlAttendanceStore{db},
+		Excuses:    sqlExcuseStore{db},
+		Timetable:  sqlTimetableStore{db},
+		Exams:      sqlExamStore{db},
+		Classes:    sqlClassStore{db},
+		Subjects:   sqlSubjectStore{db},
+		Scales:     sqlGradingScaleStore{db},
+		Terms:      sqlTermStore{db},
+		TermGrades: sqlTermGradeStore{db},
+		Bells:      sqlBellScheduleStore{db},
+		Planning:   sqlPlanningStore{db},
+		Drafts:     sqlTimetableDraftStore{db},
+	}
+}
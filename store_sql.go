@@ -0,0 +1,1809 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// errUserHasRecords is returned by UserStore.Delete when school records still refer to the user
+var errUserHasRecords = errors.New("user has school records")
+
+// nullIfEmpty stores an empty optional value as NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+type sqlUserStore struct{ db *DB }
+
+func (s sqlUserStore) Create(user User, person Person) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := tx.InsertID("uid", "INSERT INTO users (email, password, role) VALUES (?, ?, ?)", user.Email, user.Password, user.Role)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO persons (user_id, first_name, last_name, birth_date, address, phone) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, person.FirstName, person.LastName, nullIfEmpty(person.BirthDate), nullIfEmpty(person.Address), nullIfEmpty(person.Phone))
+	if err != nil {
+		return 0, err
+	}
+	return uint(userID), tx.Commit()
+}
+
+func (s sqlUserStore) ByEmail(email string) (User, error) {
+	var user User
+	err := s.db.QueryRow("SELECT uid, email, password, role FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email, &user.Password, &user.Role)
+	return user, err
+}
+
+func (s sqlUserStore) ByID(uid uint) (User, error) {
+	var user User
+	err := s.db.QueryRow("SELECT uid, email, password, role FROM users WHERE uid = ?", uid).Scan(&user.UID, &user.Email, &user.Password, &user.Role)
+	return user, err
+}
+
+func (s sqlUserStore) Person(userID uint) (Person, error) {
+	var person Person
+	var birthDate, address, phone sql.NullString
+	err := s.db.QueryRow("SELECT id, user_id, first_name, last_name, birth_date, address, phone FROM persons WHERE user_id = ?", userID).
+		Scan(&person.ID, &person.UserID, &person.FirstName, &person.LastName, &birthDate, &address, &phone)
+	person.BirthDate = birthDate.String
+	person.Address = address.String
+	person.Phone = phone.String
+	return person, err
+}
+
+func (s sqlUserStore) CountByRole(role string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", role).Scan(&count)
+	return count, err
+}
+
+func (s sqlUserStore) SetPassword(userID uint, hash string) error {
+	_, err := s.db.Exec("UPDATE users SET password = ? WHERE uid = ?", hash, userID)
+	return err
+}
+
+func (s sqlUserStore) Delete(userID uint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// School records must outlive the account, so a user they refer to is not deleted
+	records := []string{
+		"SELECT 1 FROM grades WHERE ? IN (user_id, teacher_id) LIMIT 1",
+		"SELECT 1 FROM grade_revisions WHERE editor_id = ? LIMIT 1",
+		"SELECT 1 FROM term_grades WHERE ? IN (user_id, teacher_id, approved_by) LIMIT 1",
+		"SELECT 1 FROM attendance WHERE user_id = ? LIMIT 1",
+		"SELECT 1 FROM excuses WHERE ? IN (user_id, submitted_by, reviewed_by) LIMIT 1",
+		"SELECT 1 FROM class_members WHERE user_id = ? LIMIT 1",
+		"SELECT 1 FROM classes WHERE homeroom_teacher_id = ? LIMIT 1",
+		"SELECT 1 FROM subjects WHERE teacher_id = ? LIMIT 1",
+		"SELECT 1 FROM subject_drafts WHERE teacher_id = ? LIMIT 1",
+		"SELECT 1 FROM students_subjects WHERE user_id = ? LIMIT 1",
+		"SELECT 1 FROM teachers_subjects WHERE user_id = ? LIMIT 1",
+		"SELECT 1 FROM exams WHERE teacher_id = ? LIMIT 1",
+		"SELECT 1 FROM timetable WHERE teacher_id = ? LIMIT 1",
+		"SELECT 1 FROM timetable_overrides WHERE ? IN (substitute_teacher_id, created_by) LIMIT 1",
+		"SELECT 1 FROM timetable_drafts WHERE ? IN (created_by, published_by) LIMIT 1",
+		"SELECT 1 FROM timetable_draft_entries WHERE teacher_id = ? LIMIT 1",
+	}
+	for _, record := range records {
+		var found int
+		err := tx.QueryRow(record, userID).Scan(&found)
+		if err == nil {
+			return errUserHasRecords
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	statements := []string{
+		"UPDATE lockout_events SET unlocked_by = NULL WHERE unlocked_by = ?",
+		"DELETE FROM teacher_availability WHERE teacher_id = ?",
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM password_reset_tokens WHERE user_id = ?",
+		"DELETE FROM recovery_codes WHERE user_id = ?",
+		"DELETE FROM calendar_tokens WHERE user_id = ?",
+		"DELETE FROM user_totp WHERE user_id = ?",
+		"DELETE FROM guardians WHERE ? IN (guardian_id, student_id)",
+		"DELETE FROM persons WHERE user_id = ?",
+		"DELETE FROM users WHERE uid = ?",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type sqlGradeStore struct{ db *DB }
+
+func (s sqlGradeStore) Create(grade Grade) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO grades (user_id, subject_id, teacher_id, grade, grade_type, weight, date) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		grade.UserID, grade.SubjectID, grade.TeacherID, grade.Grade, grade.GradeType, grade.Weight, grade.Date)
+	return uint(id), err
+}
+
+// inTerm narrows a query on a table with a date column to the dates of a term
+func inTerm(where string, args []interface{}, term *Term) (string, []interface{}) {
+	if term == nil {
+		return where, args
+	}
+	return where + " AND date >= ? AND date <= ?", append(args, term.StartDate, term.EndDate)
+}
+
+func (s sqlGradeStore) ListByStudent(userID uint, term *Term) ([]Grade, error) {
+	where, args := inTerm("user_id = ?", []interface{}{userID}, term)
+	rows, err := s.db.Query("SELECT id, user_id, subject_id, teacher_id, grade, grade_type, weight, date FROM grades WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grades []Grade
+	for rows.Next() {
+		var grade Grade
+		if err := rows.Scan(&grade.ID, &grade.UserID, &grade.SubjectID, &grade.TeacherID, &grade.Grade, &grade.GradeType, &grade.Weight, &grade.Date); err != nil {
+			return nil, err
+		}
+		grades = append(grades, grade)
+	}
+	return grades, rows.Err()
+}
+
+func (s sqlGradeStore) ByID(id uint) (Grade, error) {
+	var grade Grade
+	err := s.db.QueryRow("SELECT id, user_id, subject_id, teacher_id, grade, grade_type, weight, date FROM grades WHERE id = ?", id).
+		Scan(&grade.ID, &grade.UserID, &grade.SubjectID, &grade.TeacherID, &grade.Grade, &grade.GradeType, &grade.Weight, &grade.Date)
+	return grade, err
+}
+
+// saveRevision copies the current row of a grade into grade_revisions
+func (s sqlGradeStore) saveRevision(tx *Tx, gradeID uint, action string, editorID uint, reason string) error {
+	result, err := tx.Exec(`INSERT INTO grade_revisions (grade_id, action, user_id, subject_id, teacher_id, grade, weight, grade_type, date, editor_id, reason, created_at)
+		SELECT id, ?, user_id, subject_id, teacher_id, grade, weight, grade_type, date, ?, ?, ? FROM grades WHERE id = ?`,
+		action, editorID, reason, formatTimestamp(time.Now()), gradeID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlGradeStore) Update(grade Grade, editorID uint, reason string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.saveRevision(tx, grade.ID, "update", editorID, reason); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE grades SET grade = ?, grade_type = ?, weight = ?, date = ? WHERE id = ?", grade.Grade, grade.GradeType, grade.Weight, grade.Date, grade.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s sqlGradeStore) Delete(id, editorID uint, reason string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.saveRevision(tx, id, "delete", editorID, reason); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM grades WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s sqlGradeStore) Revisions(gradeID uint) ([]GradeRevision, error) {
+	rows, err := s.db.Query("SELECT id, grade_id, action, user_id, subject_id, teacher_id, grade, COALESCE(weight, 0), grade_type, date, editor_id, reason, created_at FROM grade_revisions WHERE grade_id = ? ORDER BY id", gradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []GradeRevision
+	for rows.Next() {
+		var r GradeRevision
+		if err := rows.Scan(&r.ID, &r.GradeID, &r.Action, &r.UserID, &r.SubjectID, &r.TeacherID, &r.Grade, &r.Weight, &r.GradeType, &r.Date, &r.EditorID, &r.Reason, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+type sqlAttendanceStore struct{ db *DB }
+
+func (s sqlAttendanceStore) Create(attendance Attendance) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO attendance (user_id, subject_id, status, date, excuse_id, class_period, timetable_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		attendance.UserID, attendance.SubjectID, attendance.Status, attendance.Date, attendance.ExcuseID, attendance.ClassPeriod, attendance.TimetableID)
+	return uint(id), err
+}
+
+func (s sqlAttendanceStore) ListByStudent(userID uint, term *Term) ([]Attendance, error) {
+	where, args := inTerm("user_id = ?", []interface{}{userID}, term)
+	return s.list(where, args...)
+}
+
+func (s sqlAttendanceStore) ListByLesson(subjectID uint, date string, classPeriod uint) ([]Attendance, error) {
+	return s.list("subject_id = ? AND date = ? AND class_period = ?", subjectID, date, classPeriod)
+}
+
+// list returns the attendance records matching where, with the times of their timetable entries
+func (s sqlAttendanceStore) list(where string, args ...interface{}) ([]Attendance, error) {
+	rows, err := s.db.Query(`SELECT id, user_id, subject_id, status, date, excuse_id, class_period, timetable_id,
+		(SELECT `+bellTime("time_start", "attendance.date")+` FROM timetable WHERE timetable.id = attendance.timetable_id),
+		(SELECT `+bellTime("time_end", "attendance.date")+` FROM timetable WHERE timetable.id = attendance.timetable_id)
+		FROM attendance WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attendance []Attendance
+	for rows.Next() {
+		var att Attendance
+		if err := rows.Scan(&att.ID, &att.UserID, &att.SubjectID, &att.Status, &att.Date, &att.ExcuseID, &att.ClassPeriod, &att.TimetableID, &att.StartTime, &att.EndTime); err != nil {
+			return nil, err
+		}
+		attendance = append(attendance, att)
+	}
+	return attendance, rows.Err()
+}
+
+func (s sqlAttendanceStore) Counts(userIDs []uint, from, to string) ([]AttendanceCount, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var args []interface{}
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	where := "user_id IN (?" + strings.Repeat(", ?", len(userIDs)-1) + ")"
+	if from != "" {
+		where += " AND date >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		where += " AND date <= ?"
+		args = append(args, to)
+	}
+	rows, err := s.db.Query("SELECT user_id, subject_id, status, COUNT(*) FROM attendance WHERE "+where+" GROUP BY user_id, subject_id, status", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []AttendanceCount
+	for rows.Next() {
+		var count AttendanceCount
+		if err := rows.Scan(&count.UserID, &count.SubjectID, &count.Status, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (s sqlAttendanceStore) SaveLesson(records []Attendance) ([]Attendance, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	saved := make([]Attendance, len(records))
+	for i, record := range records {
+		if record.ID != 0 {
+			_, err = tx.Exec("UPDATE attendance SET status = ?, excuse_id = ?, timetable_id = ? WHERE id = ?", record.Status, record.ExcuseID, record.TimetableID, record.ID)
+		} else {
+			var id int64
+			id, err = tx.InsertID("id", "INSERT INTO attendance (user_id, subject_id, status, date, excuse_id, class_period, timetable_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				record.UserID, record.SubjectID, record.Status, record.Date, record.ExcuseID, record.ClassPeriod, record.TimetableID)
+			record.ID = uint(id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		saved[i] = record
+	}
+	return saved, tx.Commit()
+}
+
+// bellTime returns SQL selecting column, time_start or time_end, of the timetable row in scope: its own time, else the time
+// of its period in the bell schedule assigned to the SQL expression date, else in the default schedule.
+// Without a date only the default schedule is used.
+func bellTime(column, date string) string {
+	period := "SELECT bell_periods." + column + " FROM bell_periods JOIN bell_schedules ON bell_schedules.id = bell_periods.schedule_id WHERE bell_periods.class_period = timetable.class_period AND "
+	value := "COALESCE(timetable." + column
+	if date != "" {
+		value += ", (" + period + "bell_schedules.id = (SELECT schedule_id FROM bell_schedule_dates WHERE " + date + " BETWEEN start_date AND end_date ORDER BY id LIMIT 1))"
+	}
+	return value + ", (" + period + "bell_schedules.is_default = 1))"
+}
+
+type sqlTimetableStore struct{ db *DB }
+
+// ownTimes returns the times stored with an entry, NULL for an entry following the bell schedule
+func ownTimes(entry TimetableEntry) (interface{}, interface{}) {
+	if !entry.CustomTimes {
+		return nil, nil
+	}
+	return entry.StartTime, entry.EndTime
+}
+
+func (s sqlTimetableStore) Create(entry TimetableEntry) (uint, error) {
+	start, end := ownTimes(entry)
+	id, err := s.db.InsertID("id", "INSERT INTO timetable (day, subject_id, class_period, time_start, time_end, room, teacher_id, class_name, term_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.Day, entry.SubjectID, entry.ClassPeriod, start, end, nullIfEmpty(entry.Room), entry.TeacherID, entry.ClassName, entry.TermID)
+	return uint(id), err
+}
+
+func (s sqlTimetableStore) Update(entry TimetableEntry) error {
+	start, end := ownTimes(entry)
+	result, err := s.db.Exec("UPDATE timetable SET day = ?, subject_id = ?, class_period = ?, time_start = ?, time_end = ?, room = ?, teacher_id = ?, class_name = ?, term_id = ? WHERE id = ?",
+		entry.Day, entry.SubjectID, entry.ClassPeriod, start, end, nullIfEmpty(entry.Room), entry.TeacherID, entry.ClassName, entry.TermID, entry.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTimetableStore) ListByDay(day string) ([]TimetableEntry, error) {
+	return s.list(nil, "day = ?", day)
+}
+
+func (s sqlTimetableStore) ByID(id uint) (TimetableEntry, error) {
+	entries, err := s.list(nil, "id = ?", id)
+	if err == nil && len(entries) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return TimetableEntry{}, err
+	}
+	return entries[0], nil
+}
+
+func (s sqlTimetableStore) Scheduled(subjectID uint, day string, classPeriod uint, term *Term) (TimetableEntry, error) {
+	entries, err := s.list(term, "subject_id = ? AND class_period = ? AND LOWER(day) = LOWER(?)", subjectID, classPeriod, day)
+	if err == nil && len(entries) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return TimetableEntry{}, err
+	}
+	return entries[0], nil
+}
+
+func (s sqlTimetableStore) ListByClass(className string, term *Term) ([]TimetableEntry, error) {
+	return s.list(term, "class_name = ?", className)
+}
+
+func (s sqlTimetableStore) ListByTeacher(teacherID uint, term *Term) ([]TimetableEntry, error) {
+	return s.list(term, "teacher_id = ?", teacherID)
+}
+
+// list returns the entries matching where that apply to a term, including those for every term
+func (s sqlTimetableStore) list(term *Term, where string, args ...interface{}) ([]TimetableEntry, error) {
+	if term != nil {
+		where += " AND (term_id IS NULL OR term_id = ?)"
+		args = append(args, term.ID)
+	}
+	rows, err := s.db.Query(`SELECT id, day, subject_id, class_period, COALESCE(`+bellTime("time_start", "")+`, ''), COALESCE(`+bellTime("time_end", "")+`, ''),
+		time_start IS NOT NULL, room, teacher_id, class_name, term_id FROM timetable WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timetable []TimetableEntry
+	for rows.Next() {
+		var entry TimetableEntry
+		var room sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Day, &entry.SubjectID, &entry.ClassPeriod, &entry.StartTime, &entry.EndTime, &entry.CustomTimes, &room, &entry.TeacherID, &entry.ClassName, &entry.TermID); err != nil {
+			return nil, err
+		}
+		entry.Room = room.String
+		timetable = append(timetable, entry)
+	}
+	return timetable, rows.Err()
+}
+
+func (s sqlTimetableStore) CreateOverride(override TimetableOverride) (uint, error) {
+	id, err := s.db.InsertID("id", `INSERT INTO timetable_overrides (timetable_id, date, cancelled, substitute_teacher_id, room, class_period, time_start, time_end, note, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		override.TimetableID, override.Date, override.Cancelled, override.SubstituteTeacherID, override.Room, override.ClassPeriod,
+		override.StartTime, override.EndTime, nullIfEmpty(override.Note), override.CreatedBy, override.CreatedAt)
+	return uint(id), err
+}
+
+func (s sqlTimetableStore) OverrideByID(id uint) (TimetableOverride, error) {
+	overrides, err := s.overrides("id = ?", id)
+	if err == nil && len(overrides) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return TimetableOverride{}, err
+	}
+	return overrides[0], nil
+}
+
+func (s sqlTimetableStore) DeleteOverride(id uint) error {
+	result, err := s.db.Exec("DELETE FROM timetable_overrides WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTimetableStore) Overrides(from, to string) ([]TimetableOverride, error) {
+	return s.overrides("date >= ? AND date <= ?", from, to)
+}
+
+// overrides returns the lesson changes matching where, in date order
+func (s sqlTimetableStore) overrides(where string, args ...interface{}) ([]TimetableOverride, error) {
+	rows, err := s.db.Query(`SELECT id, timetable_id, date, cancelled, substitute_teacher_id, room, class_period, time_start, time_end, note, created_by, created_at
+		FROM timetable_overrides WHERE `+where+" ORDER BY date, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []TimetableOverride{}
+	for rows.Next() {
+		var override TimetableOverride
+		var note sql.NullString
+		if err := rows.Scan(&override.ID, &override.TimetableID, &override.Date, &override.Cancelled, &override.SubstituteTeacherID, &override.Room,
+			&override.ClassPeriod, &override.StartTime, &override.EndTime, &note, &override.CreatedBy, &override.CreatedAt); err != nil {
+			return nil, err
+		}
+		override.Note = note.String
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+func (s sqlTimetableStore) BellPeriodsInUse() ([]uint, error) {
+	rows, err := s.db.Query("SELECT DISTINCT class_period FROM timetable WHERE time_start IS NULL ORDER BY class_period")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []uint
+	for rows.Next() {
+		var period uint
+		if err := rows.Scan(&period); err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+type sqlExamStore struct{ db *DB }
+
+func (s sqlExamStore) Create(exam Exam) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO exams (class_name, teacher_id, subject_id, date, type, description) VALUES (?, ?, ?, ?, ?, ?)",
+		exam.ClassName, exam.TeacherID, exam.SubjectID, exam.Date, exam.Type, nullIfEmpty(exam.Description))
+	return uint(id), err
+}
+
+func (s sqlExamStore) ListByClass(className string, term *Term) ([]Exam, error) {
+	where, args := inTerm("class_name = ?", []interface{}{className}, term)
+	return s.list(where, args...)
+}
+
+func (s sqlExamStore) ListByTeacher(teacherID uint, term *Term) ([]Exam, error) {
+	where, args := inTerm("teacher_id = ?", []interface{}{teacherID}, term)
+	return s.list(where, args...)
+}
+
+func (s sqlExamStore) list(where string, args ...interface{}) ([]Exam, error) {
+	rows, err := s.db.Query("SELECT id, class_name, teacher_id, subject_id, date, type, description FROM exams WHERE "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exams []Exam
+	for rows.Next() {
+		var exam Exam
+		var description sql.NullString
+		if err := rows.Scan(&exam.ID, &exam.ClassName, &exam.TeacherID, &exam.SubjectID, &exam.Date, &exam.Type, &description); err != nil {
+			return nil, err
+		}
+		exam.Description = description.String
+		exams = append(exams, exam)
+	}
+	return exams, rows.Err()
+}
+
+type sqlClassStore struct{ db *DB }
+
+func (s sqlClassStore) Create(class Class) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO classes (name, homeroom_teacher_id) VALUES (?, ?)", class.Name, class.HomeroomTeacherID)
+	return uint(id), err
+}
+
+func (s sqlClassStore) AddMember(member ClassMember) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO class_members (user_id, class_name, academic_year_id) VALUES (?, ?, ?)",
+		member.UserID, member.ClassName, member.AcademicYearID)
+	return uint(id), err
+}
+
+func (s sqlClassStore) List() ([]Class, error) {
+	rows, err := s.db.Query("SELECT id, name, homeroom_teacher_id FROM classes ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var classes []Class
+	for rows.Next() {
+		var class Class
+		if err := rows.Scan(&class.ID, &class.Name, &class.HomeroomTeacherID); err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+	return classes, rows.Err()
+}
+
+func (s sqlClassStore) ByName(name string) (Class, error) {
+	var class Class
+	err := s.db.QueryRow("SELECT id, name, homeroom_teacher_id FROM classes WHERE name = ?", name).
+		Scan(&class.ID, &class.Name, &class.HomeroomTeacherID)
+	return class, err
+}
+
+func (s sqlClassStore) SetHomeroom(className string, teacherID *uint) error {
+	result, err := s.db.Exec("UPDATE classes SET homeroom_teacher_id = ? WHERE name = ?", teacherID, className)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// members returns the class memberships matching where
+func (s sqlClassStore) members(where string, args ...interface{}) ([]ClassMember, error) {
+	rows, err := s.db.Query("SELECT id, user_id, class_name, academic_year_id FROM class_members WHERE "+where+" ORDER BY class_name, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []ClassMember
+	for rows.Next() {
+		var member ClassMember
+		if err := rows.Scan(&member.ID, &member.UserID, &member.ClassName, &member.AcademicYearID); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (s sqlClassStore) Members(className string, yearID *uint) ([]ClassMember, error) {
+	if yearID == nil {
+		return s.members("class_name = ?", className)
+	}
+	return s.members("class_name = ? AND (academic_year_id = ? OR academic_year_id IS NULL)", className, *yearID)
+}
+
+func (s sqlClassStore) Students(yearID uint, undated bool) ([]ClassMember, error) {
+	students := "user_id IN (SELECT uid FROM users WHERE role = 'student')"
+	if undated {
+		return s.members(students+" AND (academic_year_id = ? OR academic_year_id IS NULL)", yearID)
+	}
+	return s.members(students+" AND academic_year_id = ?", yearID)
+}
+
+func (s sqlClassStore) ClassOf(userID uint, date string) (string, error) {
+	var className string
+	// Started years come first, latest first, then upcoming years; memberships without a year predate school years and come last
+	err := s.db.QueryRow(`SELECT m.class_name FROM class_members m
+		LEFT JOIN academic_years y ON y.id = m.academic_year_id
+		WHERE m.user_id = ?
+		ORDER BY CASE WHEN y.start_date IS NULL THEN 2 WHEN y.start_date <= ? THEN 0 ELSE 1 END, y.start_date DESC, m.id DESC
+		LIMIT 1`, userID, date).Scan(&className)
+	return className, err
+}
+
+type sqlSubjectStore struct{ db *DB }
+
+func (s sqlSubjectStore) Create(subject Subject) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO subjects (name, class_name, teacher_id, grading_scale_id) VALUES (?, ?, ?, ?)",
+		subject.Name, subject.ClassName, subject.TeacherID, subject.GradingScaleID)
+	return uint(id), err
+}
+
+func (s sqlSubjectStore) ListByClass(className string) ([]Subject, error) {
+	rows, err := s.db.Query("SELECT id, name, class_name, teacher_id, grading_scale_id FROM subjects WHERE class_name = ?", className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []Subject
+	for rows.Next() {
+		var subject Subject
+		if err := rows.Scan(&subject.ID, &subject.Name, &subject.ClassName, &subject.TeacherID, &subject.GradingScaleID); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
+
+func (s sqlSubjectStore) ByID(id uint) (Subject, error) {
+	var subject Subject
+	err := s.db.QueryRow("SELECT id, name, class_name, teacher_id, grading_scale_id FROM subjects WHERE id = ?", id).
+		Scan(&subject.ID, &subject.Name, &subject.ClassName, &subject.TeacherID, &subject.GradingScaleID)
+	return subject, err
+}
+
+func (s sqlSubjectStore) SetGradingScale(subjectID uint, scaleID *uint) error {
+	result, err := s.db.Exec("UPDATE subjects SET grading_scale_id = ? WHERE id = ?", scaleID, subjectID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const subjectDraftColumns = "id, academic_year_id, source_subject_id, name, class_name, teacher_id, grading_scale_id"
+
+func scanSubjectDraft(row interface{ Scan(...interface{}) error }) (SubjectDraft, error) {
+	var draft SubjectDraft
+	err := row.Scan(&draft.ID, &draft.AcademicYearID, &draft.SourceSubjectID, &draft.Name, &draft.ClassName, &draft.TeacherID, &draft.GradingScaleID)
+	return draft, err
+}
+
+func (s sqlSubjectStore) Drafts(yearID uint) ([]SubjectDraft, error) {
+	rows, err := s.db.Query("SELECT "+subjectDraftColumns+" FROM subject_drafts WHERE academic_year_id = ? ORDER BY class_name, name", yearID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []SubjectDraft
+	for rows.Next() {
+		draft, err := scanSubjectDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+	return drafts, rows.Err()
+}
+
+func (s sqlSubjectStore) Draft(id uint) (SubjectDraft, error) {
+	return scanSubjectDraft(s.db.QueryRow("SELECT "+subjectDraftColumns+" FROM subject_drafts WHERE id = ?", id))
+}
+
+func (s sqlSubjectStore) SetDraftTeacher(id, teacherID uint) error {
+	result, err := s.db.Exec("UPDATE subject_drafts SET teacher_id = ? WHERE id = ?", teacherID, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlSubjectStore) DeleteDraft(id uint) error {
+	result, err := s.db.Exec("DELETE FROM subject_drafts WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlSubjectStore) ApplyDrafts(yearID uint) (int, error) {
+	drafts, err := s.Drafts(yearID)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, draft := range drafts {
+		result, err := tx.Exec("UPDATE subjects SET class_name = ?, teacher_id = ?, grading_scale_id = ? WHERE name = ?",
+			draft.ClassName, draft.TeacherID, draft.GradingScaleID, draft.Name)
+		if err != nil {
+			return 0, err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return 0, err
+		} else if affected == 0 {
+			if _, err := tx.InsertID("id", "INSERT INTO subjects (name, class_name, teacher_id, grading_scale_id) VALUES (?, ?, ?, ?)",
+				draft.Name, draft.ClassName, draft.TeacherID, draft.GradingScaleID); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM subject_drafts WHERE academic_year_id = ?", yearID); err != nil {
+		return 0, err
+	}
+	return len(drafts), tx.Commit()
+}
+
+type sqlGradingScaleStore struct{ db *DB }
+
+const gradingScaleColumns = "id, name, kind, min_value, max_value, is_default"
+
+func scanGradingScale(row interface{ Scan(...interface{}) error }) (GradingScale, error) {
+	var scale GradingScale
+	err := row.Scan(&scale.ID, &scale.Name, &scale.Kind, &scale.MinValue, &scale.MaxValue, &scale.IsDefault)
+	return scale, err
+}
+
+func (s sqlGradingScaleStore) Create(scale GradingScale) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if scale.IsDefault {
+		if _, err := tx.Exec("UPDATE grading_scales SET is_default = 0 WHERE is_default = 1"); err != nil {
+			return 0, err
+		}
+	}
+	id, err := tx.InsertID("id", "INSERT INTO grading_scales (name, kind, min_value, max_value, is_default) VALUES (?, ?, ?, ?, ?)",
+		scale.Name, scale.Kind, scale.MinValue, scale.MaxValue, scale.IsDefault)
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range scale.Values {
+		if _, err := tx.Exec("INSERT INTO grading_scale_values (scale_id, value, numeric_value) VALUES (?, ?, ?)",
+			id, value.Value, value.NumericValue); err != nil {
+			return 0, err
+		}
+	}
+	return uint(id), tx.Commit()
+}
+
+// values returns the accepted values of one scale, or of all scales when scaleID is nil
+func (s sqlGradingScaleStore) values(scaleID *uint) (map[uint][]GradingScaleValue, error) {
+	query := "SELECT scale_id, value, numeric_value FROM grading_scale_values"
+	var args []interface{}
+	if scaleID != nil {
+		query += " WHERE scale_id = ?"
+		args = append(args, *scaleID)
+	}
+	rows, err := s.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[uint][]GradingScaleValue{}
+	for rows.Next() {
+		var id uint
+		var value GradingScaleValue
+		if err := rows.Scan(&id, &value.Value, &value.NumericValue); err != nil {
+			return nil, err
+		}
+		values[id] = append(values[id], value)
+	}
+	return values, rows.Err()
+}
+
+func (s sqlGradingScaleStore) List() ([]GradingScale, error) {
+	rows, err := s.db.Query("SELECT " + gradingScaleColumns + " FROM grading_scales ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scales []GradingScale
+	for rows.Next() {
+		scale, err := scanGradingScale(rows)
+		if err != nil {
+			return nil, err
+		}
+		scales = append(scales, scale)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := s.values(nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scales {
+		scales[i].Values = values[scales[i].ID]
+	}
+	return scales, nil
+}
+
+// withValues loads the values of a scale that was just read
+func (s sqlGradingScaleStore) withValues(scale GradingScale, err error) (GradingScale, error) {
+	if err != nil {
+		return scale, err
+	}
+	values, err := s.values(&scale.ID)
+	scale.Values = values[scale.ID]
+	return scale, err
+}
+
+func (s sqlGradingScaleStore) ByID(id uint) (GradingScale, error) {
+	return s.withValues(scanGradingScale(s.db.QueryRow("SELECT "+gradingScaleColumns+" FROM grading_scales WHERE id = ?", id)))
+}
+
+func (s sqlGradingScaleStore) SetDefault(id *uint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE grading_scales SET is_default = 0 WHERE is_default = 1"); err != nil {
+		return err
+	}
+	if id != nil {
+		result, err := tx.Exec("UPDATE grading_scales SET is_default = 1 WHERE id = ?", *id)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return sql.ErrNoRows
+		}
+	}
+	return tx.Commit()
+}
+
+func (s sqlGradingScaleStore) ForSubject(subjectID uint) (GradingScale, error) {
+	// The subject's own scale sorts before the default one
+	return s.withValues(scanGradingScale(s.db.QueryRow(`SELECT `+gradingScaleColumns+` FROM grading_scales
+		WHERE id = (SELECT grading_scale_id FROM subjects WHERE id = ?) OR is_default = 1
+		ORDER BY is_default LIMIT 1`, subjectID)))
+}
+
+type sqlTermStore struct{ db *DB }
+
+func (s sqlTermStore) CreateYear(year AcademicYear) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO academic_years (name, start_date, end_date) VALUES (?, ?, ?)",
+		year.Name, year.StartDate, year.EndDate)
+	return uint(id), err
+}
+
+func (s sqlTermStore) Years() ([]AcademicYear, error) {
+	rows, err := s.db.Query("SELECT id, name, start_date, end_date, archived_at FROM academic_years ORDER BY start_date")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var years []AcademicYear
+	index := map[uint]int{}
+	for rows.Next() {
+		var year AcademicYear
+		if err := rows.Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate, &year.ArchivedAt); err != nil {
+			return nil, err
+		}
+		year.Terms = []Term{}
+		index[year.ID] = len(years)
+		years = append(years, year)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	terms, err := s.terms("1 = 1")
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		if i, ok := index[term.AcademicYearID]; ok {
+			years[i].Terms = append(years[i].Terms, term)
+		}
+	}
+	return years, nil
+}
+
+func (s sqlTermStore) Year(id uint) (AcademicYear, error) {
+	var year AcademicYear
+	err := s.db.QueryRow("SELECT id, name, start_date, end_date, archived_at FROM academic_years WHERE id = ?", id).
+		Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate, &year.ArchivedAt)
+	return year, err
+}
+
+func (s sqlTermStore) CreateTerm(term Term) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO terms (academic_year_id, name, start_date, end_date, proposal_deadline) VALUES (?, ?, ?, ?, ?)",
+		term.AcademicYearID, term.Name, term.StartDate, term.EndDate, term.ProposalDeadline)
+	return uint(id), err
+}
+
+// terms returns the terms matching where, in date order
+func (s sqlTermStore) terms(where string, args ...interface{}) ([]Term, error) {
+	rows, err := s.db.Query("SELECT id, academic_year_id, name, start_date, end_date, proposal_deadline FROM terms WHERE "+where+" ORDER BY start_date", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []Term
+	for rows.Next() {
+		var term Term
+		if err := rows.Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate, &term.ProposalDeadline); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+func (s sqlTermStore) ByID(id uint) (Term, error) {
+	var term Term
+	err := s.db.QueryRow("SELECT id, academic_year_id, name, start_date, end_date, proposal_deadline FROM terms WHERE id = ?", id).
+		Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate, &term.ProposalDeadline)
+	return term, err
+}
+
+func (s sqlTermStore) SetProposalDeadline(id uint, deadline *string) error {
+	result, err := s.db.Exec("UPDATE terms SET proposal_deadline = ? WHERE id = ?", deadline, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTermStore) Current(date string) (Term, error) {
+	// Terms starting later sort first, so the containing term wins over the ones before it
+	var term Term
+	err := s.db.QueryRow("SELECT id, academic_year_id, name, start_date, end_date, proposal_deadline FROM terms WHERE start_date <= ? ORDER BY start_date DESC LIMIT 1", date).
+		Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate, &term.ProposalDeadline)
+	return term, err
+}
+
+func (s sqlTermStore) Containing(date string) (Term, error) {
+	var term Term
+	err := s.db.QueryRow("SELECT id, academic_year_id, name, start_date, end_date, proposal_deadline FROM terms WHERE start_date <= ? AND end_date >= ? ORDER BY start_date DESC LIMIT 1", date, date).
+		Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate, &term.ProposalDeadline)
+	return term, err
+}
+
+func (s sqlTermStore) CurrentYear(date string) (AcademicYear, error) {
+	var year AcademicYear
+	err := s.db.QueryRow("SELECT id, name, start_date, end_date, archived_at FROM academic_years WHERE start_date <= ? ORDER BY start_date DESC LIMIT 1", date).
+		Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate, &year.ArchivedAt)
+	return year, err
+}
+
+func (s sqlTermStore) CreateHoliday(holiday Holiday) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO holidays (name, start_date, end_date) VALUES (?, ?, ?)",
+		holiday.Name, holiday.StartDate, holiday.EndDate)
+	return uint(id), err
+}
+
+func (s sqlTermStore) Holidays(from, to string) ([]Holiday, error) {
+	where, args := "1 = 1", []interface{}{}
+	if from != "" {
+		where += " AND end_date >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		where += " AND start_date <= ?"
+		args = append(args, to)
+	}
+	rows, err := s.db.Query("SELECT id, name, start_date, end_date FROM holidays WHERE "+where+" ORDER BY start_date", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []Holiday{}
+	for rows.Next() {
+		var holiday Holiday
+		if err := rows.Scan(&holiday.ID, &holiday.Name, &holiday.StartDate, &holiday.EndDate); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, rows.Err()
+}
+
+func (s sqlTermStore) DeleteHoliday(id uint) error {
+	result, err := s.db.Exec("DELETE FROM holidays WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTermStore) Rollover(plan RolloverPlan, archivedAt string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range plan.NewClasses {
+		if _, err := tx.InsertID("id", "INSERT INTO classes (name) VALUES (?)", name); err != nil {
+			return err
+		}
+	}
+	// Student memberships made before years existed belong to the year being archived
+	if _, err := tx.Exec("UPDATE class_members SET academic_year_id = ? WHERE academic_year_id IS NULL AND user_id IN (SELECT uid FROM users WHERE role = 'student')",
+		plan.FromYearID); err != nil {
+		return err
+	}
+	for _, move := range plan.Moves {
+		if move.ToClass == "" {
+			continue
+		}
+		if _, err := tx.InsertID("id", "INSERT INTO class_members (user_id, class_name, academic_year_id) VALUES (?, ?, ?)",
+			move.UserID, move.ToClass, plan.ToYearID); err != nil {
+			return err
+		}
+	}
+	for _, draft := range plan.SubjectDrafts {
+		if _, err := tx.InsertID("id", "INSERT INTO subject_drafts (academic_year_id, source_subject_id, name, class_name, teacher_id, grading_scale_id) VALUES (?, ?, ?, ?, ?, ?)",
+			plan.ToYearID, draft.SourceSubjectID, draft.Name, draft.ClassName, draft.TeacherID, draft.GradingScaleID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec("UPDATE academic_years SET archived_at = ? WHERE id = ? AND archived_at IS NULL", archivedAt, plan.FromYearID)
+	if err != nil {
+		return err
+	}
+	// A concurrent rollover of the same year got there first
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+type sqlTermGradeStore struct{ db *DB }
+
+const termGradeColumns = "id, user_id, subject_id, term_id, proposed_grade, proposed_at, final_grade, teacher_id, updated_at, approved_by, approved_at"
+
+func scanTermGrade(row interface{ Scan(...interface{}) error }) (TermGrade, error) {
+	var grade TermGrade
+	err := row.Scan(&grade.ID, &grade.UserID, &grade.SubjectID, &grade.TermID, &grade.ProposedGrade, &grade.ProposedAt,
+		&grade.FinalGrade, &grade.TeacherID, &grade.UpdatedAt, &grade.ApprovedBy, &grade.ApprovedAt)
+	return grade, err
+}
+
+// list returns the term grades matching where, by term, student and subject
+func (s sqlTermGradeStore) list(where string, args ...interface{}) ([]TermGrade, error) {
+	rows, err := s.db.Query("SELECT "+termGradeColumns+" FROM term_grades WHERE "+where+" ORDER BY term_id, user_id, subject_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grades []TermGrade
+	for rows.Next() {
+		grade, err := scanTermGrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		grades = append(grades, grade)
+	}
+	return grades, rows.Err()
+}
+
+func (s sqlTermGradeStore) Find(userID, subjectID, termID uint) (TermGrade, error) {
+	return scanTermGrade(s.db.QueryRow("SELECT "+termGradeColumns+" FROM term_grades WHERE user_id = ? AND subject_id = ? AND term_id = ?",
+		userID, subjectID, termID))
+}
+
+func (s sqlTermGradeStore) ByID(id uint) (TermGrade, error) {
+	return scanTermGrade(s.db.QueryRow("SELECT "+termGradeColumns+" FROM term_grades WHERE id = ?", id))
+}
+
+func (s sqlTermGradeStore) Create(grade TermGrade) (uint, error) {
+	id, err := s.db.InsertID("id", `INSERT INTO term_grades (user_id, subject_id, term_id, proposed_grade, proposed_at, final_grade, teacher_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		grade.UserID, grade.SubjectID, grade.TermID, grade.ProposedGrade, grade.ProposedAt, grade.FinalGrade, grade.TeacherID, grade.UpdatedAt)
+	return uint(id), err
+}
+
+func (s sqlTermGradeStore) Update(grade TermGrade) error {
+	result, err := s.db.Exec(`UPDATE term_grades SET proposed_grade = ?, proposed_at = ?, final_grade = ?, teacher_id = ?, updated_at = ?
+		WHERE id = ? AND approved_at IS NULL`,
+		grade.ProposedGrade, grade.ProposedAt, grade.FinalGrade, grade.TeacherID, grade.UpdatedAt, grade.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTermGradeStore) ListByStudent(userID uint, term *Term) ([]TermGrade, error) {
+	if term == nil {
+		return s.list("user_id = ?", userID)
+	}
+	return s.list("user_id = ? AND term_id = ?", userID, term.ID)
+}
+
+// classStudents selects the students of a class in the year of a term, or without a year.
+// It takes the class name and the term ID.
+const classStudentsQuery = `SELECT user_id FROM class_members WHERE class_name = ?
+	AND (academic_year_id IS NULL OR academic_year_id = (SELECT academic_year_id FROM terms WHERE id = ?))`
+
+func (s sqlTermGradeStore) ListByClass(className string, term *Term) ([]TermGrade, error) {
+	if term == nil {
+		// Each grade counts the students the class had in the year of its own term
+		return s.list(`user_id IN (SELECT user_id FROM class_members WHERE class_name = ?
+			AND (academic_year_id IS NULL OR academic_year_id = (SELECT academic_year_id FROM terms WHERE id = term_grades.term_id)))`, className)
+	}
+	return s.list("term_id = ? AND user_id IN ("+classStudentsQuery+")", term.ID, className, term.ID)
+}
+
+func (s sqlTermGradeStore) Approve(className string, termID uint, subjectID *uint, approverID uint, at string) (int, error) {
+	query := "UPDATE term_grades SET approved_by = ?, approved_at = ? WHERE term_id = ? AND approved_at IS NULL AND final_grade IS NOT NULL AND user_id IN (" + classStudentsQuery + ")"
+	args := []interface{}{approverID, at, termID, className, termID}
+	if subjectID != nil {
+		query += " AND subject_id = ?"
+		args = append(args, *subjectID)
+	}
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+func (s sqlTermGradeStore) Unlock(id uint) error {
+	result, err := s.db.Exec("UPDATE term_grades SET approved_by = NULL, approved_at = NULL WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type sqlExcuseStore struct{ db *DB }
+
+const excuseColumns = "id, user_id, submitted_by, reason, start_date, end_date, status, created_at, reviewed_by, reviewed_at, review_comment"
+
+func scanExcuse(row interface{ Scan(...interface{}) error }) (Excuse, error) {
+	var excuse Excuse
+	err := row.Scan(&excuse.ID, &excuse.UserID, &excuse.SubmittedBy, &excuse.Reason, &excuse.StartDate, &excuse.EndDate,
+		&excuse.Status, &excuse.CreatedAt, &excuse.ReviewedBy, &excuse.ReviewedAt, &excuse.ReviewComment)
+	return excuse, err
+}
+
+// list returns the excuses matching where, newest first, optionally only those with a status
+func (s sqlExcuseStore) list(status, where string, args ...interface{}) ([]Excuse, error) {
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	rows, err := s.db.Query("SELECT "+excuseColumns+" FROM excuses WHERE "+where+" ORDER BY start_date DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	excuses := []Excuse{}
+	for rows.Next() {
+		excuse, err := scanExcuse(rows)
+		if err != nil {
+			return nil, err
+		}
+		excuses = append(excuses, excuse)
+	}
+	return excuses, rows.Err()
+}
+
+func (s sqlExcuseStore) Create(excuse Excuse) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO excuses (user_id, submitted_by, reason, start_date, end_date, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		excuse.UserID, excuse.SubmittedBy, excuse.Reason, excuse.StartDate, excuse.EndDate, excuse.Status, excuse.CreatedAt)
+	return uint(id), err
+}
+
+func (s sqlExcuseStore) ByID(id uint) (Excuse, error) {
+	return scanExcuse(s.db.QueryRow("SELECT "+excuseColumns+" FROM excuses WHERE id = ?", id))
+}
+
+func (s sqlExcuseStore) ListByStudent(userID uint) ([]Excuse, error) {
+	return s.list("", "user_id = ?", userID)
+}
+
+// excuseMembershipYear keeps the class memberships m without a year or in the year current on the excuse's first day,
+// as ClassOf does, so that a class's excuses stay with the students who were in it at the time
+const excuseMembershipYear = `(m.academic_year_id IS NULL OR m.academic_year_id = (SELECT y.id FROM academic_years y
+	WHERE y.start_date <= excuses.start_date ORDER BY y.start_date DESC LIMIT 1))`
+
+func (s sqlExcuseStore) ListByClass(className, status string) ([]Excuse, error) {
+	return s.list(status, "user_id IN (SELECT m.user_id FROM class_members m WHERE m.class_name = ? AND "+excuseMembershipYear+")", className)
+}
+
+func (s sqlExcuseStore) ListByHomeroom(teacherID uint, status string) ([]Excuse, error) {
+	return s.list(status, `user_id IN (SELECT m.user_id FROM class_members m
+		INNER JOIN classes c ON c.name = m.class_name WHERE c.homeroom_teacher_id = ? AND `+excuseMembershipYear+`)`, teacherID)
+}
+
+func (s sqlExcuseStore) Covering(userID uint, date string) (Excuse, error) {
+	return scanExcuse(s.db.QueryRow("SELECT "+excuseColumns+" FROM excuses WHERE user_id = ? AND status = 'approved' AND start_date <= ? AND end_date >= ? ORDER BY id LIMIT 1",
+		userID, date, date))
+}
+
+func (s sqlExcuseStore) Approve(id, reviewerID uint, comment *string, at string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := s.review(tx, id, "approved", reviewerID, comment, at); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`UPDATE attendance SET status = 'excused', excuse_id = ?
+		WHERE status = 'absent' AND user_id = (SELECT user_id FROM excuses WHERE id = ?)
+		AND date >= (SELECT start_date FROM excuses WHERE id = ?) AND date <= (SELECT end_date FROM excuses WHERE id = ?)`, id, id, id, id)
+	if err != nil {
+		return 0, err
+	}
+	excused, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(excused), tx.Commit()
+}
+
+func (s sqlExcuseStore) Reject(id, reviewerID uint, comment *string, at string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.review(tx, id, "rejected", reviewerID, comment, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// review records the decision on a pending excuse, or returns sql.ErrNoRows when it is no longer pending
+func (s sqlExcuseStore) review(tx *Tx, id uint, status string, reviewerID uint, comment *string, at string) error {
+	result, err := tx.Exec("UPDATE excuses SET status = ?, reviewed_by = ?, reviewed_at = ?, review_comment = ? WHERE id = ? AND status = 'pending'",
+		status, reviewerID, at, comment, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type sqlBellScheduleStore struct{ db *DB }
+
+// insertBellPeriods stores the periods of a schedule
+func insertBellPeriods(tx *Tx, scheduleID int64, periods []BellPeriod) error {
+	for _, period := range periods {
+		if _, err := tx.Exec("INSERT INTO bell_periods (schedule_id, class_period, time_start, time_end) VALUES (?, ?, ?, ?)",
+			scheduleID, period.ClassPeriod, period.StartTime, period.EndTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s sqlBellScheduleStore) Create(schedule BellSchedule) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if schedule.IsDefault {
+		if _, err := tx.Exec("UPDATE bell_schedules SET is_default = 0 WHERE is_default = 1"); err != nil {
+			return 0, err
+		}
+	}
+	id, err := tx.InsertID("id", "INSERT INTO bell_schedules (name, is_default) VALUES (?, ?)", schedule.Name, schedule.IsDefault)
+	if err != nil {
+		return 0, err
+	}
+	if err := insertBellPeriods(tx, id, schedule.Periods); err != nil {
+		return 0, err
+	}
+	return uint(id), tx.Commit()
+}
+
+// list returns the schedules matching where with their periods and dates
+func (s sqlBellScheduleStore) list(where string, args ...interface{}) ([]BellSchedule, error) {
+	rows, err := s.db.Query("SELECT id, name, is_default FROM bell_schedules WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []BellSchedule{}
+	index := map[uint]int{}
+	for rows.Next() {
+		schedule := BellSchedule{Periods: []BellPeriod{}, Dates: []BellScheduleDates{}}
+		if err := rows.Scan(&schedule.ID, &schedule.Name, &schedule.IsDefault); err != nil {
+			return nil, err
+		}
+		index[schedule.ID] = len(schedules)
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	periods, err := s.db.Query("SELECT schedule_id, class_period, time_start, time_end FROM bell_periods ORDER BY class_period")
+	if err != nil {
+		return nil, err
+	}
+	defer periods.Close()
+	for periods.Next() {
+		var scheduleID uint
+		var period BellPeriod
+		if err := periods.Scan(&scheduleID, &period.ClassPeriod, &period.StartTime, &period.EndTime); err != nil {
+			return nil, err
+		}
+		if i, ok := index[scheduleID]; ok {
+			schedules[i].Periods = append(schedules[i].Periods, period)
+		}
+	}
+	if err := periods.Err(); err != nil {
+		return nil, err
+	}
+	periods.Close()
+
+	dates, err := s.db.Query("SELECT id, schedule_id, start_date, end_date FROM bell_schedule_dates ORDER BY start_date")
+	if err != nil {
+		return nil, err
+	}
+	defer dates.Close()
+	for dates.Next() {
+		var days BellScheduleDates
+		if err := dates.Scan(&days.ID, &days.ScheduleID, &days.StartDate, &days.EndDate); err != nil {
+			return nil, err
+		}
+		if i, ok := index[days.ScheduleID]; ok {
+			schedules[i].Dates = append(schedules[i].Dates, days)
+		}
+	}
+	return schedules, dates.Err()
+}
+
+func (s sqlBellScheduleStore) List() ([]BellSchedule, error) {
+	return s.list("1 = 1")
+}
+
+func (s sqlBellScheduleStore) ByID(id uint) (BellSchedule, error) {
+	schedules, err := s.list("id = ?", id)
+	if err == nil && len(schedules) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return BellSchedule{}, err
+	}
+	return schedules[0], nil
+}
+
+func (s sqlBellScheduleStore) Update(schedule BellSchedule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE bell_schedules SET name = ? WHERE id = ?", schedule.Name, schedule.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM bell_periods WHERE schedule_id = ?", schedule.ID); err != nil {
+		return err
+	}
+	if err := insertBellPeriods(tx, int64(schedule.ID), schedule.Periods); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s sqlBellScheduleStore) SetDefault(id uint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE bell_schedules SET is_default = 0 WHERE is_default = 1"); err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE bell_schedules SET is_default = 1 WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func (s sqlBellScheduleStore) AddDates(dates BellScheduleDates) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO bell_schedule_dates (schedule_id, start_date, end_date) VALUES (?, ?, ?)",
+		dates.ScheduleID, dates.StartDate, dates.EndDate)
+	return uint(id), err
+}
+
+func (s sqlBellScheduleStore) DeleteDates(id uint) error {
+	result, err := s.db.Exec("DELETE FROM bell_schedule_dates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type sqlPlanningStore struct{ db *DB }
+
+func (s sqlPlanningStore) CreateRoom(room Room) (uint, error) {
+	id, err := s.db.InsertID("id", "INSERT INTO rooms (name, capacity, room_type) VALUES (?, ?, ?)", room.Name, room.Capacity, room.RoomType)
+	return uint(id), err
+}
+
+func (s sqlPlanningStore) Rooms() ([]Room, error) {
+	rows, err := s.db.Query("SELECT id, name, capacity, room_type FROM rooms ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []Room{}
+	for rows.Next() {
+		var room Room
+		if err := rows.Scan(&room.ID, &room.Name, &room.Capacity, &room.RoomType); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// deleteRow removes the row of a table with an ID, or returns sql.ErrNoRows
+func deleteRow(db *DB, table string, id uint) error {
+	result, err := db.Exec("DELETE FROM "+table+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlPlanningStore) DeleteRoom(id uint) error {
+	return deleteRow(s.db, "rooms", id)
+}
+
+func (s sqlPlanningStore) SetRequirement(requirement TeachingRequirement) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM teaching_requirements WHERE subject_id = ?", requirement.SubjectID).Scan(&id)
+	if err == sql.ErrNoRows {
+		id, err = tx.InsertID("id", "INSERT INTO teaching_requirements (subject_id, hours_per_week, max_per_day, room_type) VALUES (?, ?, ?, ?)",
+			requirement.SubjectID, requirement.HoursPerWeek, requirement.MaxPerDay, requirement.RoomType)
+	} else if err == nil {
+		_, err = tx.Exec("UPDATE teaching_requirements SET hours_per_week = ?, max_per_day = ?, room_type = ? WHERE id = ?",
+			requirement.HoursPerWeek, requirement.MaxPerDay, requirement.RoomType, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), tx.Commit()
+}
+
+func (s sqlPlanningStore) Requirements() ([]TeachingRequirement, error) {
+	rows, err := s.db.Query("SELECT id, subject_id, hours_per_week, max_per_day, room_type FROM teaching_requirements ORDER BY subject_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requirements := []TeachingRequirement{}
+	for rows.Next() {
+		var requirement TeachingRequirement
+		if err := rows.Scan(&requirement.ID, &requirement.SubjectID, &requirement.HoursPerWeek, &requirement.MaxPerDay, &requirement.RoomType); err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, rows.Err()
+}
+
+func (s sqlPlanningStore) DeleteRequirement(id uint) error {
+	return deleteRow(s.db, "teaching_requirements", id)
+}
+
+func (s sqlPlanningStore) SetAvailability(availability TeacherAvailability) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM teacher_availability WHERE teacher_id = ? AND day = ? AND class_period = ?",
+		availability.TeacherID, availability.Day, availability.ClassPeriod).Scan(&id)
+	if err == sql.ErrNoRows {
+		id, err = tx.InsertID("id", "INSERT INTO teacher_availability (teacher_id, day, class_period, preference) VALUES (?, ?, ?, ?)",
+			availability.TeacherID, availability.Day, availability.ClassPeriod, availability.Preference)
+	} else if err == nil {
+		_, err = tx.Exec("UPDATE teacher_availability SET preference = ? WHERE id = ?", availability.Preference, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), tx.Commit()
+}
+
+func (s sqlPlanningStore) Availability(teacherID uint) ([]TeacherAvailability, error) {
+	rows, err := s.db.Query("SELECT id, teacher_id, day, class_period, preference FROM teacher_availability WHERE ? = 0 OR teacher_id = ? ORDER BY teacher_id, id",
+		teacherID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	availability := []TeacherAvailability{}
+	for rows.Next() {
+		var period TeacherAvailability
+		if err := rows.Scan(&period.ID, &period.TeacherID, &period.Day, &period.ClassPeriod, &period.Preference); err != nil {
+			return nil, err
+		}
+		availability = append(availability, period)
+	}
+	return availability, rows.Err()
+}
+
+func (s sqlPlanningStore) DeleteAvailability(id uint) error {
+	return deleteRow(s.db, "teacher_availability", id)
+}
+
+type sqlTimetableDraftStore struct{ db *DB }
+
+func (s sqlTimetableDraftStore) Create(draft TimetableDraft) (uint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := tx.InsertID("id", "INSERT INTO timetable_drafts (term_id, days, max_lessons_per_day, allow_gaps, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		draft.TermID, strings.Join(draft.Days, ","), draft.MaxLessonsPerDay, draft.AllowGaps, draft.CreatedBy, draft.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range draft.Entries {
+		var day, classPeriod interface{}
+		if entry.ClassPeriod != 0 {
+			day, classPeriod = entry.Day, entry.ClassPeriod
+		}
+		if _, err := tx.Exec("INSERT INTO timetable_draft_entries (draft_id, subject_id, day, class_period, room, teacher_id, class_name) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, entry.SubjectID, day, classPeriod, nullIfEmpty(entry.Room), entry.TeacherID, entry.ClassName); err != nil {
+			return 0, err
+		}
+	}
+	return uint(id), tx.Commit()
+}
+
+// list returns the drafts matching where, newest first
+func (s sqlTimetableDraftStore) list(where string, args ...interface{}) ([]TimetableDraft, error) {
+	rows, err := s.db.Query(`SELECT id, term_id, days, max_lessons_per_day, allow_gaps, created_by, created_at, published_by, published_at
+		FROM timetable_drafts WHERE `+where+" ORDER BY id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []TimetableDraft{}
+	for rows.Next() {
+		var draft TimetableDraft
+		var days string
+		if err := rows.Scan(&draft.ID, &draft.TermID, &days, &draft.MaxLessonsPerDay, &draft.AllowGaps, &draft.CreatedBy, &draft.CreatedAt,
+			&draft.PublishedBy, &draft.PublishedAt); err != nil {
+			return nil, err
+		}
+		draft.Days = strings.Split(days, ",")
+		drafts = append(drafts, draft)
+	}
+	return drafts, rows.Err()
+}
+
+func (s sqlTimetableDraftStore) List() ([]TimetableDraft, error) {
+	return s.list("1 = 1")
+}
+
+func (s sqlTimetableDraftStore) ByID(id uint) (TimetableDraft, error) {
+	drafts, err := s.list("id = ?", id)
+	if err == nil && len(drafts) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return TimetableDraft{}, err
+	}
+	draft := drafts[0]
+	draft.Entries, err = s.entries("draft_id = ?", id)
+	return draft, err
+}
+
+// entries returns the drafted lessons matching where, the unplaced ones last
+func (s sqlTimetableDraftStore) entries(where string, args ...interface{}) ([]TimetableDraftEntry, error) {
+	rows, err := s.db.Query(`SELECT id, draft_id, subject_id, COALESCE(day, ''), COALESCE(class_period, 0), room, teacher_id, class_name
+		FROM timetable_draft_entries WHERE `+where+" ORDER BY class_period IS NULL, class_name, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimetableDraftEntry{}
+	for rows.Next() {
+		var entry TimetableDraftEntry
+		var room sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.DraftID, &entry.SubjectID, &entry.Day, &entry.ClassPeriod, &room, &entry.TeacherID, &entry.ClassName); err != nil {
+			return nil, err
+		}
+		entry.Room = room.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s sqlTimetableDraftStore) EntryByID(id uint) (TimetableDraftEntry, error) {
+	entries, err := s.entries("id = ?", id)
+	if err == nil && len(entries) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return TimetableDraftEntry{}, err
+	}
+	return entries[0], nil
+}
+
+func (s sqlTimetableDraftStore) UpdateEntry(entry TimetableDraftEntry) error {
+	var day, classPeriod interface{}
+	if entry.ClassPeriod != 0 {
+		day, classPeriod = entry.Day, entry.ClassPeriod
+	}
+	result, err := s.db.Exec("UPDATE timetable_draft_entries SET day = ?, class_period = ?, room = ? WHERE id = ?", day, classPeriod, nullIfEmpty(entry.Room), entry.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s sqlTimetableDraftStore) DeleteEntry(id uint) error {
+	return deleteRow(s.db, "timetable_draft_entries", id)
+}
+
+func (s sqlTimetableDraftStore) Delete(id uint) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM timetable_draft_entries WHERE draft_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM timetable_drafts WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func (s sqlTimetableDraftStore) Publish(id, publishedBy uint, at string, check func() error) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// On SQLite this first write already keeps other writers out until the transaction ends
+	result, err := tx.Exec("UPDATE timetable_drafts SET published_by = ?, published_at = ? WHERE id = ? AND published_at IS NULL", publishedBy, at, id)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	if tx.driver == driverPostgres {
+		if _, err := tx.Exec(`LOCK TABLE timetable, timetable_draft_entries, teacher_availability, rooms, teaching_requirements,
+			class_members, bell_schedules, bell_periods IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return 0, err
+		}
+	}
+	if err := check(); err != nil {
+		return 0, err
+	}
+	result, err = tx.Exec(`INSERT INTO timetable (day, subject_id, class_period, room, teacher_id, class_name, term_id)
+		SELECT timetable_draft_entries.day, timetable_draft_entries.subject_id, timetable_draft_entries.class_period, timetable_draft_entries.room,
+			timetable_draft_entries.teacher_id, timetable_draft_entries.class_name, timetable_drafts.term_id
+		FROM timetable_draft_entries JOIN timetable_drafts ON timetable_drafts.id = timetable_draft_entries.draft_id
+		WHERE timetable_draft_entries.draft_id = ? AND timetable_draft_entries.class_period IS NOT NULL`, id)
+	if err != nil {
+		return 0, err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(added), tx.Commit()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// teacherClassesQuery selects the classes a teacher teaches: classes of their subjects
+// (via subjects.teacher_id or teachers_subjects) and classes they have timetable lessons with.
+// It takes the teacher ID three times.
+const teacherClassesQuery = `SELECT class_name FROM subjects WHERE teacher_id = ?
+	UNION SELECT subjects.class_name FROM teachers_subjects INNER JOIN subjects ON subjects.id = teachers_subjects.subject_id WHERE teachers_subjects.user_id = ?
+	UNION SELECT class_name FROM timetable WHERE teacher_id = ?`
+
+// teacherTeachesSubject reports whether the subject is assigned to the teacher
+func teacherTeachesSubject(teacherID, subjectID uint) (bool, error) {
+	var one int
+	err := db.QueryRow("SELECT 1 FROM subjects WHERE id = ? AND teacher_id = ? UNION SELECT 1 FROM teachers_subjects WHERE subject_id = ? AND user_id = ?",
+		subjectID, teacherID, subjectID, teacherID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// teacherTeachesClass reports whether the class is one the teacher teaches
+func teacherTeachesClass(teacherID uint, className string) (bool, error) {
+	var one int
+	err := db.QueryRow("SELECT 1 FROM classes WHERE name = ? AND name IN ("+teacherClassesQuery+")",
+		className, teacherID, teacherID, teacherID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// teacherTeachesStudent reports whether the student belongs to a class the teacher teaches
+func teacherTeachesStudent(teacherID, studentID uint) (bool, error) {
+	var one int
+	err := db.QueryRow("SELECT 1 FROM class_members WHERE user_id = ? AND class_name IN ("+teacherClassesQuery+") LIMIT 1",
+		studentID, teacherID, teacherID, teacherID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// studentInSubjectClass reports whether the student is a member of the class the subject is taught in
+func studentInSubjectClass(studentID, subjectID uint) (bool, error) {
+	var one int
+	err := db.QueryRow("SELECT 1 FROM class_members INNER JOIN subjects ON subjects.class_name = class_members.class_name WHERE class_members.user_id = ? AND subjects.id = ?",
+		studentID, subjectID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// currentUserID looks up the uid of the authenticated user
+func currentUserID(c *gin.Context) (uint, error) {
+	email, _ := c.Get("email")
+	var uid uint
+	err := db.QueryRow("SELECT uid FROM users WHERE email = ?", email).Scan(&uid)
+	return uid, err
+}
+
+// teacherScope checks a teacher's access with the given predicate. Other roles pass unchecked.
+// On denial or error it writes the response and returns false.
+func teacherScope(c *gin.Context, allowed func(teacherID uint) (bool, error)) bool {
+	if c.GetString("role") != "teacher" {
+		return true
+	}
+	teacherID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return false
+	}
+	ok, err := allowed(teacherID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking permissions"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
+		return false
+	}
+	return true
+}
+
+// requireTeacherSubject allows teachers to act only on subjects assigned to them
+func requireTeacherSubject(c *gin.Context, subjectID uint) bool {
+	return teacherScope(c, func(teacherID uint) (bool, error) {
+		return teacherTeachesSubject(teacherID, subjectID)
+	})
+}
+
+// requireTeacherClass allows teachers to act only on classes they teach
+func requireTeacherClass(c *gin.Context, className string) bool {
+	return teacherScope(c, func(teacherID uint) (bool, error) {
+		return teacherTeachesClass(teacherID, className)
+	})
+}
+
+// requireTeacherStudent allows teachers to act only on students of classes they teach
+func requireTeacherStudent(c *gin.Context, studentID uint) bool {
+	return teacherScope(c, func(teacherID uint) (bool, error) {
+		return teacherTeachesStudent(teacherID, studentID)
+	})
+}
+
+// requireTeacherSubjectStudent allows teachers to act only on subjects assigned to them,
+// for students of the class that subject is taught in
+func requireTeacherSubjectStudent(c *gin.Context, subjectID, studentID uint) bool {
+	return teacherScope(c, func(teacherID uint) (bool, error) {
+		ok, err := teacherTeachesSubject(teacherID, subjectID)
+		if err != nil || !ok {
+			return false, err
+		}
+		return studentInSubjectClass(studentID, subjectID)
+	})
+}
+
+// requireTeacherAccount checks that a user ID given in a request belongs to a teacher.
+// It writes the error response itself and returns false otherwise.
+func requireTeacherAccount(c *gin.Context, userID uint) bool {
+	teacher, err := store.Users.ByID(userID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teacher"})
+		return false
+	}
+	if err == sql.ErrNoRows || teacher.Role != "teacher" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Teacher ID must belong to a teacher"})
+		return false
+	}
+	return true
+}
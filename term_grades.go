@@ -0,0 +1,314 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireHomeroom allows teachers to act only on the class they are homeroom teacher of, denying others with message.
+// Other roles pass unchecked. On denial or error it writes the response and returns false.
+func requireHomeroom(c *gin.Context, class Class, message string) bool {
+	if c.GetString("role") != "teacher" {
+		return true
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return false
+	}
+	if class.HomeroomTeacherID == nil || *class.HomeroomTeacherID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"message": message})
+		return false
+	}
+	return true
+}
+
+// classParam loads the class named by the :name path parameter.
+// It writes the error response itself and returns false when the class cannot be loaded.
+func classParam(c *gin.Context) (Class, bool) {
+	class, err := store.Classes.ByName(c.Param("name"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Class not found"})
+		return class, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+		return class, false
+	}
+	return class, true
+}
+
+func SetClassHomeroom(c *gin.Context) {
+	class, ok := classParam(c)
+	if !ok {
+		return
+	}
+	var change Class
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if change.HomeroomTeacherID != nil && !requireTeacherAccount(c, *change.HomeroomTeacherID) {
+		return
+	}
+
+	if err := store.Classes.SetHomeroom(class.Name, change.HomeroomTeacherID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating class"})
+		return
+	}
+	updated := class
+	updated.HomeroomTeacherID = change.HomeroomTeacherID
+	recordAudit(c, "update", "class", class.ID, class, updated)
+	c.JSON(http.StatusOK, gin.H{"message": "Homeroom teacher updated successfully"})
+}
+
+func SetTermGrade(c *gin.Context) {
+	var grade TermGrade
+	if err := c.ShouldBindJSON(&grade); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if grade.UserID == 0 || grade.SubjectID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "User ID and subject ID are required"})
+		return
+	}
+	if grade.ProposedGrade == nil && grade.FinalGrade == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Proposed or final grade is required"})
+		return
+	}
+
+	term, ok := loadTerm(c, grade.TermID)
+	if !ok {
+		return
+	}
+	if !requireTeacherSubjectStudent(c, grade.SubjectID, grade.UserID) {
+		return
+	}
+	if !requireOpenYear(c, term.StartDate) {
+		return
+	}
+	if grade.ProposedGrade != nil {
+		// Admins may still correct a proposal after the deadline
+		if c.GetString("role") == "teacher" && term.ProposalDeadline != nil && today() > *term.ProposalDeadline {
+			c.JSON(http.StatusConflict, gin.H{"message": "Proposal deadline has passed"})
+			return
+		}
+		if !requireGradeOnScale(c, grade.SubjectID, "numeric", grade.ProposedGrade) {
+			return
+		}
+	}
+	if grade.FinalGrade != nil && !requireGradeOnScale(c, grade.SubjectID, "numeric", grade.FinalGrade) {
+		return
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	now := formatTimestamp(time.Now())
+
+	existing, err := store.TermGrades.Find(grade.UserID, grade.SubjectID, term.ID)
+	if err == sql.ErrNoRows {
+		created := TermGrade{
+			UserID:        grade.UserID,
+			SubjectID:     grade.SubjectID,
+			TermID:        term.ID,
+			ProposedGrade: grade.ProposedGrade,
+			FinalGrade:    grade.FinalGrade,
+			TeacherID:     uid,
+			UpdatedAt:     now,
+		}
+		if created.ProposedGrade != nil {
+			created.ProposedAt = &now
+		}
+		id, err := store.TermGrades.Create(created)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving term grade"})
+			return
+		}
+		created.ID = id
+		recordAudit(c, "create", "term_grade", created.ID, nil, created)
+		c.JSON(http.StatusCreated, gin.H{"message": "Term grade saved successfully", "id": created.ID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term grade"})
+		return
+	}
+	if existing.ApprovedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "Term grade is approved and locked"})
+		return
+	}
+
+	updated := existing
+	if grade.ProposedGrade != nil {
+		updated.ProposedGrade = grade.ProposedGrade
+		updated.ProposedAt = &now
+	}
+	if grade.FinalGrade != nil {
+		updated.FinalGrade = grade.FinalGrade
+	}
+	updated.TeacherID = uid
+	updated.UpdatedAt = now
+	err = store.TermGrades.Update(updated)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"message": "Term grade is approved and locked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving term grade"})
+		return
+	}
+	recordAudit(c, "update", "term_grade", existing.ID, existing, updated)
+	c.JSON(http.StatusOK, gin.H{"message": "Term grade saved successfully", "id": existing.ID})
+}
+
+func ApproveTermGrades(c *gin.Context) {
+	var approval TermGradeApproval
+	if err := c.ShouldBindJSON(&approval); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if approval.ClassName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required"})
+		return
+	}
+	class, err := store.Classes.ByName(approval.ClassName)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Class not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+		return
+	}
+	var termID uint
+	if approval.TermID != nil {
+		termID = *approval.TermID
+	}
+	term, ok := loadTerm(c, termID)
+	if !ok {
+		return
+	}
+	if !requireHomeroom(c, class, "Only the homeroom teacher can approve term grades") {
+		return
+	}
+	if !requireOpenYear(c, term.StartDate) {
+		return
+	}
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	approved, err := store.TermGrades.Approve(class.Name, term.ID, approval.SubjectID, uid, formatTimestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error approving term grades"})
+		return
+	}
+	approval.TermID = &term.ID
+	recordAudit(c, "approve", "term_grades", class.Name, nil, approval)
+	c.JSON(http.StatusOK, gin.H{"message": "Term grades approved successfully", "approved": approved})
+}
+
+func UnlockTermGrade(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid term grade ID"})
+		return
+	}
+	grade, err := store.TermGrades.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Term grade not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term grade"})
+		return
+	}
+	if grade.ApprovedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "Term grade is not approved"})
+		return
+	}
+	term, err := store.Terms.ByID(grade.TermID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return
+	}
+	if !requireOpenYear(c, term.StartDate) {
+		return
+	}
+
+	if err := store.TermGrades.Unlock(grade.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error unlocking term grade"})
+		return
+	}
+	unlocked := grade
+	unlocked.ApprovedBy = nil
+	unlocked.ApprovedAt = nil
+	recordAudit(c, "unlock", "term_grade", grade.ID, grade, unlocked)
+	c.JSON(http.StatusOK, gin.H{"message": "Term grade unlocked successfully"})
+}
+
+func GetClassTermGrades(c *gin.Context) {
+	className := c.Query("class_name")
+	if className == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Class name is required"})
+		return
+	}
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	if !requireTeacherClass(c, className) {
+		return
+	}
+	grades, err := store.TermGrades.ListByClass(className, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term grades"})
+		return
+	}
+	c.JSON(http.StatusOK, grades)
+}
+
+// respondTermGrades writes a student's term grades as students and parents see them:
+// proposals as soon as they are made, final grades only once approved.
+func respondTermGrades(c *gin.Context, userID uint) {
+	term, ok := termParam(c)
+	if !ok {
+		return
+	}
+	grades, err := store.TermGrades.ListByStudent(userID, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term grades"})
+		return
+	}
+	for i := range grades {
+		if grades[i].ApprovedAt == nil {
+			grades[i].FinalGrade = nil
+		}
+	}
+	c.JSON(http.StatusOK, grades)
+}
+
+func GetTermGrades(c *gin.Context) {
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	respondTermGrades(c, uid)
+}
+
+func GetChildTermGrades(c *gin.Context) {
+	studentID, ok := linkedStudentID(c)
+	if !ok {
+		return
+	}
+	respondTermGrades(c, studentID)
+}
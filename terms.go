@@ -0,0 +1,415 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// today returns the current date in YYYY-MM-DD format
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// termParam returns the term selected with ?term_id=, defaulting to the current term.
+// ?term_id=all, or a school without terms, selects every term (nil).
+// It writes the error response itself and returns false when the term cannot be loaded.
+func termParam(c *gin.Context) (*Term, bool) {
+	value := c.Query("term_id")
+	if value == "all" {
+		return nil, true
+	}
+	var term Term
+	var err error
+	if value == "" {
+		term, err = store.Terms.Current(today())
+		if err == sql.ErrNoRows {
+			return nil, true
+		}
+	} else {
+		id, parseErr := strconv.ParseUint(value, 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid term_id"})
+			return nil, false
+		}
+		term, err = store.Terms.ByID(uint(id))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Term not found"})
+			return nil, false
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return nil, false
+	}
+	return &term, true
+}
+
+// yearParam returns the school year selected with ?academic_year_id=, defaulting to the current year.
+// ?academic_year_id=all, or a school without years, selects every year (nil).
+// It writes the error response itself and returns false when the year cannot be loaded.
+func yearParam(c *gin.Context) (*uint, bool) {
+	value := c.Query("academic_year_id")
+	if value == "all" {
+		return nil, true
+	}
+	var id *uint
+	if value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid academic_year_id"})
+			return nil, false
+		}
+		yearID := uint(parsed)
+		id = &yearID
+	}
+	if !resolveYearID(c, &id) {
+		return nil, false
+	}
+	return id, true
+}
+
+// resolveYearID checks that a given school year exists, or fills in the current one when id is nil.
+// It stays nil when the school has no years. It writes the error response itself and returns false on failure.
+func resolveYearID(c *gin.Context, id **uint) bool {
+	var year AcademicYear
+	var err error
+	if *id == nil {
+		year, err = store.Terms.CurrentYear(today())
+		if err == sql.ErrNoRows {
+			return true
+		}
+	} else {
+		year, err = store.Terms.Year(**id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Academic year not found"})
+			return false
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic year"})
+		return false
+	}
+	*id = &year.ID
+	return true
+}
+
+// resolveTermID checks that a given term exists, or fills in the current one when id is nil.
+// It stays nil when the school has no terms. It writes the error response itself and returns false on failure.
+func resolveTermID(c *gin.Context, id **uint) bool {
+	var term Term
+	var err error
+	if *id == nil {
+		term, err = store.Terms.Current(today())
+		if err == sql.ErrNoRows {
+			return true
+		}
+	} else {
+		term, err = store.Terms.ByID(**id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Term not found"})
+			return false
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return false
+	}
+	*id = &term.ID
+	return true
+}
+
+// loadTerm returns the given term, or the current one when id is 0.
+// It writes the error response itself and returns false when there is no such term.
+func loadTerm(c *gin.Context, id uint) (Term, bool) {
+	var termID *uint
+	if id != 0 {
+		termID = &id
+	}
+	if !resolveTermID(c, &termID) {
+		return Term{}, false
+	}
+	if termID == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No current term"})
+		return Term{}, false
+	}
+	term, err := store.Terms.ByID(*termID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return Term{}, false
+	}
+	return term, true
+}
+
+// validProposalDeadline reports whether a term's proposal deadline is a date within the term
+func validProposalDeadline(term Term) bool {
+	deadline := term.ProposalDeadline
+	return deadline == nil || (validDate(*deadline) && *deadline >= term.StartDate && *deadline <= term.EndDate)
+}
+
+// overlaps reports whether two inclusive YYYY-MM-DD ranges share a day
+func overlaps(start1, end1, start2, end2 string) bool {
+	return start1 <= end2 && start2 <= end1
+}
+
+func AddAcademicYear(c *gin.Context) {
+	var year AcademicYear
+	if err := c.ShouldBindJSON(&year); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	year.Name = strings.TrimSpace(year.Name)
+	if year.Name == "" || year.StartDate == "" || year.EndDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name, start date, and end date are required"})
+		return
+	}
+	if !validDate(year.StartDate) || !validDate(year.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if year.EndDate <= year.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must be after start date"})
+		return
+	}
+
+	years, err := store.Terms.Years()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic years"})
+		return
+	}
+	for _, existing := range years {
+		if existing.Name == year.Name {
+			c.JSON(http.StatusConflict, gin.H{"message": "Academic year already exists"})
+			return
+		}
+		if overlaps(existing.StartDate, existing.EndDate, year.StartDate, year.EndDate) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Academic year overlaps " + existing.Name})
+			return
+		}
+	}
+
+	id, err := store.Terms.CreateYear(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving academic year"})
+		return
+	}
+	year.ID = id
+	year.Terms = nil
+	recordAudit(c, "create", "academic_year", year.ID, nil, year)
+	c.JSON(http.StatusCreated, gin.H{"message": "Academic year created successfully", "id": year.ID})
+}
+
+func AddTerm(c *gin.Context) {
+	var term Term
+	if err := c.ShouldBindJSON(&term); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	term.Name = strings.TrimSpace(term.Name)
+	if term.AcademicYearID == 0 || term.Name == "" || term.StartDate == "" || term.EndDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Academic year ID, name, start date, and end date are required"})
+		return
+	}
+	if !validDate(term.StartDate) || !validDate(term.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if term.EndDate <= term.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must be after start date"})
+		return
+	}
+	if !validProposalDeadline(term) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Proposal deadline must be a date within the term"})
+		return
+	}
+
+	years, err := store.Terms.Years()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic years"})
+		return
+	}
+	var year *AcademicYear
+	for i := range years {
+		if years[i].ID == term.AcademicYearID {
+			year = &years[i]
+		}
+	}
+	if year == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Academic year not found"})
+		return
+	}
+	if term.StartDate < year.StartDate || term.EndDate > year.EndDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Term must lie within its academic year"})
+		return
+	}
+	for _, existing := range year.Terms {
+		if existing.Name == term.Name {
+			c.JSON(http.StatusConflict, gin.H{"message": "Term already exists"})
+			return
+		}
+		if overlaps(existing.StartDate, existing.EndDate, term.StartDate, term.EndDate) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Term overlaps " + existing.Name})
+			return
+		}
+	}
+
+	id, err := store.Terms.CreateTerm(term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving term"})
+		return
+	}
+	term.ID = id
+	recordAudit(c, "create", "term", term.ID, nil, term)
+	c.JSON(http.StatusCreated, gin.H{"message": "Term created successfully", "id": term.ID})
+}
+
+func SetTermProposalDeadline(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid term ID"})
+		return
+	}
+	term, err := store.Terms.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Term not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return
+	}
+
+	var change Term
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	updated := term
+	updated.ProposalDeadline = change.ProposalDeadline
+	if !validProposalDeadline(updated) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Proposal deadline must be a date within the term"})
+		return
+	}
+
+	if err := store.Terms.SetProposalDeadline(term.ID, updated.ProposalDeadline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating term"})
+		return
+	}
+	recordAudit(c, "update", "term", term.ID, term, updated)
+	c.JSON(http.StatusOK, gin.H{"message": "Proposal deadline updated successfully"})
+}
+
+func GetAcademicYears(c *gin.Context) {
+	years, err := store.Terms.Years()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving academic years"})
+		return
+	}
+	current, err := store.Terms.Current(today())
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return
+	}
+	var currentTerm *Term
+	if err == nil {
+		currentTerm = &current
+	}
+	c.JSON(http.StatusOK, gin.H{"academic_years": years, "current_term": currentTerm})
+}
+
+func AddHoliday(c *gin.Context) {
+	var holiday Holiday
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	holiday.Name = strings.TrimSpace(holiday.Name)
+	if holiday.EndDate == "" {
+		holiday.EndDate = holiday.StartDate
+	}
+	if holiday.Name == "" || holiday.StartDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name and start date are required"})
+		return
+	}
+	if !validDate(holiday.StartDate) || !validDate(holiday.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	if holiday.EndDate < holiday.StartDate {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "End date must not be before start date"})
+		return
+	}
+
+	existing, err := store.Terms.Holidays(holiday.StartDate, holiday.EndDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving holidays"})
+		return
+	}
+	if len(existing) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "Holiday overlaps " + existing[0].Name})
+		return
+	}
+
+	id, err := store.Terms.CreateHoliday(holiday)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving holiday"})
+		return
+	}
+	holiday.ID = id
+	recordAudit(c, "create", "holiday", holiday.ID, nil, holiday)
+	c.JSON(http.StatusCreated, gin.H{"message": "Holiday created successfully", "id": holiday.ID})
+}
+
+func DeleteHoliday(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid holiday ID"})
+		return
+	}
+	holidays, err := store.Terms.Holidays("", "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving holidays"})
+		return
+	}
+	var before *Holiday
+	for i := range holidays {
+		if holidays[i].ID == uint(id) {
+			before = &holidays[i]
+		}
+	}
+	if before == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Holiday not found"})
+		return
+	}
+
+	err = store.Terms.DeleteHoliday(before.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Holiday not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting holiday"})
+		return
+	}
+	recordAudit(c, "delete", "holiday", before.ID, *before, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted successfully"})
+}
+
+// GetHolidays lists the holidays, optionally only those sharing a day with ?from= and ?to=
+func GetHolidays(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if (from != "" && !validDate(from)) || (to != "" && !validDate(to)) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	holidays, err := store.Terms.Holidays(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving holidays"})
+		return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
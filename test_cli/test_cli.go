@@ -5,7 +5,9 @@ import (
     "bytes"
     "encoding/json"
     "fmt"
+    "io"
     "net/http"
+    "net/url"
     "os"
     "strings"
 )
@@ -13,27 +15,42 @@ import (
 const baseURL = "http://localhost:10800/api"
 
 var token string
+var refreshToken string
 
 func main() {
     reader := bufio.NewReader(os.Stdin)
     fmt.Println("== Mercury Backend CLI ==")
 
     for {
-        fmt.Print("\nChoose option [login, timetable, change-password, register-user, add-timetable, add-grade, delete-account, ping, get-grades, get-user-info, get-subjects, add-attendance, get-lucky-number, get-exams, get-attendance, get-class-members, get-student-grades, get-student-attendance, get-student-info, add-exam, add-class, add-subject, add-class-member, quit]: ")
+        fmt.Print("\nChoose option [login, refresh, logout, enroll-2fa, confirm-2fa, request-password-reset, confirm-password-reset, timetable, change-password, register-user, add-timetable, edit-timetable, add-grade, delete-account, ping, get-grades, get-user-info, get-subjects, add-attendance, get-lucky-number, get-exams, get-attendance, get-class-members, get-student-grades, get-student-attendance, get-student-info, add-exam, add-class, add-subject, add-class-member, link-guardian, get-children, get-child-data, unlock-login, get-audit-log, edit-grade, delete-grade, get-grade-history, get-averages, get-grading-scales, set-subject-scale, add-academic-year, add-term, get-academic-years, rollover, get-subject-drafts, apply-subject-drafts, set-homeroom, set-term-grade, approve-term-grades, get-term-grades, get-report-card, get-class-report-cards, submit-excuse, get-excuses, review-excuse, take-lesson-attendance, get-attendance-stats, get-attendance-alerts, add-holiday, create-calendar-feed, revoke-calendar-feed, add-timetable-override, delete-timetable-override, get-substitutions, add-bell-schedule, edit-bell-schedule, set-default-bell-schedule, add-bell-schedule-dates, delete-bell-schedule-dates, get-bell-schedules, add-room, set-teaching-requirement, set-teacher-availability, generate-timetable, get-timetable-draft, move-draft-entry, publish-timetable-draft, quit]: ")
         choice, _ := reader.ReadString('\n')
         choice = strings.TrimSpace(choice)
 
         switch choice {
         case "login":
             login(reader)
+        case "enroll-2fa":
+            enrollTwoFactor()
+        case "confirm-2fa":
+            confirmTwoFactor(reader)
+        case "refresh":
+            refresh()
+        case "logout":
+            logout()
+        case "request-password-reset":
+            requestPasswordReset(reader)
+        case "confirm-password-reset":
+            confirmPasswordReset(reader)
         case "timetable":
-            getTimetable()
+            getTimetable(reader)
         case "change-password":
             changePassword(reader)
         case "register-user":
             registerUser(reader)
         case "add-timetable":
             addTimetableEntry(reader)
+        case "edit-timetable":
+            editTimetableEntry(reader)
         case "add-grade":
             addGrade(reader)
         case "delete-account":
@@ -70,6 +87,102 @@ func main() {
             addSubject(reader)
         case "add-class-member":
             addClassMember(reader)
+        case "link-guardian":
+            linkGuardian(reader)
+        case "get-children":
+            getChildren()
+        case "get-child-data":
+            getChildData(reader)
+        case "unlock-login":
+            unlockLogin(reader)
+        case "get-audit-log":
+            getAuditLog(reader)
+        case "edit-grade":
+            editGrade(reader)
+        case "delete-grade":
+            deleteGrade(reader)
+        case "get-grade-history":
+            getGradeHistory(reader)
+        case "get-averages":
+            getAverages()
+        case "get-grading-scales":
+            getGradingScales()
+        case "set-subject-scale":
+            setSubjectScale(reader)
+        case "add-academic-year":
+            addAcademicYear(reader)
+        case "add-term":
+            addTerm(reader)
+        case "get-academic-years":
+            getAcademicYears()
+        case "rollover":
+            rollover(reader)
+        case "get-subject-drafts":
+            getSubjectDrafts(reader)
+        case "apply-subject-drafts":
+            applySubjectDrafts(reader)
+        case "set-homeroom":
+            setHomeroom(reader)
+        case "set-term-grade":
+            setTermGrade(reader)
+        case "approve-term-grades":
+            approveTermGrades(reader)
+        case "get-term-grades":
+            getTermGrades()
+        case "get-report-card":
+            getReportCard(reader)
+        case "get-class-report-cards":
+            getClassReportCards(reader)
+        case "submit-excuse":
+            submitExcuse(reader)
+        case "get-excuses":
+            getExcuses()
+        case "review-excuse":
+            reviewExcuse(reader)
+        case "take-lesson-attendance":
+            takeLessonAttendance(reader)
+        case "get-attendance-stats":
+            getAttendanceStats(reader)
+        case "get-attendance-alerts":
+            getAttendanceAlerts(reader)
+        case "add-holiday":
+            addHoliday(reader)
+        case "create-calendar-feed":
+            createCalendarFeed()
+        case "revoke-calendar-feed":
+            revokeCalendarFeed()
+        case "add-timetable-override":
+            addTimetableOverride(reader)
+        case "delete-timetable-override":
+            deleteTimetableOverride(reader)
+        case "get-substitutions":
+            getSubstitutions(reader)
+        case "add-bell-schedule":
+            addBellSchedule(reader)
+        case "edit-bell-schedule":
+            editBellSchedule(reader)
+        case "set-default-bell-schedule":
+            setDefaultBellSchedule(reader)
+        case "add-bell-schedule-dates":
+            addBellScheduleDates(reader)
+        case "delete-bell-schedule-dates":
+            deleteBellScheduleDates(reader)
+        case "get-bell-schedules":
+            getBellSchedules(reader)
+        case "add-room":
+            addRoom(reader)
+        case "set-teaching-requirement":
+            setTeachingRequirement(reader)
+        case "set-teacher-availability":
+            setTeacherAvailability(reader)
+        case "generate-timetable":
+            generateTimetable(reader)
+        case "get-timetable-draft":
+            getTimetableDraft(reader)
+        case "move-draft-entry":
+            moveDraftEntry(reader)
+        case "publish-timetable-draft":
+            publishTimetableDraft(reader)
         case "quit":
             fmt.Println("Goodbye!")
             return
@@ -98,24 +211,221 @@ func login(reader *bufio.Reader) {
     }
     defer resp.Body.Close()
 
-    var result map[string]string
+    var result map[string]interface{}
     json.NewDecoder(resp.Body).Decode(&result)
 
+    if resp.StatusCode == 200 && result["two_factor_required"] == true {
+        fmt.Print("Authenticator code (or recovery code): ")
+        code, _ := reader.ReadString('\n')
+        code = strings.TrimSpace(code)
+        data := map[string]string{"challenge_token": fmt.Sprint(result["challenge_token"])}
+        if len(code) == 6 {
+            data["code"] = code
+        } else {
+            data["recovery_code"] = code
+        }
+        body, _ = json.Marshal(data)
+
+        resp2, err := http.Post(baseURL+"/login/2fa", "application/json", bytes.NewBuffer(body))
+        if err != nil {
+            fmt.Println("Login error:", err)
+            return
+        }
+        defer resp2.Body.Close()
+        resp = resp2
+        result = map[string]interface{}{}
+        json.NewDecoder(resp.Body).Decode(&result)
+    }
+
     if resp.StatusCode == 200 {
-        token = result["token"]
+        token, _ = result["token"].(string)
+        refreshToken, _ = result["refresh_token"].(string)
         fmt.Println("Logged in successfully.")
     } else {
         fmt.Println("Error:", result["message"])
     }
 }
 
-func getTimetable() {
+func enrollTwoFactor() {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    req, _ := http.NewRequest("POST", baseURL+"/2fa/enroll", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    if resp.StatusCode != 200 {
+        fmt.Println("Error:", result["message"])
+        return
+    }
+    fmt.Println("Secret:", result["secret"])
+    fmt.Println("URI:", result["uri"])
+    fmt.Println("Add it to your authenticator app, then run confirm-2fa.")
+}
+
+func confirmTwoFactor(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    fmt.Print("Authenticator code: ")
+    code, _ := reader.ReadString('\n')
+    body, _ := json.Marshal(map[string]string{"code": strings.TrimSpace(code)})
+
+    req, _ := http.NewRequest("POST", baseURL+"/2fa/confirm", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if codes, ok := result["recovery_codes"].([]interface{}); ok {
+        fmt.Println("Recovery codes (store them safely, they are shown only once):")
+        for _, code := range codes {
+            fmt.Println(" ", code)
+        }
+    }
+}
+
+func refresh() {
+    if refreshToken == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+
+    resp, err := http.Post(baseURL+"/refresh", "application/json", bytes.NewBuffer(body))
+    if err != nil {
+        fmt.Println("Refresh error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    if resp.StatusCode == 200 {
+        token, _ = result["token"].(string)
+        refreshToken, _ = result["refresh_token"].(string)
+        fmt.Println("Token refreshed.")
+    } else {
+        fmt.Println("Error:", result["message"])
+    }
+}
+
+func logout() {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    req, _ := http.NewRequest("POST", baseURL+"/logout", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    if resp.StatusCode == 200 {
+        token = ""
+        refreshToken = ""
+    }
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func requestPasswordReset(reader *bufio.Reader) {
+    fmt.Print("Email: ")
+    email, _ := reader.ReadString('\n')
+
+    body, _ := json.Marshal(map[string]string{"email": strings.TrimSpace(email)})
+
+    resp, err := http.Post(baseURL+"/password-reset/request", "application/json", bytes.NewBuffer(body))
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func confirmPasswordReset(reader *bufio.Reader) {
+    fmt.Print("Reset token: ")
+    resetToken, _ := reader.ReadString('\n')
+    fmt.Print("New password: ")
+    newPass, _ := reader.ReadString('\n')
+
+    data := map[string]string{
+        "token":        strings.TrimSpace(resetToken),
+        "new_password": strings.TrimSpace(newPass),
+    }
+    body, _ := json.Marshal(data)
+
+    resp, err := http.Post(baseURL+"/password-reset/confirm", "application/json", bytes.NewBuffer(body))
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getTimetable(reader *bufio.Reader) {
     if token == "" {
         fmt.Println("Please login first.")
         return
     }
 
-    req, _ := http.NewRequest("GET", baseURL+"/timetable", nil)
+    fmt.Print("Week (any date YYYY-MM-DD, empty for the weekly plan): ")
+    week, _ := reader.ReadString('\n')
+    week = strings.TrimSpace(week)
+    requestURL := baseURL + "/timetable"
+    if week != "" {
+        requestURL += "?week=" + url.QueryEscape(week)
+    }
+
+    req, _ := http.NewRequest("GET", requestURL, nil)
     req.Header.Set("Authorization", "Bearer "+token)
 
     client := &http.Client{}
@@ -138,9 +448,15 @@ func getTimetable() {
 
     fmt.Println("\n--- Timetable ---")
     for _, entry := range timetable {
-        fmt.Printf("ID: %v | Day: %s | Start: %s | End: %s | Room: %s | Subject ID: %v | Teacher ID: %v | Class: %s\n",
-            entry["id"], entry["day"], entry["time_start"], entry["time_end"],
+        if week != "" {
+            fmt.Printf("%s ", entry["date"])
+        }
+        fmt.Printf("ID: %v | Day: %s | Period: %v | Start: %s | End: %s | Room: %s | Subject ID: %v | Teacher ID: %v | Class: %s\n",
+            entry["id"], entry["day"], entry["class_period"], entry["start_time"], entry["end_time"],
             entry["room"], entry["subject_id"], entry["teacher_id"], entry["class_name"])
+        if changes, ok := entry["changes"].([]interface{}); ok && len(changes) > 0 {
+            fmt.Printf("    Changed: %v %v\n", changes, entry["note"])
+        }
     }
 }
 
@@ -191,7 +507,7 @@ func registerUser(reader *bufio.Reader) {
     email, _ := reader.ReadString('\n')
     fmt.Print("Password: ")
     password, _ := reader.ReadString('\n')
-    fmt.Print("Role [student, teacher, admin]: ")
+    fmt.Print("Role [student, teacher, admin, parent]: ")
     role, _ := reader.ReadString('\n')
     fmt.Print("First name: ")
     firstName, _ := reader.ReadString('\n')
@@ -247,13 +563,34 @@ func addTimetableEntry(reader *bufio.Reader) {
     }
 
     fmt.Println("== Add Timetable Entry ==")
+    data := readTimetableEntry(reader)
+    sendTimetableEntry(reader, "POST", baseURL+"/admin/timetable", data)
+}
+
+func editTimetableEntry(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Edit Timetable Entry ==")
+    fmt.Print("Timetable entry ID: ")
+    entryID, _ := reader.ReadString('\n')
+    data := readTimetableEntry(reader)
+    sendTimetableEntry(reader, "PUT", baseURL+"/admin/timetable/"+strings.TrimSpace(entryID), data)
+}
+
+// readTimetableEntry asks for the fields of a timetable entry
+func readTimetableEntry(reader *bufio.Reader) map[string]interface{} {
     fmt.Print("Day (e.g. Monday): ")
     day, _ := reader.ReadString('\n')
     fmt.Print("Subject ID (number): ")
     subjectIDStr, _ := reader.ReadString('\n')
-    fmt.Print("Start Time (e.g. 08:00): ")
+    fmt.Print("Class Period (number): ")
+    classPeriodStr, _ := reader.ReadString('\n')
+    fmt.Print("Start Time (e.g. 08:00, empty for the bell schedule): ")
     start, _ := reader.ReadString('\n')
-    fmt.Print("End Time (e.g. 09:30): ")
+    fmt.Print("End Time (e.g. 09:30, empty for the bell schedule): ")
     end, _ := reader.ReadString('\n')
     fmt.Print("Room: ")
     room, _ := reader.ReadString('\n')
@@ -262,18 +599,28 @@ func addTimetableEntry(reader *bufio.Reader) {
     fmt.Print("Class Name: ")
     className, _ := reader.ReadString('\n')
 
-    data := map[string]interface{}{
-        "day":         strings.TrimSpace(day),
-        "subject_id":  toInt(subjectIDStr),
-        "time_start":  strings.TrimSpace(start),
-        "time_end":    strings.TrimSpace(end),
-        "room":        strings.TrimSpace(room),
-        "teacher_id":  toInt(teacherIDStr),
-        "class_name":  strings.TrimSpace(className),
+    return map[string]interface{}{
+        "day":          strings.TrimSpace(day),
+        "subject_id":   toInt(subjectIDStr),
+        "class_period": toInt(classPeriodStr),
+        "start_time":   strings.TrimSpace(start),
+        "end_time":     strings.TrimSpace(end),
+        "room":         strings.TrimSpace(room),
+        "teacher_id":   toInt(teacherIDStr),
+        "class_name":   strings.TrimSpace(className),
+    }
+}
+
+// sendTimetableEntry saves a timetable entry, or only checks it for conflicts on a dry run
+func sendTimetableEntry(reader *bufio.Reader, method, requestURL string, data map[string]interface{}) {
+    fmt.Print("Dry run, only check for conflicts (y/N): ")
+    dryRun, _ := reader.ReadString('\n')
+    if strings.EqualFold(strings.TrimSpace(dryRun), "y") {
+        requestURL += "?dry_run=true"
     }
 
     body, _ := json.Marshal(data)
-    req, _ := http.NewRequest("POST", baseURL+"/admin/timetable", bytes.NewBuffer(body))
+    req, _ := http.NewRequest(method, requestURL, bytes.NewBuffer(body))
     req.Header.Set("Authorization", "Bearer "+token)
     req.Header.Set("Content-Type", "application/json")
 
@@ -285,11 +632,17 @@ func addTimetableEntry(reader *bufio.Reader) {
     }
     defer resp.Body.Close()
 
-    var result map[string]string
+    var result map[string]interface{}
     json.NewDecoder(resp.Body).Decode(&result)
 
     fmt.Println("Status:", resp.StatusCode)
     fmt.Println("Message:", result["message"])
+    conflicts, _ := result["conflicts"].([]interface{})
+    for _, item := range conflicts {
+        conflict, _ := item.(map[string]interface{})
+        entry, _ := conflict["entry"].(map[string]interface{})
+        fmt.Printf("Conflict with entry %v (%v %v-%v, class %v, room %v, teacher %v): %v\n", entry["id"], entry["day"], entry["start_time"], entry["end_time"], entry["class_name"], entry["room"], entry["teacher_id"], conflict["reasons"])
+    }
 }
 
 func addGrade(reader *bufio.Reader) {
@@ -307,6 +660,8 @@ func addGrade(reader *bufio.Reader) {
     grade, _ := reader.ReadString('\n')
     fmt.Print("Grade Type ('numeric', 'comment', 'custom'): ")
     gradeType, _ := reader.ReadString('\n')
+    fmt.Print("Weight (1-10, empty for 1): ")
+    weight, _ := reader.ReadString('\n')
     fmt.Print("Date (YYYY-MM-DD): ")
     date, _ := reader.ReadString('\n')
 
@@ -315,6 +670,7 @@ func addGrade(reader *bufio.Reader) {
         "subject_id": toInt(subjectID),
         "grade":      strings.TrimSpace(grade),
         "grade_type": strings.TrimSpace(gradeType),
+        "weight":     toInt(weight),
         "date":       strings.TrimSpace(date),
     }
 
@@ -411,8 +767,8 @@ func getGrades() {
 
     fmt.Println("\n--- Grades ---")
     for _, grade := range grades {
-        fmt.Printf("ID: %v | User ID: %v | Subject ID: %v | Grade: %s | Type: %s | Date: %s\n",
-            grade["id"], grade["user_id"], grade["subject_id"], grade["grade"], grade["grade_type"], grade["date"])
+        fmt.Printf("ID: %v | User ID: %v | Subject ID: %v | Grade: %s | Type: %s | Weight: %v | Date: %s\n",
+            grade["id"], grade["user_id"], grade["subject_id"], grade["grade"], grade["grade_type"], grade["weight"], grade["date"])
     }
 }
 
@@ -493,10 +849,14 @@ func addAttendance(reader *bufio.Reader) {
     userID, _ := reader.ReadString('\n')
     fmt.Print("Subject ID: ")
     subjectID, _ := reader.ReadString('\n')
-    fmt.Print("Status (present/absent): ")
+    fmt.Print("Status (present/absent/late/excused): ")
     status, _ := reader.ReadString('\n')
     fmt.Print("Date (YYYY-MM-DD): ")
     date, _ := reader.ReadString('\n')
+    fmt.Print("Class period (optional): ")
+    classPeriod, _ := reader.ReadString('\n')
+    fmt.Print("Timetable entry ID (optional): ")
+    timetableID, _ := reader.ReadString('\n')
 
     data := map[string]interface{}{
         "user_id":    toInt(userID),
@@ -504,6 +864,12 @@ func addAttendance(reader *bufio.Reader) {
         "status":     strings.TrimSpace(status),
         "date":       strings.TrimSpace(date),
     }
+    if strings.TrimSpace(classPeriod) != "" {
+        data["class_period"] = toInt(classPeriod)
+    }
+    if strings.TrimSpace(timetableID) != "" {
+        data["timetable_id"] = toInt(timetableID)
+    }
 
     body, _ := json.Marshal(data)
     url := baseURL + "/teacher/attendance"
@@ -710,8 +1076,8 @@ func getStudentGrades(reader *bufio.Reader) {
 
     fmt.Println("\n--- Student Grades ---")
     for _, grade := range grades {
-        fmt.Printf("ID: %v | User ID: %v | Subject ID: %v | Grade: %s | Type: %s | Date: %s\n",
-            grade["id"], grade["user_id"], grade["subject_id"], grade["grade"], grade["grade_type"], grade["date"])
+        fmt.Printf("ID: %v | User ID: %v | Subject ID: %v | Grade: %s | Type: %s | Weight: %v | Date: %s\n",
+            grade["id"], grade["user_id"], grade["subject_id"], grade["grade"], grade["grade_type"], grade["weight"], grade["date"])
     }
 }
 
@@ -971,6 +1337,1978 @@ func addClassMember(reader *bufio.Reader) {
     fmt.Println("Message:", result["message"])
 }
 
+func linkGuardian(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Link Guardian ==")
+    fmt.Print("Guardian (parent) ID: ")
+    guardianID, _ := reader.ReadString('\n')
+    fmt.Print("Student ID: ")
+    studentID, _ := reader.ReadString('\n')
+    fmt.Print("Relationship (e.g. mother): ")
+    relationship, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "guardian_id":  toInt(guardianID),
+        "student_id":   toInt(studentID),
+        "relationship": strings.TrimSpace(relationship),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/guardian", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getChildren() {
+    if token == "" {
+        fmt.Println("Please login as parent first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/parent/children", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var children []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&children)
+
+    fmt.Println("\n--- Children ---")
+    for _, child := range children {
+        fmt.Printf("Student ID: %v | Name: %s %s | Class: %v | Relationship: %v\n",
+            child["student_id"], child["first_name"], child["last_name"], child["class_name"], child["relationship"])
+    }
+}
+
+func getChildData(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as parent first.")
+        return
+    }
+
+    fmt.Print("Student ID: ")
+    studentID, _ := reader.ReadString('\n')
+    fmt.Print("Data [grades, attendance, exams, timetable]: ")
+    kind, _ := reader.ReadString('\n')
+
+    url := fmt.Sprintf("%s/parent/children/%d/%s", baseURL, toInt(studentID), strings.TrimSpace(kind))
+    req, _ := http.NewRequest("GET", url, nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var entries []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&entries)
+
+    fmt.Println("\n--- " + strings.TrimSpace(kind) + " ---")
+    for _, entry := range entries {
+        fmt.Println(entry)
+    }
+}
+
+func unlockLogin(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Unlock Login ==")
+    fmt.Print("Email (empty to skip): ")
+    email, _ := reader.ReadString('\n')
+    fmt.Print("IP (empty to skip): ")
+    ip, _ := reader.ReadString('\n')
+
+    data := map[string]string{
+        "email": strings.TrimSpace(email),
+        "ip":    strings.TrimSpace(ip),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/unlock", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getAuditLog(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Audit Log ==")
+    fmt.Print("Entity type (empty for all): ")
+    entityType, _ := reader.ReadString('\n')
+    fmt.Print("From date YYYY-MM-DD (empty for all): ")
+    from, _ := reader.ReadString('\n')
+
+    params := url.Values{}
+    if value := strings.TrimSpace(entityType); value != "" {
+        params.Set("entity_type", value)
+    }
+    if value := strings.TrimSpace(from); value != "" {
+        params.Set("from", value)
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/admin/audit?"+params.Encode(), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var entries []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&entries)
+
+    fmt.Println("\n--- Audit Log ---")
+    for _, entry := range entries {
+        fmt.Printf("%v %v (%v) %v %v #%v\n", entry["created_at"], entry["actor_id"], entry["actor_role"], entry["action"], entry["entity_type"], entry["entity_id"])
+    }
+}
+
+func editGrade(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin or teacher first.")
+        return
+    }
+
+    fmt.Println("== Edit Grade ==")
+    fmt.Print("Grade ID: ")
+    gradeID, _ := reader.ReadString('\n')
+    fmt.Print("Grade (e.g. 5, A, etc): ")
+    grade, _ := reader.ReadString('\n')
+    fmt.Print("Grade Type ('numeric', 'comment', 'custom'): ")
+    gradeType, _ := reader.ReadString('\n')
+    fmt.Print("Weight (1-10, empty for 1): ")
+    weight, _ := reader.ReadString('\n')
+    fmt.Print("Date (YYYY-MM-DD): ")
+    date, _ := reader.ReadString('\n')
+    fmt.Print("Reason: ")
+    reason, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "grade":      strings.TrimSpace(grade),
+        "grade_type": strings.TrimSpace(gradeType),
+        "weight":     toInt(weight),
+        "date":       strings.TrimSpace(date),
+        "reason":     strings.TrimSpace(reason),
+    }
+    body, _ := json.Marshal(data)
+    url := baseURL + "/teacher/grade/" + strings.TrimSpace(gradeID)
+    if isAdmin() {
+        url = baseURL + "/admin/grade/" + strings.TrimSpace(gradeID)
+    }
+
+    req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func deleteGrade(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin or teacher first.")
+        return
+    }
+
+    fmt.Println("== Delete Grade ==")
+    fmt.Print("Grade ID: ")
+    gradeID, _ := reader.ReadString('\n')
+    fmt.Print("Reason: ")
+    reason, _ := reader.ReadString('\n')
+
+    body, _ := json.Marshal(map[string]string{"reason": strings.TrimSpace(reason)})
+    url := baseURL + "/teacher/grade/" + strings.TrimSpace(gradeID)
+    if isAdmin() {
+        url = baseURL + "/admin/grade/" + strings.TrimSpace(gradeID)
+    }
+
+    req, _ := http.NewRequest("DELETE", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getGradeHistory(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Grade History ==")
+    fmt.Print("Grade ID: ")
+    gradeID, _ := reader.ReadString('\n')
+
+    req, _ := http.NewRequest("GET", baseURL+"/admin/grade/"+strings.TrimSpace(gradeID)+"/history", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var history struct {
+        Grade     map[string]interface{}   `json:"grade"`
+        Revisions []map[string]interface{} `json:"revisions"`
+    }
+    json.NewDecoder(resp.Body).Decode(&history)
+
+    fmt.Println("\n--- Grade History ---")
+    for _, revision := range history.Revisions {
+        fmt.Printf("%v %v by %v: %v (%v, %v) - %v\n", revision["created_at"], revision["action"], revision["editor_id"], revision["grade"], revision["grade_type"], revision["date"], revision["reason"])
+    }
+    if history.Grade == nil {
+        fmt.Println("Current: deleted")
+    } else {
+        fmt.Printf("Current: %v (%v, %v)\n", history.Grade["grade"], history.Grade["grade_type"], history.Grade["date"])
+    }
+}
+
+func getAverages() {
+    if token == "" {
+        fmt.Println("Please login as student first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/student/averages", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var averages struct {
+        Subjects []map[string]interface{} `json:"subjects"`
+        Overall  interface{}              `json:"overall"`
+    }
+    json.NewDecoder(resp.Body).Decode(&averages)
+
+    fmt.Println("\n--- Averages ---")
+    for _, subject := range averages.Subjects {
+        fmt.Printf("Subject ID: %v | Average: %v | Grades: %v | Total weight: %v\n",
+            subject["subject_id"], subject["average"], subject["count"], subject["total_weight"])
+    }
+    fmt.Println("Overall:", averages.Overall)
+}
+
+func getGradingScales() {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/grading-scales", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var scales []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&scales)
+
+    fmt.Println("\n--- Grading Scales ---")
+    for _, scale := range scales {
+        fmt.Printf("ID: %v | Name: %v | Kind: %v | Default: %v\n", scale["id"], scale["name"], scale["kind"], scale["is_default"])
+        if scale["kind"] == "range" {
+            fmt.Printf("    %v - %v\n", scale["min_value"], scale["max_value"])
+            continue
+        }
+        values, _ := scale["values"].([]interface{})
+        for _, v := range values {
+            value, _ := v.(map[string]interface{})
+            fmt.Printf("    %v = %v\n", value["value"], value["numeric_value"])
+        }
+    }
+}
+
+func setSubjectScale(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Set Subject Grading Scale ==")
+    fmt.Print("Subject ID: ")
+    subjectID, _ := reader.ReadString('\n')
+    fmt.Print("Grading scale ID (empty for the default scale): ")
+    scaleID, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{"grading_scale_id": nil}
+    if strings.TrimSpace(scaleID) != "" {
+        data["grading_scale_id"] = toInt(scaleID)
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("PUT", baseURL+"/admin/subject/"+strings.TrimSpace(subjectID)+"/grading-scale", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]string
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func addAcademicYear(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Add Academic Year ==")
+    fmt.Print("Name (e.g. 2026/2027): ")
+    name, _ := reader.ReadString('\n')
+    fmt.Print("Start date (YYYY-MM-DD): ")
+    startDate, _ := reader.ReadString('\n')
+    fmt.Print("End date (YYYY-MM-DD): ")
+    endDate, _ := reader.ReadString('\n')
+
+    data := map[string]string{
+        "name":       strings.TrimSpace(name),
+        "start_date": strings.TrimSpace(startDate),
+        "end_date":   strings.TrimSpace(endDate),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/academic-year", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func addTerm(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Add Term ==")
+    fmt.Print("Academic year ID: ")
+    yearID, _ := reader.ReadString('\n')
+    fmt.Print("Name (e.g. Semester 1): ")
+    name, _ := reader.ReadString('\n')
+    fmt.Print("Start date (YYYY-MM-DD): ")
+    startDate, _ := reader.ReadString('\n')
+    fmt.Print("End date (YYYY-MM-DD): ")
+    endDate, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "academic_year_id": toInt(yearID),
+        "name":             strings.TrimSpace(name),
+        "start_date":       strings.TrimSpace(startDate),
+        "end_date":         strings.TrimSpace(endDate),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/term", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getAcademicYears() {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/academic-years", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var result struct {
+        AcademicYears []struct {
+            ID         int                      `json:"id"`
+            Name       string                   `json:"name"`
+            StartDate  string                   `json:"start_date"`
+            EndDate    string                   `json:"end_date"`
+            ArchivedAt *string                  `json:"archived_at"`
+            Terms      []map[string]interface{} `json:"terms"`
+        } `json:"academic_years"`
+        CurrentTerm map[string]interface{} `json:"current_term"`
+    }
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("\n--- Academic Years ---")
+    for _, year := range result.AcademicYears {
+        archived := ""
+        if year.ArchivedAt != nil {
+            archived = " | archived " + *year.ArchivedAt
+        }
+        fmt.Printf("ID: %v | %s | %s - %s%s\n", year.ID, year.Name, year.StartDate, year.EndDate, archived)
+        for _, term := range year.Terms {
+            fmt.Printf("    Term %v | %v | %v - %v\n", term["id"], term["name"], term["start_date"], term["end_date"])
+        }
+    }
+    if result.CurrentTerm != nil {
+        fmt.Println("Current term:", result.CurrentTerm["name"])
+    }
+}
+
+func rollover(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== End-of-Year Rollover ==")
+    fmt.Print("Finished academic year ID: ")
+    fromYearID, _ := reader.ReadString('\n')
+    fmt.Print("Next academic year ID: ")
+    toYearID, _ := reader.ReadString('\n')
+    fmt.Print("Max class level (0 for no limit): ")
+    maxLevel, _ := reader.ReadString('\n')
+    fmt.Print("Class map (e.g. 3A=,IA=IIA; empty for none): ")
+    classMapInput, _ := reader.ReadString('\n')
+    fmt.Print("Overrides (e.g. 12:repeat,13:leave,14:move:2B; empty for none): ")
+    overridesInput, _ := reader.ReadString('\n')
+    fmt.Print("Copy subjects as drafts? (y/n): ")
+    copySubjects, _ := reader.ReadString('\n')
+    fmt.Print("Dry run? (y/n): ")
+    dryRun, _ := reader.ReadString('\n')
+
+    classMap := map[string]string{}
+    for _, pair := range strings.Split(strings.TrimSpace(classMapInput), ",") {
+        if from, to, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+            classMap[strings.TrimSpace(from)] = strings.TrimSpace(to)
+        }
+    }
+    overrides := []map[string]interface{}{}
+    for _, entry := range strings.Split(strings.TrimSpace(overridesInput), ",") {
+        parts := strings.Split(strings.TrimSpace(entry), ":")
+        if len(parts) < 2 {
+            continue
+        }
+        override := map[string]interface{}{"user_id": toInt(parts[0]), "action": parts[1]}
+        if len(parts) > 2 {
+            override["class_name"] = parts[2]
+        }
+        overrides = append(overrides, override)
+    }
+
+    data := map[string]interface{}{
+        "from_year_id":  toInt(fromYearID),
+        "to_year_id":    toInt(toYearID),
+        "max_level":     toInt(maxLevel),
+        "class_map":     classMap,
+        "overrides":     overrides,
+        "copy_subjects": strings.TrimSpace(copySubjects) == "y",
+        "dry_run":       strings.TrimSpace(dryRun) == "y",
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/rollover", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Message string `json:"message"`
+        Plan    struct {
+            NewClasses    []string                 `json:"new_classes"`
+            Moves         []map[string]interface{} `json:"moves"`
+            SubjectDrafts []map[string]interface{} `json:"subject_drafts"`
+            Summary       map[string]int           `json:"summary"`
+        } `json:"plan"`
+    }
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result.Message)
+    if resp.StatusCode != 200 {
+        return
+    }
+    for _, move := range result.Plan.Moves {
+        fmt.Printf("Student %v: %v -> %v (%v)\n", move["user_id"], move["from_class"], move["to_class"], move["action"])
+    }
+    fmt.Println("New classes:", strings.Join(result.Plan.NewClasses, ", "))
+    fmt.Println("Subject drafts:", len(result.Plan.SubjectDrafts))
+    fmt.Println("Summary:", result.Plan.Summary)
+}
+
+func getSubjectDrafts(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Academic year ID: ")
+    yearID, _ := reader.ReadString('\n')
+
+    req, _ := http.NewRequest("GET", baseURL+"/admin/subject-drafts?academic_year_id="+strings.TrimSpace(yearID), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var drafts []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&drafts)
+
+    fmt.Println("\n--- Subject Drafts ---")
+    for _, draft := range drafts {
+        fmt.Printf("ID: %v | %v | Class: %v | Teacher: %v\n", draft["id"], draft["name"], draft["class_name"], draft["teacher_id"])
+    }
+}
+
+func applySubjectDrafts(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Academic year ID: ")
+    yearID, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "academic_year_id": toInt(yearID),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/subject-drafts/apply", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if result["applied"] != nil {
+        fmt.Println("Applied:", result["applied"])
+    }
+}
+
+func setHomeroom(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Class name: ")
+    className, _ := reader.ReadString('\n')
+    fmt.Print("Homeroom teacher ID (empty for none): ")
+    teacherID, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "homeroom_teacher_id": nil,
+    }
+    if strings.TrimSpace(teacherID) != "" {
+        data["homeroom_teacher_id"] = toInt(teacherID)
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("PUT", baseURL+"/admin/class/"+url.PathEscape(strings.TrimSpace(className))+"/homeroom", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func setTermGrade(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Student ID: ")
+    userID, _ := reader.ReadString('\n')
+    fmt.Print("Subject ID: ")
+    subjectID, _ := reader.ReadString('\n')
+    fmt.Print("Term ID (empty for current): ")
+    termID, _ := reader.ReadString('\n')
+    fmt.Print("Proposed grade (empty to keep): ")
+    proposed, _ := reader.ReadString('\n')
+    fmt.Print("Final grade (empty to keep): ")
+    final, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "user_id":    toInt(userID),
+        "subject_id": toInt(subjectID),
+        "term_id":    toInt(termID),
+    }
+    if strings.TrimSpace(proposed) != "" {
+        data["proposed_grade"] = strings.TrimSpace(proposed)
+    }
+    if strings.TrimSpace(final) != "" {
+        data["final_grade"] = strings.TrimSpace(final)
+    }
+    body, _ := json.Marshal(data)
+    url := baseURL + "/teacher/term-grade"
+    if isAdmin() {
+        url = baseURL + "/admin/term-grade"
+    }
+
+    req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func approveTermGrades(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as homeroom teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Class name: ")
+    className, _ := reader.ReadString('\n')
+    fmt.Print("Term ID (empty for current): ")
+    termID, _ := reader.ReadString('\n')
+    fmt.Print("Subject ID (empty for all): ")
+    subjectID, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "class_name": strings.TrimSpace(className),
+    }
+    if strings.TrimSpace(termID) != "" {
+        data["term_id"] = toInt(termID)
+    }
+    if strings.TrimSpace(subjectID) != "" {
+        data["subject_id"] = toInt(subjectID)
+    }
+    body, _ := json.Marshal(data)
+    url := baseURL + "/teacher/term-grades/approve"
+    if isAdmin() {
+        url = baseURL + "/admin/term-grades/approve"
+    }
+
+    req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if result["approved"] != nil {
+        fmt.Println("Approved:", result["approved"])
+    }
+}
+
+func getTermGrades() {
+    if token == "" {
+        fmt.Println("Please login as student first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/student/term-grades", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var grades []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&grades)
+
+    fmt.Println("\n--- Term Grades ---")
+    for _, grade := range grades {
+        fmt.Printf("Subject: %v | Term: %v | Proposed: %v | Final: %v\n", grade["subject_id"], grade["term_id"], grade["proposed_grade"], grade["final_grade"])
+    }
+}
+
+func getReportCard(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Student ID: ")
+    studentID, _ := reader.ReadString('\n')
+    fmt.Print("Academic year ID (empty for current): ")
+    yearID, _ := reader.ReadString('\n')
+    fmt.Print("Output file: ")
+    path, _ := reader.ReadString('\n')
+
+    params := url.Values{}
+    if strings.TrimSpace(yearID) != "" {
+        params.Set("academic_year_id", strings.TrimSpace(yearID))
+    }
+    downloadFile(baseURL+"/admin/report-card/"+strings.TrimSpace(studentID)+"?"+params.Encode(), strings.TrimSpace(path))
+}
+
+func getClassReportCards(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Class name: ")
+    className, _ := reader.ReadString('\n')
+    fmt.Print("Academic year ID (empty for current): ")
+    yearID, _ := reader.ReadString('\n')
+    fmt.Print("Output file: ")
+    path, _ := reader.ReadString('\n')
+
+    params := url.Values{}
+    params.Set("class_name", strings.TrimSpace(className))
+    if strings.TrimSpace(yearID) != "" {
+        params.Set("academic_year_id", strings.TrimSpace(yearID))
+    }
+    downloadFile(baseURL+"/admin/report-cards?"+params.Encode(), strings.TrimSpace(path))
+}
+
+// downloadFile saves the response of an authenticated GET request to path
+func downloadFile(requestURL, path string) {
+    req, _ := http.NewRequest("GET", requestURL, nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        fmt.Println("Read error:", err)
+        return
+    }
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        fmt.Println("Write error:", err)
+        return
+    }
+    fmt.Printf("Saved %d bytes to %s\n", len(data), path)
+}
+
+func submitExcuse(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as student first.")
+        return
+    }
+
+    fmt.Print("Reason: ")
+    reason, _ := reader.ReadString('\n')
+    fmt.Print("Start date (YYYY-MM-DD): ")
+    startDate, _ := reader.ReadString('\n')
+    fmt.Print("End date (YYYY-MM-DD): ")
+    endDate, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "reason":     strings.TrimSpace(reason),
+        "start_date": strings.TrimSpace(startDate),
+        "end_date":   strings.TrimSpace(endDate),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/student/excuse", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getExcuses() {
+    if token == "" {
+        fmt.Println("Please login as student first.")
+        return
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/student/excuses", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var excuses []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&excuses)
+
+    fmt.Println("\n--- Excuses ---")
+    for _, excuse := range excuses {
+        fmt.Printf("ID: %v | %v - %v | %v | %v\n", excuse["id"], excuse["start_date"], excuse["end_date"], excuse["status"], excuse["reason"])
+    }
+}
+
+func reviewExcuse(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as homeroom teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Excuse ID: ")
+    excuseID, _ := reader.ReadString('\n')
+    fmt.Print("Decision (approved/rejected): ")
+    status, _ := reader.ReadString('\n')
+    fmt.Print("Comment (optional): ")
+    comment, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "status": strings.TrimSpace(status),
+    }
+    if strings.TrimSpace(comment) != "" {
+        data["comment"] = strings.TrimSpace(comment)
+    }
+    body, _ := json.Marshal(data)
+    url := baseURL + "/teacher/excuse/" + strings.TrimSpace(excuseID) + "/review"
+    if isAdmin() {
+        url = baseURL + "/admin/excuse/" + strings.TrimSpace(excuseID) + "/review"
+    }
+
+    req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if result["excused"] != nil {
+        fmt.Println("Excused absences:", result["excused"])
+    }
+}
+
+func takeLessonAttendance(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Timetable entry ID (empty to give subject and period): ")
+    timetableID, _ := reader.ReadString('\n')
+    data := map[string]interface{}{}
+    if strings.TrimSpace(timetableID) != "" {
+        data["timetable_id"] = toInt(timetableID)
+    } else {
+        fmt.Print("Subject ID: ")
+        subjectID, _ := reader.ReadString('\n')
+        fmt.Print("Class period: ")
+        classPeriod, _ := reader.ReadString('\n')
+        data["subject_id"] = toInt(subjectID)
+        data["class_period"] = toInt(classPeriod)
+    }
+    fmt.Print("Date (YYYY-MM-DD): ")
+    date, _ := reader.ReadString('\n')
+    data["date"] = strings.TrimSpace(date)
+
+    records := []map[string]interface{}{}
+    fmt.Println("Enter students who are not present as <user_id> <absent/late>, empty line to finish.")
+    for {
+        fmt.Print("Student: ")
+        line, _ := reader.ReadString('\n')
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            break
+        }
+        if len(fields) != 2 {
+            fmt.Println("Expected <user_id> <status>.")
+            continue
+        }
+        records = append(records, map[string]interface{}{"user_id": toInt(fields[0]), "status": fields[1]})
+    }
+    data["records"] = records
+
+    body, _ := json.Marshal(data)
+    url := baseURL + "/teacher/attendance/lesson"
+    if isAdmin() {
+        url = baseURL + "/admin/attendance/lesson"
+    }
+
+    req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if result["created"] != nil {
+        fmt.Println("Created:", result["created"], "Updated:", result["updated"])
+    }
+}
+
+// readDateRange asks for an optional date range and returns it as query parameters
+func readDateRange(reader *bufio.Reader) url.Values {
+    fmt.Print("From (YYYY-MM-DD, empty for the current term): ")
+    from, _ := reader.ReadString('\n')
+    fmt.Print("To (YYYY-MM-DD, empty for the current term): ")
+    to, _ := reader.ReadString('\n')
+    query := url.Values{}
+    if strings.TrimSpace(from) != "" {
+        query.Set("from", strings.TrimSpace(from))
+    }
+    if strings.TrimSpace(to) != "" {
+        query.Set("to", strings.TrimSpace(to))
+    }
+    return query
+}
+
+func getAttendanceStats(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Class name (empty to give a student): ")
+    className, _ := reader.ReadString('\n')
+    path := "/attendance-stats/class/" + url.PathEscape(strings.TrimSpace(className))
+    if strings.TrimSpace(className) == "" {
+        fmt.Print("Student ID: ")
+        studentID, _ := reader.ReadString('\n')
+        path = "/attendance-stats/student/" + strings.TrimSpace(studentID)
+    }
+    query := readDateRange(reader)
+    requestURL := baseURL + "/teacher" + path
+    if isAdmin() {
+        requestURL = baseURL + "/admin" + path
+    }
+
+    req, _ := http.NewRequest("GET", requestURL+"?"+query.Encode(), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+    if resp.StatusCode != 200 {
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    rate, _ := result["rate"].(map[string]interface{})
+    fmt.Printf("\n--- Attendance %v - %v ---\n", result["from"], result["to"])
+    fmt.Printf("Total: %v%% of %v lessons\n", rate["percent"], rate["lessons"])
+    subjects, _ := result["subjects"].([]interface{})
+    for _, item := range subjects {
+        subject, _ := item.(map[string]interface{})
+        subjectRate, _ := subject["rate"].(map[string]interface{})
+        fmt.Printf("%v: %v%% of %v lessons\n", subject["name"], subjectRate["percent"], subjectRate["lessons"])
+    }
+    students, _ := result["students"].([]interface{})
+    for _, item := range students {
+        student, _ := item.(map[string]interface{})
+        studentRate, _ := student["rate"].(map[string]interface{})
+        fmt.Printf("Student %v %v %v: %v%% of %v lessons\n", student["user_id"], student["first_name"], student["last_name"], studentRate["percent"], studentRate["lessons"])
+    }
+}
+
+func getAttendanceAlerts(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as homeroom teacher or admin first.")
+        return
+    }
+
+    fmt.Print("Class name (optional): ")
+    className, _ := reader.ReadString('\n')
+    query := readDateRange(reader)
+    if strings.TrimSpace(className) != "" {
+        query.Set("class_name", strings.TrimSpace(className))
+    }
+    requestURL := baseURL + "/teacher/attendance-alerts"
+    if isAdmin() {
+        requestURL = baseURL + "/admin/attendance-alerts"
+    }
+
+    req, _ := http.NewRequest("GET", requestURL+"?"+query.Encode(), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var alerts []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&alerts)
+
+    fmt.Println("\n--- Attendance alerts ---")
+    for _, alert := range alerts {
+        rate, _ := alert["rate"].(map[string]interface{})
+        subject := "all subjects"
+        if alert["subject_name"] != nil {
+            subject = fmt.Sprint(alert["subject_name"])
+        }
+        fmt.Printf("%v | %v %v (%v) | %v | %v: %v%%\n", alert["class_name"], alert["first_name"], alert["last_name"], alert["user_id"], alert["rule"], subject, rate["percent"])
+    }
+}
+
+func addHoliday(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Add Holiday ==")
+    fmt.Print("Name (e.g. Christmas break): ")
+    name, _ := reader.ReadString('\n')
+    fmt.Print("Start date (YYYY-MM-DD): ")
+    startDate, _ := reader.ReadString('\n')
+    fmt.Print("End date (YYYY-MM-DD, empty for one day): ")
+    endDate, _ := reader.ReadString('\n')
+
+    data := map[string]string{
+        "name":       strings.TrimSpace(name),
+        "start_date": strings.TrimSpace(startDate),
+        "end_date":   strings.TrimSpace(endDate),
+    }
+    body, _ := json.Marshal(data)
+
+    req, _ := http.NewRequest("POST", baseURL+"/admin/holiday", bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func createCalendarFeed() {
+    if token == "" {
+        fmt.Println("Please login as student or teacher first.")
+        return
+    }
+
+    req, _ := http.NewRequest("POST", baseURL+"/calendar-token", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if feedURL, ok := result["url"].(string); ok {
+        fmt.Println("Subscribe to this URL in your calendar app (it is shown only once):")
+        fmt.Println(feedURL)
+    }
+}
+
+func revokeCalendarFeed() {
+    if token == "" {
+        fmt.Println("Please login as student or teacher first.")
+        return
+    }
+
+    req, _ := http.NewRequest("DELETE", baseURL+"/calendar-token", nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func addTimetableOverride(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Change a Lesson on One Date ==")
+    fmt.Print("Timetable entry ID: ")
+    timetableID, _ := reader.ReadString('\n')
+    fmt.Print("Date (YYYY-MM-DD): ")
+    date, _ := reader.ReadString('\n')
+    fmt.Print("Cancel the lesson? (y/n): ")
+    cancel, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "timetable_id": toInt(timetableID),
+        "date":         strings.TrimSpace(date),
+    }
+    if strings.TrimSpace(cancel) == "y" {
+        data["cancelled"] = true
+    } else {
+        fmt.Print("Substitute teacher ID (empty to keep): ")
+        substitute, _ := reader.ReadString('\n')
+        fmt.Print("Room (empty to keep): ")
+        room, _ := reader.ReadString('\n')
+        fmt.Print("Class period (empty to keep): ")
+        period, _ := reader.ReadString('\n')
+        fmt.Print("Start time HH:MM (empty to keep): ")
+        startTime, _ := reader.ReadString('\n')
+        fmt.Print("End time HH:MM (empty to keep): ")
+        endTime, _ := reader.ReadString('\n')
+        if strings.TrimSpace(substitute) != "" {
+            data["substitute_teacher_id"] = toInt(substitute)
+        }
+        if strings.TrimSpace(room) != "" {
+            data["room"] = strings.TrimSpace(room)
+        }
+        if strings.TrimSpace(period) != "" {
+            data["class_period"] = toInt(period)
+        }
+        if strings.TrimSpace(startTime) != "" {
+            data["start_time"] = strings.TrimSpace(startTime)
+        }
+        if strings.TrimSpace(endTime) != "" {
+            data["end_time"] = strings.TrimSpace(endTime)
+        }
+    }
+    fmt.Print("Note (e.g. Teacher ill): ")
+    note, _ := reader.ReadString('\n')
+    data["note"] = strings.TrimSpace(note)
+    fmt.Print("Only check for conflicts? (y/n): ")
+    dryRun, _ := reader.ReadString('\n')
+    body, _ := json.Marshal(data)
+
+    requestURL := baseURL + "/admin/timetable-override"
+    if strings.TrimSpace(dryRun) == "y" {
+        requestURL += "?dry_run=true"
+    }
+    req, _ := http.NewRequest("POST", requestURL, bytes.NewBuffer(body))
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if conflicts, ok := result["conflicts"].([]interface{}); ok {
+        for _, conflict := range conflicts {
+            conflict, _ := conflict.(map[string]interface{})
+            entry, _ := conflict["entry"].(map[string]interface{})
+            fmt.Printf("  Entry %v (%v %v-%v, class %v): %v\n", entry["id"], entry["day"], entry["start_time"], entry["end_time"], entry["class_name"], conflict["reasons"])
+        }
+    }
+}
+
+func deleteTimetableOverride(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Override ID: ")
+    id, _ := reader.ReadString('\n')
+
+    req, _ := http.NewRequest("DELETE", baseURL+"/admin/timetable-override/"+strings.TrimSpace(id), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+}
+
+func getSubstitutions(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    fmt.Print("Date (YYYY-MM-DD, empty for today): ")
+    date, _ := reader.ReadString('\n')
+    query := url.Values{}
+    if strings.TrimSpace(date) != "" {
+        query.Set("date", strings.TrimSpace(date))
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/substitutions?"+query.Encode(), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var lessons []map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&lessons)
+
+    fmt.Println("\n--- Substitutions ---")
+    for _, lesson := range lessons {
+        original, _ := lesson["original"].(map[string]interface{})
+        fmt.Printf("Class %v | %v-%v | Subject ID: %v | Teacher ID: %v -> %v | Room: %v -> %v | %v %v\n",
+            lesson["class_name"], original["start_time"], original["end_time"], lesson["subject_id"],
+            original["teacher_id"], lesson["teacher_id"], original["room"], lesson["room"], lesson["changes"], lesson["note"])
+    }
+}
+
+// readBellPeriods asks for the times of class periods 1, 2, ... until an empty start time
+func readBellPeriods(reader *bufio.Reader) []map[string]interface{} {
+    periods := []map[string]interface{}{}
+    for period := 1; ; period++ {
+        fmt.Printf("Period %d start (HH:MM, empty to finish): ", period)
+        start, _ := reader.ReadString('\n')
+        if strings.TrimSpace(start) == "" {
+            return periods
+        }
+        fmt.Printf("Period %d end (HH:MM): ", period)
+        end, _ := reader.ReadString('\n')
+        periods = append(periods, map[string]interface{}{
+            "class_period": period,
+            "start_time":   strings.TrimSpace(start),
+            "end_time":     strings.TrimSpace(end),
+        })
+    }
+}
+
+// sendBellSchedule sends a bell schedule request and prints the answer
+func sendBellSchedule(method, requestURL string, data interface{}) {
+    var body io.Reader
+    if data != nil {
+        encoded, _ := json.Marshal(data)
+        body = bytes.NewBuffer(encoded)
+    }
+    req, _ := http.NewRequest(method, requestURL, body)
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    fmt.Println("Message:", result["message"])
+    if id, ok := result["id"]; ok {
+        fmt.Println("ID:", id)
+    }
+}
+
+func addBellSchedule(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Add Bell Schedule ==")
+    fmt.Print("Name (e.g. Shortened lessons): ")
+    name, _ := reader.ReadString('\n')
+    fmt.Print("Make it the default schedule (y/N): ")
+    isDefault, _ := reader.ReadString('\n')
+    periods := readBellPeriods(reader)
+
+    sendBellSchedule("POST", baseURL+"/admin/bell-schedule", map[string]interface{}{
+        "name":       strings.TrimSpace(name),
+        "is_default": strings.EqualFold(strings.TrimSpace(isDefault), "y"),
+        "periods":    periods,
+    })
+}
+
+func editBellSchedule(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Edit Bell Schedule ==")
+    fmt.Print("Bell schedule ID: ")
+    id, _ := reader.ReadString('\n')
+    fmt.Print("Name: ")
+    name, _ := reader.ReadString('\n')
+    periods := readBellPeriods(reader)
+
+    sendBellSchedule("PUT", baseURL+"/admin/bell-schedule/"+strings.TrimSpace(id), map[string]interface{}{
+        "name":    strings.TrimSpace(name),
+        "periods": periods,
+    })
+}
+
+func setDefaultBellSchedule(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Bell schedule ID: ")
+    id, _ := reader.ReadString('\n')
+
+    sendBellSchedule("PUT", baseURL+"/admin/bell-schedule/default", map[string]interface{}{
+        "bell_schedule_id": toInt(id),
+    })
+}
+
+func addBellScheduleDates(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Bell schedule ID: ")
+    id, _ := reader.ReadString('\n')
+    fmt.Print("Start date (YYYY-MM-DD): ")
+    startDate, _ := reader.ReadString('\n')
+    fmt.Print("End date (YYYY-MM-DD, empty for one day): ")
+    endDate, _ := reader.ReadString('\n')
+
+    sendBellSchedule("POST", baseURL+"/admin/bell-schedule/"+strings.TrimSpace(id)+"/dates", map[string]string{
+        "start_date": strings.TrimSpace(startDate),
+        "end_date":   strings.TrimSpace(endDate),
+    })
+}
+
+func deleteBellScheduleDates(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Bell schedule dates ID: ")
+    id, _ := reader.ReadString('\n')
+
+    sendBellSchedule("DELETE", baseURL+"/admin/bell-schedule-dates/"+strings.TrimSpace(id), nil)
+}
+
+func getBellSchedules(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login first.")
+        return
+    }
+
+    fmt.Print("Date (YYYY-MM-DD, empty for today): ")
+    date, _ := reader.ReadString('\n')
+    query := url.Values{}
+    if strings.TrimSpace(date) != "" {
+        query.Set("date", strings.TrimSpace(date))
+    }
+
+    req, _ := http.NewRequest("GET", baseURL+"/bell-schedules?"+query.Encode(), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        var result map[string]string
+        json.NewDecoder(resp.Body).Decode(&result)
+        fmt.Println("Error:", result["message"])
+        return
+    }
+
+    var result struct {
+        BellSchedules  []map[string]interface{} `json:"bell_schedules"`
+        ActiveSchedule map[string]interface{}   `json:"active_schedule"`
+    }
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("\n--- Bell Schedules ---")
+    for _, schedule := range result.BellSchedules {
+        fmt.Printf("ID: %v | %v | Default: %v\n", schedule["id"], schedule["name"], schedule["is_default"])
+        periods, _ := schedule["periods"].([]interface{})
+        for _, item := range periods {
+            period, _ := item.(map[string]interface{})
+            fmt.Printf("  Period %v: %v-%v\n", period["class_period"], period["start_time"], period["end_time"])
+        }
+        dates, _ := schedule["dates"].([]interface{})
+        for _, item := range dates {
+            days, _ := item.(map[string]interface{})
+            fmt.Printf("  Dates ID %v: %v to %v\n", days["id"], days["start_date"], days["end_date"])
+        }
+    }
+    if result.ActiveSchedule != nil {
+        fmt.Println("In use on that day:", result.ActiveSchedule["name"])
+    }
+}
+
+// sendPlanning sends a timetable planning request and prints the answer
+func sendPlanning(method, requestURL string, data interface{}) map[string]interface{} {
+    var body io.Reader
+    if data != nil {
+        encoded, _ := json.Marshal(data)
+        body = bytes.NewBuffer(encoded)
+    }
+    req, _ := http.NewRequest(method, requestURL, body)
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return nil
+    }
+    defer resp.Body.Close()
+
+    var result map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&result)
+
+    fmt.Println("Status:", resp.StatusCode)
+    if message, ok := result["message"]; ok {
+        fmt.Println("Message:", message)
+    }
+    if id, ok := result["id"]; ok {
+        fmt.Println("ID:", id)
+    }
+    if added, ok := result["added"]; ok {
+        fmt.Println("Added:", added)
+    }
+    printViolations(result["violations"])
+    return result
+}
+
+// printViolations prints the constraints a timetable draft breaks
+func printViolations(value interface{}) {
+    violations, _ := value.([]interface{})
+    for _, item := range violations {
+        violation, _ := item.(map[string]interface{})
+        kind := "soft"
+        if violation["hard"] == true {
+            kind = "HARD"
+        }
+        fmt.Printf("  [%s] %v: %v\n", kind, violation["constraint"], violation["message"])
+    }
+}
+
+func addRoom(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Add Room ==")
+    fmt.Print("Name (e.g. 101): ")
+    name, _ := reader.ReadString('\n')
+    fmt.Print("Capacity: ")
+    capacity, _ := reader.ReadString('\n')
+    fmt.Print("Room type (e.g. lab, gym; empty for a classroom): ")
+    roomType, _ := reader.ReadString('\n')
+
+    sendPlanning("POST", baseURL+"/admin/room", map[string]interface{}{
+        "name":      strings.TrimSpace(name),
+        "capacity":  toInt(capacity),
+        "room_type": strings.TrimSpace(roomType),
+    })
+}
+
+func setTeachingRequirement(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Set Teaching Requirement ==")
+    fmt.Print("Subject ID: ")
+    subjectID, _ := reader.ReadString('\n')
+    fmt.Print("Lessons per week: ")
+    hours, _ := reader.ReadString('\n')
+    fmt.Print("Most lessons per day (empty to spread them evenly): ")
+    maxPerDay, _ := reader.ReadString('\n')
+    fmt.Print("Room type (empty for any room): ")
+    roomType, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "subject_id":     toInt(subjectID),
+        "hours_per_week": toInt(hours),
+        "room_type":      strings.TrimSpace(roomType),
+    }
+    if strings.TrimSpace(maxPerDay) != "" {
+        data["max_per_day"] = toInt(maxPerDay)
+    }
+    sendPlanning("PUT", baseURL+"/admin/teaching-requirement", data)
+}
+
+func setTeacherAvailability(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Set Teacher Availability ==")
+    fmt.Print("Teacher ID: ")
+    teacherID, _ := reader.ReadString('\n')
+    fmt.Print("Day (e.g. Monday): ")
+    day, _ := reader.ReadString('\n')
+    fmt.Print("Class period (empty for the whole day): ")
+    classPeriod, _ := reader.ReadString('\n')
+    fmt.Print("Preference (unavailable, avoid, prefer): ")
+    preference, _ := reader.ReadString('\n')
+
+    sendPlanning("PUT", baseURL+"/admin/teacher-availability", map[string]interface{}{
+        "teacher_id":   toInt(teacherID),
+        "day":          strings.TrimSpace(day),
+        "class_period": toInt(classPeriod),
+        "preference":   strings.TrimSpace(preference),
+    })
+}
+
+// printDraftEntries prints the lessons of a timetable draft
+func printDraftEntries(value interface{}) {
+    entries, _ := value.([]interface{})
+    for _, item := range entries {
+        entry, _ := item.(map[string]interface{})
+        if entry["day"] == "" {
+            fmt.Printf("  Entry %v: Class %v, subject %v - not placed\n", entry["id"], entry["class_name"], entry["subject_id"])
+            continue
+        }
+        fmt.Printf("  Entry %v: %v period %v | Class %v | Subject %v | Teacher %v | Room %v\n",
+            entry["id"], entry["day"], entry["class_period"], entry["class_name"], entry["subject_id"], entry["teacher_id"], entry["room"])
+    }
+}
+
+func generateTimetable(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Println("== Generate Timetable Draft ==")
+    fmt.Print("Term ID (empty for the current term): ")
+    termID, _ := reader.ReadString('\n')
+    fmt.Print("Classes (comma-separated, empty for every class): ")
+    classes, _ := reader.ReadString('\n')
+    fmt.Print("Most lessons per day (empty for every period): ")
+    maxLessons, _ := reader.ReadString('\n')
+    fmt.Print("Allow free periods between lessons (y/N): ")
+    allowGaps, _ := reader.ReadString('\n')
+
+    data := map[string]interface{}{
+        "max_lessons_per_day": toInt(maxLessons),
+        "allow_gaps":          strings.EqualFold(strings.TrimSpace(allowGaps), "y"),
+    }
+    if strings.TrimSpace(termID) != "" {
+        data["term_id"] = toInt(termID)
+    }
+    if strings.TrimSpace(classes) != "" {
+        names := []string{}
+        for _, name := range strings.Split(classes, ",") {
+            names = append(names, strings.TrimSpace(name))
+        }
+        data["class_names"] = names
+    }
+    result := sendPlanning("POST", baseURL+"/admin/timetable-draft", data)
+    if draft, ok := result["draft"].(map[string]interface{}); ok {
+        printDraftEntries(draft["entries"])
+        printViolations(draft["violations"])
+    }
+}
+
+func getTimetableDraft(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Timetable draft ID: ")
+    id, _ := reader.ReadString('\n')
+
+    req, _ := http.NewRequest("GET", baseURL+"/admin/timetable-draft/"+strings.TrimSpace(id), nil)
+    req.Header.Set("Authorization", "Bearer "+token)
+
+    client := &http.Client{}
+    resp, err := client.Do(req)
+    if err != nil {
+        fmt.Println("Request error:", err)
+        return
+    }
+    defer resp.Body.Close()
+
+    var draft map[string]interface{}
+    json.NewDecoder(resp.Body).Decode(&draft)
+    if resp.StatusCode != 200 {
+        fmt.Println("Error:", draft["message"])
+        return
+    }
+
+    fmt.Println("\n--- Timetable Draft ---")
+    fmt.Printf("ID: %v | Term: %v | Days: %v | Created: %v | Published: %v\n",
+        draft["id"], draft["term_id"], draft["days"], draft["created_at"], draft["published_at"])
+    printDraftEntries(draft["entries"])
+    printViolations(draft["violations"])
+}
+
+func moveDraftEntry(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Timetable draft entry ID: ")
+    id, _ := reader.ReadString('\n')
+    fmt.Print("Day (e.g. Monday, empty to leave it unplaced): ")
+    day, _ := reader.ReadString('\n')
+    fmt.Print("Class period: ")
+    classPeriod, _ := reader.ReadString('\n')
+    fmt.Print("Room (empty to pick a free one): ")
+    room, _ := reader.ReadString('\n')
+
+    sendPlanning("PUT", baseURL+"/admin/timetable-draft-entry/"+strings.TrimSpace(id), map[string]interface{}{
+        "day":          strings.TrimSpace(day),
+        "class_period": toInt(classPeriod),
+        "room":         strings.TrimSpace(room),
+    })
+}
+
+func publishTimetableDraft(reader *bufio.Reader) {
+    if token == "" {
+        fmt.Println("Please login as admin first.")
+        return
+    }
+
+    fmt.Print("Timetable draft ID: ")
+    id, _ := reader.ReadString('\n')
+
+    sendPlanning("POST", baseURL+"/admin/timetable-draft/"+strings.TrimSpace(id)+"/publish", nil)
+}
+
 //# TODO: Implement the isAdmin function to check if the user is an admin
 func isAdmin() bool {
     return true
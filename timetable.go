@@ -0,0 +1,603 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validWeekday reports whether day is a day name accepted by timetable.day
+func validWeekday(day string) bool {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if day == weekday.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// validClockTime reports whether value is a time of day in HH:MM format
+func validClockTime(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil && len(value) == 5
+}
+
+// validateTimetableEntry checks the fields of a new or edited timetable entry and resolves its times and term.
+// It writes the error response itself and returns false when the entry is invalid.
+func validateTimetableEntry(c *gin.Context, entry *TimetableEntry) bool {
+	if entry.SubjectID == 0 || entry.ClassPeriod == 0 || entry.TeacherID == 0 || entry.ClassName == "" || entry.Day == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject ID, class period, teacher ID, class name, and day are required"})
+		return false
+	}
+	if !validWeekday(entry.Day) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Day must be a day of the week such as Monday"})
+		return false
+	}
+	if !resolveEntryTimes(c, entry) {
+		return false
+	}
+	return resolveTermID(c, &entry.TermID)
+}
+
+// resolveEntryTimes takes the times of an entry given without them from its period in the default bell schedule.
+// Given times that differ from the schedule are kept as the entry's own times.
+// It writes the error response itself and returns false when the times are invalid.
+func resolveEntryTimes(c *gin.Context, entry *TimetableEntry) bool {
+	if (entry.StartTime == "") != (entry.EndTime == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Start time and end time must be given together"})
+		return false
+	}
+	if entry.StartTime != "" {
+		if !validClockTime(entry.StartTime) || !validClockTime(entry.EndTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Time must be in HH:MM format"})
+			return false
+		}
+		if entry.EndTime <= entry.StartTime {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "End time must be after start time"})
+			return false
+		}
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return false
+	}
+	period, _, ok := bellPeriod(schedules, "", entry.ClassPeriod)
+	if entry.StartTime == "" {
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Class period %d is not in the default bell schedule; give its start and end time", entry.ClassPeriod)})
+			return false
+		}
+		entry.StartTime, entry.EndTime = period.StartTime, period.EndTime
+	}
+	entry.CustomTimes = !ok || entry.StartTime != period.StartTime || entry.EndTime != period.EndTime
+	return true
+}
+
+// heldTogether reports whether two lessons of the same day share their period or overlap in time
+func heldTogether(a, b TimetableEntry) bool {
+	samePeriod := a.ClassPeriod != 0 && a.ClassPeriod == b.ClassPeriod
+	overlapping := a.StartTime < b.EndTime && b.StartTime < a.EndTime
+	return samePeriod || overlapping
+}
+
+// clashReasons returns what two lessons held at the same time would both book: "teacher", "room" or "class"
+func clashReasons(a, b TimetableEntry) []string {
+	var reasons []string
+	if a.TeacherID == b.TeacherID {
+		reasons = append(reasons, "teacher")
+	}
+	room := strings.TrimSpace(a.Room)
+	if room != "" && strings.EqualFold(room, strings.TrimSpace(b.Room)) {
+		reasons = append(reasons, "room")
+	}
+	if a.ClassName == b.ClassName {
+		reasons = append(reasons, "class")
+	}
+	return reasons
+}
+
+// timetableConflicts returns the entries that would be held at the same time as entry, in the same period or
+// overlapping time range of the same day and term, and share its teacher, room or class
+func timetableConflicts(entry TimetableEntry) ([]TimetableConflict, error) {
+	entries, err := store.Timetable.ListByDay(entry.Day)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []TimetableConflict{}
+	for _, other := range entries {
+		if other.ID == entry.ID {
+			continue
+		}
+		if entry.TermID != nil && other.TermID != nil && *entry.TermID != *other.TermID {
+			continue
+		}
+		if !heldTogether(entry, other) {
+			continue
+		}
+		if reasons := clashReasons(entry, other); len(reasons) > 0 {
+			conflicts = append(conflicts, TimetableConflict{Entry: other, Reasons: reasons})
+		}
+	}
+	return conflicts, nil
+}
+
+// answerConflicts rejects a change that double-books a teacher, room or class with 409 and the conflicting entries.
+// With ?dry_run=true it answers whether the change could be saved instead.
+// It writes the response itself and returns false when the change must not be saved.
+func answerConflicts(c *gin.Context, conflicts []TimetableConflict, err error, subject string) bool {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking timetable conflicts"})
+		return false
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": subject + " conflicts with existing entries", "conflicts": conflicts})
+		return false
+	}
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"message": subject + " has no conflicts", "conflicts": conflicts})
+		return false
+	}
+	return true
+}
+
+// checkTimetableConflicts rejects an entry that double-books a teacher, room or class, see answerConflicts
+func checkTimetableConflicts(c *gin.Context, entry TimetableEntry) bool {
+	conflicts, err := timetableConflicts(entry)
+	return answerConflicts(c, conflicts, err, "Timetable entry")
+}
+
+func UpdateTimetableEntry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid timetable entry ID"})
+		return
+	}
+	before, err := store.Timetable.ByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable entry not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable entry"})
+		return
+	}
+	var entry TimetableEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	entry.ID = before.ID
+	if !validateTimetableEntry(c, &entry) {
+		return
+	}
+	if !checkTimetableConflicts(c, entry) {
+		return
+	}
+
+	err = store.Timetable.Update(entry)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable entry not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving timetable entry"})
+		return
+	}
+	recordAudit(c, "update", "timetable", entry.ID, before, entry)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable entry updated successfully"})
+}
+
+// weekParam returns the Monday of the week selected with ?week=, which may be any date of the week, or nil without ?week=.
+// It writes the error response itself and returns false when the date is invalid.
+func weekParam(c *gin.Context) (*time.Time, bool) {
+	value := c.Query("week")
+	if value == "" {
+		return nil, true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Week must be a date in YYYY-MM-DD format"})
+		return nil, false
+	}
+	monday := date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	return &monday, true
+}
+
+// applyOverride returns a timetable entry as held on the date of an override
+func applyOverride(entry TimetableEntry, override TimetableOverride) TimetableEntry {
+	if override.SubstituteTeacherID != nil {
+		entry.TeacherID = *override.SubstituteTeacherID
+	}
+	if override.Room != nil {
+		entry.Room = *override.Room
+	}
+	if override.ClassPeriod != nil {
+		entry.ClassPeriod = *override.ClassPeriod
+	}
+	if override.StartTime != nil && override.EndTime != nil {
+		entry.StartTime, entry.EndTime = *override.StartTime, *override.EndTime
+	}
+	return entry
+}
+
+// scheduledLesson returns the lesson of a timetable entry on date, timed by the bell schedule of the day,
+// changed by an override and cancelled by a holiday. override and holiday are nil when there is none.
+func scheduledLesson(entry TimetableEntry, date string, override *TimetableOverride, holiday *Holiday, schedules []BellSchedule) ScheduledLesson {
+	original := entry
+	lesson := ScheduledLesson{TimetableEntry: entry, Date: date, Changes: []string{}}
+	var schedule *BellSchedule
+	if !entry.CustomTimes {
+		if period, used, ok := bellPeriod(schedules, date, entry.ClassPeriod); ok {
+			lesson.StartTime, lesson.EndTime = period.StartTime, period.EndTime
+			schedule = used
+		}
+	}
+	if override != nil {
+		lesson.TimetableEntry = applyOverride(lesson.TimetableEntry, *override)
+		lesson.OverrideID = &override.ID
+		lesson.Note = override.Note
+		// A lesson moved to another period without new times takes the times of that period
+		if override.ClassPeriod != nil && override.StartTime == nil && !entry.CustomTimes {
+			if period, used, ok := bellPeriod(schedules, date, *override.ClassPeriod); ok {
+				lesson.StartTime, lesson.EndTime = period.StartTime, period.EndTime
+				schedule = used
+			}
+		}
+		if override.Cancelled {
+			lesson.Cancelled = true
+			lesson.Changes = append(lesson.Changes, "cancelled")
+		}
+		if lesson.TeacherID != original.TeacherID {
+			lesson.Changes = append(lesson.Changes, "teacher")
+		}
+		if lesson.Room != original.Room {
+			lesson.Changes = append(lesson.Changes, "room")
+		}
+	}
+	if lesson.ClassPeriod != original.ClassPeriod || lesson.StartTime != original.StartTime || lesson.EndTime != original.EndTime {
+		lesson.Changes = append(lesson.Changes, "time")
+		if lesson.Note == "" && schedule != nil && !schedule.IsDefault {
+			lesson.Note = schedule.Name
+		}
+	}
+	if override != nil || len(lesson.Changes) > 0 {
+		lesson.Original = &original
+	}
+	if holiday != nil {
+		lesson.Cancelled = true
+		lesson.Changes = append(lesson.Changes, "holiday")
+		if lesson.Note == "" {
+			lesson.Note = holiday.Name
+		}
+	}
+	return lesson
+}
+
+// scheduledLessons expands timetable entries into the lessons held on each day from from to to, keeping every entry
+// to its term and applying the bell schedules, overrides and holidays of each date. The lessons are sorted by date and time.
+func scheduledLessons(entries []TimetableEntry, from, to time.Time) ([]ScheduledLesson, error) {
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	schedules, err := store.Bells.List()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := store.Timetable.Overrides(first, last)
+	if err != nil {
+		return nil, err
+	}
+	type lessonKey struct {
+		timetableID uint
+		date        string
+	}
+	changed := make(map[lessonKey]*TimetableOverride, len(overrides))
+	for i := range overrides {
+		changed[lessonKey{overrides[i].TimetableID, overrides[i].Date}] = &overrides[i]
+	}
+	holidays, err := store.Terms.Holidays(first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := []ScheduledLesson{}
+	var term *Term
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := date.Format("2006-01-02")
+		// Terms do not overlap, so the term of the previous day still holds until its end
+		if term == nil || day < term.StartDate || day > term.EndDate {
+			term = nil
+			current, err := store.Terms.Containing(day)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if err == nil {
+				term = &current
+			}
+		}
+		var holiday *Holiday
+		for i := range holidays {
+			if day >= holidays[i].StartDate && day <= holidays[i].EndDate {
+				holiday = &holidays[i]
+			}
+		}
+		for _, entry := range entries {
+			if !strings.EqualFold(entry.Day, date.Weekday().String()) {
+				continue
+			}
+			if entry.TermID != nil && (term == nil || *entry.TermID != term.ID) {
+				continue
+			}
+			lessons = append(lessons, scheduledLesson(entry, day, changed[lessonKey{entry.ID, day}], holiday, schedules))
+		}
+	}
+	sort.SliceStable(lessons, func(i, j int) bool {
+		if lessons[i].Date != lessons[j].Date {
+			return lessons[i].Date < lessons[j].Date
+		}
+		return lessons[i].StartTime < lessons[j].StartTime
+	})
+	return lessons, nil
+}
+
+// withSubstitutions adds to the timetable entries of a teacher the entries of the lessons they substitute from from to to
+func withSubstitutions(entries []TimetableEntry, teacherID uint, from, to time.Time) ([]TimetableEntry, error) {
+	overrides, err := store.Timetable.Overrides(from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[uint]bool, len(entries))
+	for _, entry := range entries {
+		listed[entry.ID] = true
+	}
+	for _, override := range overrides {
+		if override.SubstituteTeacherID == nil || *override.SubstituteTeacherID != teacherID || listed[override.TimetableID] {
+			continue
+		}
+		entry, err := store.Timetable.ByID(override.TimetableID)
+		if err != nil {
+			return nil, err
+		}
+		listed[entry.ID] = true
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// teacherLessons keeps the lessons a teacher takes, and those of theirs given to a substitute
+func teacherLessons(lessons []ScheduledLesson, teacherID uint) []ScheduledLesson {
+	kept := []ScheduledLesson{}
+	for _, lesson := range lessons {
+		if lesson.TeacherID == teacherID || (lesson.Original != nil && lesson.Original.TeacherID == teacherID) {
+			kept = append(kept, lesson)
+		}
+	}
+	return kept
+}
+
+// weekTimetable expands timetable entries into the lessons of the week starting on monday.
+// With a teacherID it adds the lessons the teacher substitutes and drops those other teachers take.
+func weekTimetable(entries []TimetableEntry, monday time.Time, teacherID uint) ([]ScheduledLesson, error) {
+	sunday := monday.AddDate(0, 0, 6)
+	var err error
+	if teacherID != 0 {
+		entries, err = withSubstitutions(entries, teacherID, monday, sunday)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lessons, err := scheduledLessons(entries, monday, sunday)
+	if err != nil {
+		return nil, err
+	}
+	if teacherID != 0 {
+		lessons = teacherLessons(lessons, teacherID)
+	}
+	return lessons, nil
+}
+
+// overrideConflicts returns the other lessons held at the same time as a changed lesson on its date
+// that share its teacher, room or class
+func overrideConflicts(lesson ScheduledLesson) ([]TimetableConflict, error) {
+	date, _ := time.Parse("2006-01-02", lesson.Date)
+	entries, err := store.Timetable.ListByDay(lesson.Day)
+	if err != nil {
+		return nil, err
+	}
+	others, err := scheduledLessons(entries, date, date)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []TimetableConflict{}
+	for _, other := range others {
+		if other.ID == lesson.ID || other.Cancelled || !heldTogether(lesson.TimetableEntry, other.TimetableEntry) {
+			continue
+		}
+		if reasons := clashReasons(lesson.TimetableEntry, other.TimetableEntry); len(reasons) > 0 {
+			conflicts = append(conflicts, TimetableConflict{Entry: other.TimetableEntry, Reasons: reasons})
+		}
+	}
+	return conflicts, nil
+}
+
+// AddTimetableOverride cancels or changes one lesson of the weekly timetable on a single date
+func AddTimetableOverride(c *gin.Context) {
+	var override TimetableOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if override.TimetableID == 0 || override.Date == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Timetable ID and date are required"})
+		return
+	}
+	timetableID := override.TimetableID
+	entry, ok := lessonOf(c, &timetableID, 0, 0, override.Date)
+	if !ok {
+		return
+	}
+	if override.Room != nil {
+		room := strings.TrimSpace(*override.Room)
+		override.Room = &room
+	}
+	override.Note = strings.TrimSpace(override.Note)
+	if !override.Cancelled && override.SubstituteTeacherID == nil && override.Room == nil && override.ClassPeriod == nil && override.StartTime == nil && override.EndTime == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "An override must cancel the lesson or change its teacher, room, period or time"})
+		return
+	}
+	if override.Cancelled && (override.SubstituteTeacherID != nil || override.Room != nil || override.ClassPeriod != nil || override.StartTime != nil || override.EndTime != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A cancelled lesson cannot have other changes"})
+		return
+	}
+	if (override.StartTime == nil) != (override.EndTime == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Start time and end time must be changed together"})
+		return
+	}
+	if override.StartTime != nil {
+		if !validClockTime(*override.StartTime) || !validClockTime(*override.EndTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Time must be in HH:MM format"})
+			return
+		}
+		if *override.EndTime <= *override.StartTime {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "End time must be after start time"})
+			return
+		}
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	if override.ClassPeriod != nil {
+		if *override.ClassPeriod == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Class period must be at least 1"})
+			return
+		}
+		if _, _, ok := bellPeriod(schedules, override.Date, *override.ClassPeriod); !ok && override.StartTime == nil && !entry.CustomTimes {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Class period %d is not in the bell schedule; give its start and end time", *override.ClassPeriod)})
+			return
+		}
+	}
+	if override.SubstituteTeacherID != nil {
+		if *override.SubstituteTeacherID == entry.TeacherID {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Substitute teacher must differ from the usual teacher"})
+			return
+		}
+		substitute, err := store.Users.ByID(*override.SubstituteTeacherID)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving user"})
+			return
+		}
+		if err == sql.ErrNoRows || substitute.Role != "teacher" {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Substitute teacher not found"})
+			return
+		}
+	}
+
+	existing, err := store.Timetable.Overrides(override.Date, override.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable overrides"})
+		return
+	}
+	for _, other := range existing {
+		if other.TimetableID == entry.ID {
+			c.JSON(http.StatusConflict, gin.H{"message": "The lesson already has an override on this date", "id": other.ID})
+			return
+		}
+	}
+	if !override.Cancelled {
+		conflicts, err := overrideConflicts(scheduledLesson(entry, override.Date, &override, nil, schedules))
+		if !answerConflicts(c, conflicts, err, "Timetable override") {
+			return
+		}
+	} else if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"message": "Timetable override has no conflicts", "conflicts": []TimetableConflict{}})
+		return
+	}
+
+	override.ID = 0
+	override.CreatedBy, err = currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	override.CreatedAt = formatTimestamp(time.Now())
+	id, err := store.Timetable.CreateOverride(override)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving timetable override"})
+		return
+	}
+	override.ID = id
+	recordAudit(c, "create", "timetable_override", override.ID, nil, override)
+	c.JSON(http.StatusCreated, gin.H{"message": "Timetable override created successfully", "id": override.ID})
+}
+
+func DeleteTimetableOverride(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid timetable override ID"})
+		return
+	}
+	override, err := store.Timetable.OverrideByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable override not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable override"})
+		return
+	}
+
+	err = store.Timetable.DeleteOverride(override.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable override not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting timetable override"})
+		return
+	}
+	recordAudit(c, "delete", "timetable_override", override.ID, override, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable override deleted successfully"})
+}
+
+// GetSubstitutions lists the changed and cancelled lessons of the whole school on ?date=, today by default,
+// sorted by class and time
+func GetSubstitutions(c *gin.Context) {
+	date := c.DefaultQuery("date", today())
+	if !validDate(date) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Date must be in YYYY-MM-DD format"})
+		return
+	}
+	overrides, err := store.Timetable.Overrides(date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable overrides"})
+		return
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return
+	}
+	lessons := []ScheduledLesson{}
+	for i := range overrides {
+		entry, err := store.Timetable.ByID(overrides[i].TimetableID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable entry"})
+			return
+		}
+		lessons = append(lessons, scheduledLesson(entry, date, &overrides[i], nil, schedules))
+	}
+	sort.SliceStable(lessons, func(i, j int) bool {
+		if lessons[i].ClassName != lessons[j].ClassName {
+			return lessons[i].ClassName < lessons[j].ClassName
+		}
+		return lessons[i].Original.StartTime < lessons[j].Original.StartTime
+	})
+	c.JSON(http.StatusOK, lessons)
+}
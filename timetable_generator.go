@@ -0,0 +1,1142 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The timetable generator places the weekly lessons of the teaching requirements into the periods of the default bell
+// schedule. Placed lessons never break a hard constraint: a class, teacher and room have one lesson at a time, teachers
+// are not scheduled when unavailable, rooms hold the class and are of the kind the subject needs, and classes and
+// subjects keep to their most lessons per day. Soft constraints are weighed by penalties that a local search lowers:
+// free periods between a class's lessons, lessons in periods teachers would rather avoid, preferred periods left
+// unused, days starting late and lessons piling up on a few days. Existing timetable entries of the term stay where they are
+// and count towards the requirements. Lessons that cannot be placed are left for the admin to resolve.
+
+// Penalties weighing the constraints of a timetable; the generator looks for the lowest total
+const (
+	unplacedPenalty     = 1000 // Per lesson without a period
+	forbiddenGapPenalty = 100  // Per free period between a class's lessons when gaps are not allowed
+	gapPenalty          = 10   // Per free period between a class's lessons when gaps are allowed
+	avoidPenalty        = 3    // Per lesson in a period its teacher would rather avoid
+	preferPenalty       = 1    // Taken off per lesson in a period its teacher prefers
+	lateStartPenalty    = 1    // Per period a class's day starts after the first
+)
+
+// plannedLesson is one weekly lesson placed by the generator
+type plannedLesson struct {
+	entry     TimetableDraftEntry
+	subject   string   // Subject name, for messages
+	slot      int      // Day and period, see timetablePlanner; -1 while unplaced
+	maxPerDay int      // Most lessons of the subject on one day
+	rooms     []string // Rooms the lesson fits in, in order of preference
+}
+
+// classDay identifies the lessons of a class on one day
+type classDay struct {
+	class string
+	day   int
+}
+
+// availabilityKey identifies a period of a teacher's week; period 0 stands for the whole day
+type availabilityKey struct {
+	day    string
+	period uint
+}
+
+// timetablePlanner holds the occupancy of a week while lessons are placed.
+// A slot numbers a period of a day: day index * len(periods) + period index.
+type timetablePlanner struct {
+	days         []string
+	periods      []BellPeriod
+	maxLessons   int
+	allowGaps    bool
+	rooms        []Room
+	classSizes   map[string]int
+	requirements map[uint]TeachingRequirement
+	availability map[uint]map[availabilityKey]string
+	preferred    []TeacherAvailability // Periods and days teachers would rather teach in
+	lessons      []*plannedLesson
+
+	// Number of lessons, including existing timetable entries, per slot or day
+	classAt    map[string][]int
+	teacherAt  map[uint][]int
+	roomAt     map[string][]int
+	classDays  map[string][]int
+	subjectDay map[uint][]int
+	// Placed lesson per slot
+	classLesson   map[string][]*plannedLesson
+	teacherLesson map[uint][]*plannedLesson
+	roomLesson    map[string][]*plannedLesson
+}
+
+// roomKey returns the name under which a room is booked, matching room names like clashReasons
+func roomKey(room string) string {
+	return strings.ToLower(strings.TrimSpace(room))
+}
+
+func countsOf[K comparable](m map[K][]int, key K, size int) []int {
+	if m[key] == nil {
+		m[key] = make([]int, size)
+	}
+	return m[key]
+}
+
+func lessonsOf[K comparable](m map[K][]*plannedLesson, key K, size int) []*plannedLesson {
+	if m[key] == nil {
+		m[key] = make([]*plannedLesson, size)
+	}
+	return m[key]
+}
+
+func (p *timetablePlanner) slots() int {
+	return len(p.days) * len(p.periods)
+}
+
+// slot returns the slot of a day and period, or false when they are not part of the week
+func (p *timetablePlanner) slot(day string, classPeriod uint) (int, bool) {
+	for d, name := range p.days {
+		if name != day {
+			continue
+		}
+		for i, period := range p.periods {
+			if period.ClassPeriod == classPeriod {
+				return d*len(p.periods) + i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (p *timetablePlanner) when(s int) string {
+	return fmt.Sprintf("in period %d on %s", p.periods[s%len(p.periods)].ClassPeriod, p.days[s/len(p.periods)])
+}
+
+// block books the class, teacher and room of an existing timetable entry in every slot it is held together with
+func (p *timetablePlanner) block(entry TimetableEntry) {
+	day := -1
+	for s := 0; s < p.slots(); s++ {
+		period := p.periods[s%len(p.periods)]
+		slotEntry := TimetableEntry{ClassPeriod: period.ClassPeriod, StartTime: period.StartTime, EndTime: period.EndTime}
+		if p.days[s/len(p.periods)] != entry.Day || !heldTogether(entry, slotEntry) {
+			continue
+		}
+		countsOf(p.classAt, entry.ClassName, p.slots())[s]++
+		countsOf(p.teacherAt, entry.TeacherID, p.slots())[s]++
+		if key := roomKey(entry.Room); key != "" {
+			countsOf(p.roomAt, key, p.slots())[s]++
+		}
+		day = s / len(p.periods)
+	}
+	if day >= 0 {
+		countsOf(p.classDays, entry.ClassName, len(p.days))[day]++
+		countsOf(p.subjectDay, entry.SubjectID, len(p.days))[day]++
+	}
+}
+
+// addLesson adds an unplaced lesson of a subject. Without a teaching requirement, the subject is taken to have
+// hours lessons a week.
+func (p *timetablePlanner) addLesson(entry TimetableDraftEntry, subject string, hours int) *plannedLesson {
+	requirement, ok := p.requirements[entry.SubjectID]
+	if ok {
+		hours = int(requirement.HoursPerWeek)
+	}
+	lesson := &plannedLesson{entry: entry, subject: subject, slot: -1, rooms: p.roomsFor(entry.ClassName, requirement)}
+	lesson.maxPerDay = (hours + len(p.days) - 1) / len(p.days)
+	if requirement.MaxPerDay != nil {
+		lesson.maxPerDay = int(*requirement.MaxPerDay)
+	}
+	p.lessons = append(p.lessons, lesson)
+	return lesson
+}
+
+// roomsFor returns the rooms that hold a class and are of the kind a requirement needs. Lessons needing no special
+// room go to ordinary classrooms first, and every lesson to the smallest room it fits in.
+func (p *timetablePlanner) roomsFor(class string, requirement TeachingRequirement) []string {
+	var rooms []Room
+	for _, room := range p.rooms {
+		if int(room.Capacity) >= p.classSizes[class] && (requirement.RoomType == "" || strings.EqualFold(room.RoomType, requirement.RoomType)) {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		if (rooms[i].RoomType == "") != (rooms[j].RoomType == "") {
+			return rooms[i].RoomType == ""
+		}
+		return rooms[i].Capacity < rooms[j].Capacity
+	})
+	names := make([]string, len(rooms))
+	for i, room := range rooms {
+		names[i] = room.Name
+	}
+	return names
+}
+
+func (p *timetablePlanner) room(name string) Room {
+	for _, room := range p.rooms {
+		if roomKey(room.Name) == roomKey(name) {
+			return room
+		}
+	}
+	return Room{}
+}
+
+// preference returns how a teacher stands to teaching in a slot: "unavailable", "avoid", "prefer" or ""
+func (p *timetablePlanner) preference(teacherID uint, s int) string {
+	day := p.days[s/len(p.periods)]
+	if preference, ok := p.availability[teacherID][availabilityKey{day, p.periods[s%len(p.periods)].ClassPeriod}]; ok {
+		return preference
+	}
+	return p.availability[teacherID][availabilityKey{day, 0}]
+}
+
+// check returns why an unplaced lesson cannot be held in a slot and room, or "" when it can
+func (p *timetablePlanner) check(lesson *plannedLesson, s int, room string) string {
+	entry := lesson.entry
+	day := p.days[s/len(p.periods)]
+	if countsOf(p.classAt, entry.ClassName, p.slots())[s] > 0 {
+		return fmt.Sprintf("Class %s already has a lesson %s", entry.ClassName, p.when(s))
+	}
+	if countsOf(p.teacherAt, entry.TeacherID, p.slots())[s] > 0 {
+		return fmt.Sprintf("Teacher %d already teaches %s", entry.TeacherID, p.when(s))
+	}
+	if p.preference(entry.TeacherID, s) == "unavailable" {
+		return fmt.Sprintf("Teacher %d is unavailable %s", entry.TeacherID, p.when(s))
+	}
+	if room != "" {
+		if countsOf(p.roomAt, roomKey(room), p.slots())[s] > 0 {
+			return fmt.Sprintf("Room %s is taken %s", room, p.when(s))
+		}
+		if len(p.rooms) > 0 && !lesson.fitsIn(room) {
+			return fmt.Sprintf("Room %s does not hold class %s or is not of the kind %s needs", room, entry.ClassName, lesson.subject)
+		}
+	} else if len(p.rooms) > 0 {
+		return "A room is required"
+	}
+	if countsOf(p.classDays, entry.ClassName, len(p.days))[s/len(p.periods)] >= p.maxLessons {
+		return fmt.Sprintf("Class %s already has the most lessons allowed on %s (%d)", entry.ClassName, day, p.maxLessons)
+	}
+	if countsOf(p.subjectDay, entry.SubjectID, len(p.days))[s/len(p.periods)] >= lesson.maxPerDay {
+		return fmt.Sprintf("%s already has the most lessons allowed on %s (%d)", lesson.subject, day, lesson.maxPerDay)
+	}
+	return ""
+}
+
+func (lesson *plannedLesson) fitsIn(room string) bool {
+	for _, name := range lesson.rooms {
+		if roomKey(name) == roomKey(room) {
+			return true
+		}
+	}
+	return false
+}
+
+// freeRoom returns the first room the lesson fits in that is free in a slot, "" without rooms set up
+func (p *timetablePlanner) freeRoom(lesson *plannedLesson, s int) (string, bool) {
+	if len(p.rooms) == 0 {
+		return "", true
+	}
+	for _, room := range lesson.rooms {
+		if countsOf(p.roomAt, roomKey(room), p.slots())[s] == 0 {
+			return room, true
+		}
+	}
+	return "", false
+}
+
+func (p *timetablePlanner) place(lesson *plannedLesson, s int, room string) {
+	entry := lesson.entry
+	lesson.slot = s
+	lesson.entry.Room = room
+	lesson.entry.Day = p.days[s/len(p.periods)]
+	lesson.entry.ClassPeriod = p.periods[s%len(p.periods)].ClassPeriod
+	countsOf(p.classAt, entry.ClassName, p.slots())[s]++
+	countsOf(p.teacherAt, entry.TeacherID, p.slots())[s]++
+	countsOf(p.classDays, entry.ClassName, len(p.days))[s/len(p.periods)]++
+	countsOf(p.subjectDay, entry.SubjectID, len(p.days))[s/len(p.periods)]++
+	lessonsOf(p.classLesson, entry.ClassName, p.slots())[s] = lesson
+	lessonsOf(p.teacherLesson, entry.TeacherID, p.slots())[s] = lesson
+	if key := roomKey(room); key != "" {
+		countsOf(p.roomAt, key, p.slots())[s]++
+		lessonsOf(p.roomLesson, key, p.slots())[s] = lesson
+	}
+}
+
+func (p *timetablePlanner) unplace(lesson *plannedLesson) {
+	s, entry := lesson.slot, lesson.entry
+	if s < 0 {
+		return
+	}
+	countsOf(p.classAt, entry.ClassName, p.slots())[s]--
+	countsOf(p.teacherAt, entry.TeacherID, p.slots())[s]--
+	countsOf(p.classDays, entry.ClassName, len(p.days))[s/len(p.periods)]--
+	countsOf(p.subjectDay, entry.SubjectID, len(p.days))[s/len(p.periods)]--
+	lessonsOf(p.classLesson, entry.ClassName, p.slots())[s] = nil
+	lessonsOf(p.teacherLesson, entry.TeacherID, p.slots())[s] = nil
+	if key := roomKey(entry.Room); key != "" {
+		countsOf(p.roomAt, key, p.slots())[s]--
+		lessonsOf(p.roomLesson, key, p.slots())[s] = nil
+	}
+	lesson.slot = -1
+	lesson.entry.Day, lesson.entry.ClassPeriod, lesson.entry.Room = "", 0, ""
+}
+
+// gaps returns the free periods between the first and last lesson of a class on a day, as period indexes
+func (p *timetablePlanner) gaps(class string, day int) (gaps []int, first, lessons int) {
+	at := countsOf(p.classAt, class, p.slots())[day*len(p.periods) : (day+1)*len(p.periods)]
+	first, last := -1, -1
+	for i, count := range at {
+		if count > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			lessons++
+		}
+	}
+	for i := first + 1; i < last; i++ {
+		if at[i] == 0 {
+			gaps = append(gaps, i)
+		}
+	}
+	return gaps, first, lessons
+}
+
+// dayCost returns the penalty of the lessons of a class on a day
+func (p *timetablePlanner) dayCost(key classDay) int {
+	gaps, first, lessons := p.gaps(key.class, key.day)
+	if lessons == 0 {
+		return 0
+	}
+	penalty := gapPenalty
+	if !p.allowGaps {
+		penalty = forbiddenGapPenalty
+	}
+	// Squaring the day's lessons spreads them evenly over the week
+	return len(gaps)*penalty + first*lateStartPenalty + lessons*lessons
+}
+
+// lessonCost returns the penalty of a lesson's own placement
+func (p *timetablePlanner) lessonCost(lesson *plannedLesson) int {
+	if lesson.slot < 0 {
+		return unplacedPenalty
+	}
+	preference := p.preference(lesson.entry.TeacherID, lesson.slot)
+	if preference == "avoid" {
+		return avoidPenalty
+	}
+	if preference == "prefer" {
+		return -preferPenalty
+	}
+	return 0
+}
+
+// cost returns the penalty of the lessons and the class days they are or will be held on
+func (p *timetablePlanner) cost(lessons []*plannedLesson, days map[classDay]bool) int {
+	total := 0
+	for _, lesson := range lessons {
+		total += p.lessonCost(lesson)
+	}
+	for key := range days {
+		total += p.dayCost(key)
+	}
+	return total
+}
+
+// touched returns the class days of the lessons' slots and of the given slots of their classes
+func (p *timetablePlanner) touched(lessons []*plannedLesson, slots ...int) map[classDay]bool {
+	days := map[classDay]bool{}
+	for i, lesson := range lessons {
+		if lesson.slot >= 0 {
+			days[classDay{lesson.entry.ClassName, lesson.slot / len(p.periods)}] = true
+		}
+		if i < len(slots) && slots[i] >= 0 {
+			days[classDay{lesson.entry.ClassName, slots[i] / len(p.periods)}] = true
+		}
+	}
+	return days
+}
+
+func (p *timetablePlanner) totalCost() int {
+	days := map[classDay]bool{}
+	for class := range p.classAt {
+		for d := range p.days {
+			days[classDay{class, d}] = true
+		}
+	}
+	return p.cost(p.lessons, days)
+}
+
+// placement remembers where lessons were so that a rejected move can be undone
+type placement struct {
+	lesson *plannedLesson
+	slot   int
+	room   string
+}
+
+func remember(lessons ...*plannedLesson) []placement {
+	saved := make([]placement, len(lessons))
+	for i, lesson := range lessons {
+		saved[i] = placement{lesson, lesson.slot, lesson.entry.Room}
+	}
+	return saved
+}
+
+func (p *timetablePlanner) restore(saved []placement) {
+	for _, place := range saved {
+		p.unplace(place.lesson)
+	}
+	for _, place := range saved {
+		if place.slot >= 0 {
+			p.place(place.lesson, place.slot, place.room)
+		}
+	}
+}
+
+// greedy places the lessons one by one, the most constrained first, each where it adds the least penalty
+func (p *timetablePlanner) greedy() {
+	options := make(map[*plannedLesson]int, len(p.lessons))
+	for _, lesson := range p.lessons {
+		for s := 0; s < p.slots(); s++ {
+			if p.preference(lesson.entry.TeacherID, s) != "unavailable" {
+				options[lesson]++
+			}
+		}
+		if len(p.rooms) > 0 {
+			options[lesson] *= len(lesson.rooms)
+		}
+	}
+	order := append([]*plannedLesson(nil), p.lessons...)
+	sort.SliceStable(order, func(i, j int) bool { return options[order[i]] < options[order[j]] })
+
+	for _, lesson := range order {
+		best, bestRoom, bestCost := -1, "", 0
+		for s := 0; s < p.slots(); s++ {
+			room, ok := p.freeRoom(lesson, s)
+			if !ok || p.check(lesson, s, room) != "" {
+				continue
+			}
+			days := p.touched([]*plannedLesson{lesson}, s)
+			before := p.cost([]*plannedLesson{lesson}, days)
+			p.place(lesson, s, room)
+			cost := p.cost([]*plannedLesson{lesson}, days) - before
+			p.unplace(lesson)
+			if best < 0 || cost < bestCost {
+				best, bestRoom, bestCost = s, room, cost
+			}
+		}
+		if best >= 0 {
+			p.place(lesson, best, bestRoom)
+		}
+	}
+}
+
+// try applies a move of lessons to slots and keeps it when the search accepts the change in penalty.
+// A slot of -1 leaves the lesson unplaced. It returns the change, or false when the move breaks a hard constraint.
+func (p *timetablePlanner) try(lessons []*plannedLesson, slots []int, accept func(delta int) bool) (int, bool) {
+	days := p.touched(lessons, slots...)
+	before := p.cost(lessons, days)
+	saved := remember(lessons...)
+	for _, lesson := range lessons {
+		p.unplace(lesson)
+	}
+	for i, lesson := range lessons {
+		if slots[i] < 0 {
+			continue
+		}
+		room, ok := p.freeRoom(lesson, slots[i])
+		if !ok || p.check(lesson, slots[i], room) != "" {
+			p.restore(saved)
+			return 0, false
+		}
+		p.place(lesson, slots[i], room)
+	}
+	delta := p.cost(lessons, days) - before
+	if !accept(delta) {
+		p.restore(saved)
+		return 0, false
+	}
+	return delta, true
+}
+
+// search lowers the penalty of the placed lessons by simulated annealing: it moves lessons to other periods, swaps
+// lessons of a class and places unplaced lessons, pushing aside those in the way. The random source is seeded so
+// that the same data gives the same draft. The best timetable seen is kept.
+func (p *timetablePlanner) search() {
+	if len(p.lessons) == 0 {
+		return
+	}
+	random := rand.New(rand.NewSource(1))
+	iterations := 400*len(p.lessons) + 1000
+	if iterations > 300000 {
+		iterations = 300000
+	}
+	current := p.totalCost()
+	best, bestPlaces := current, remember(p.lessons...)
+	temperature := 10.0
+	cooling := math.Pow(0.1/temperature, 1/float64(iterations))
+	accept := func(delta int) bool {
+		return delta <= 0 || random.Float64() < math.Exp(-float64(delta)/temperature)
+	}
+
+	var unplaced []*plannedLesson
+	for i := 0; i < iterations; i++ {
+		temperature *= cooling
+		unplaced = unplaced[:0]
+		for _, lesson := range p.lessons {
+			if lesson.slot < 0 {
+				unplaced = append(unplaced, lesson)
+			}
+		}
+
+		var delta int
+		var ok bool
+		lesson := p.lessons[random.Intn(len(p.lessons))]
+		s := random.Intn(p.slots())
+		switch move := random.Intn(10); {
+		case len(unplaced) > 0 && move < 3:
+			// Place an unplaced lesson, pushing aside the lessons of its class, teacher and room in that period
+			lesson = unplaced[random.Intn(len(unplaced))]
+			moved := []*plannedLesson{lesson}
+			slots := []int{s}
+			for _, other := range p.blockers(lesson, s) {
+				moved = append(moved, other)
+				slots = append(slots, -1)
+			}
+			delta, ok = p.try(moved, slots, accept)
+		case lesson.slot >= 0 && move < 7:
+			other := lessonsOf(p.classLesson, lesson.entry.ClassName, p.slots())[s]
+			if other != nil && other != lesson {
+				delta, ok = p.try([]*plannedLesson{lesson, other}, []int{other.slot, lesson.slot}, accept)
+			} else if other == nil {
+				delta, ok = p.try([]*plannedLesson{lesson}, []int{s}, accept)
+			}
+		case lesson.slot >= 0:
+			delta, ok = p.try([]*plannedLesson{lesson}, []int{s}, accept)
+		}
+		if !ok {
+			continue
+		}
+		current += delta
+		if current < best {
+			best, bestPlaces = current, remember(p.lessons...)
+		}
+	}
+	p.restore(bestPlaces)
+}
+
+// blockers returns the placed lessons that keep an unplaced lesson out of a slot: those of its class and teacher,
+// and of its first room when no room it fits in is free
+func (p *timetablePlanner) blockers(lesson *plannedLesson, s int) []*plannedLesson {
+	var blockers []*plannedLesson
+	add := func(other *plannedLesson) {
+		if other == nil {
+			return
+		}
+		for _, blocker := range blockers {
+			if blocker == other {
+				return
+			}
+		}
+		blockers = append(blockers, other)
+	}
+	add(lessonsOf(p.classLesson, lesson.entry.ClassName, p.slots())[s])
+	add(lessonsOf(p.teacherLesson, lesson.entry.TeacherID, p.slots())[s])
+	if _, ok := p.freeRoom(lesson, s); !ok && len(lesson.rooms) > 0 {
+		add(lessonsOf(p.roomLesson, roomKey(lesson.rooms[0]), p.slots())[s])
+	}
+	return blockers
+}
+
+// violations lists the constraints broken by the lessons: lessons left unplaced or breaking a hard constraint, periods
+// teachers would rather avoid, preferred periods left unused and free periods between a class's lessons
+func (p *timetablePlanner) violations(conflicts map[*plannedLesson]string) []DraftViolation {
+	violations := []DraftViolation{}
+	for _, lesson := range p.lessons {
+		id := lesson.entry.ID
+		if reason, ok := conflicts[lesson]; ok {
+			violations = append(violations, DraftViolation{Constraint: "conflict", Hard: true, EntryID: &id, Message: reason})
+		}
+		if lesson.slot < 0 {
+			if _, ok := conflicts[lesson]; !ok {
+				violations = append(violations, DraftViolation{Constraint: "unplaced", Hard: true, EntryID: &id,
+					Message: fmt.Sprintf("A lesson of %s for class %s is not placed", lesson.subject, lesson.entry.ClassName)})
+			}
+			continue
+		}
+		if p.preference(lesson.entry.TeacherID, lesson.slot) == "avoid" {
+			violations = append(violations, DraftViolation{Constraint: "teacher_avoid", EntryID: &id,
+				Message: fmt.Sprintf("Teacher %d would rather not teach %s", lesson.entry.TeacherID, p.when(lesson.slot))})
+		}
+	}
+
+	teachers := map[uint]bool{}
+	for _, lesson := range p.lessons {
+		teachers[lesson.entry.TeacherID] = true
+	}
+	for _, preferred := range p.preferred {
+		if !teachers[preferred.TeacherID] {
+			continue
+		}
+		at := countsOf(p.teacherAt, preferred.TeacherID, p.slots())
+		teaching := false
+		for s := range at {
+			day, period := p.days[s/len(p.periods)], p.periods[s%len(p.periods)].ClassPeriod
+			if day == preferred.Day && (preferred.ClassPeriod == 0 || preferred.ClassPeriod == period) && at[s] > 0 {
+				teaching = true
+			}
+		}
+		if teaching {
+			continue
+		}
+		message := fmt.Sprintf("Teacher %d does not teach in preferred period %d on %s", preferred.TeacherID, preferred.ClassPeriod, preferred.Day)
+		if preferred.ClassPeriod == 0 {
+			message = fmt.Sprintf("Teacher %d does not teach on preferred day %s", preferred.TeacherID, preferred.Day)
+		}
+		violations = append(violations, DraftViolation{Constraint: "teacher_prefer", Message: message})
+	}
+
+	classes := map[string]bool{}
+	for _, lesson := range p.lessons {
+		classes[lesson.entry.ClassName] = true
+	}
+	names := make([]string, 0, len(classes))
+	for class := range classes {
+		names = append(names, class)
+	}
+	sort.Strings(names)
+	for _, class := range names {
+		for d, day := range p.days {
+			gaps, _, _ := p.gaps(class, d)
+			for _, i := range gaps {
+				violations = append(violations, DraftViolation{Constraint: "gap", Hard: !p.allowGaps,
+					Message: fmt.Sprintf("Class %s has a free period %d on %s", class, p.periods[i].ClassPeriod, day)})
+			}
+		}
+	}
+	return violations
+}
+
+// newPlanner loads what placing the lessons of a term depends on: the default bell schedule, rooms, class sizes,
+// teaching requirements, teacher availability and the term's timetable entries, which it books.
+// It writes the error response itself and returns false when they cannot be loaded.
+func newPlanner(c *gin.Context, term Term, days []string, maxLessons uint, allowGaps bool) (*timetablePlanner, []TimetableEntry, bool) {
+	p := &timetablePlanner{
+		days: days, maxLessons: int(maxLessons), allowGaps: allowGaps,
+		classSizes: map[string]int{}, requirements: map[uint]TeachingRequirement{},
+		availability: map[uint]map[availabilityKey]string{},
+		classAt:      map[string][]int{}, teacherAt: map[uint][]int{}, roomAt: map[string][]int{},
+		classDays: map[string][]int{}, subjectDay: map[uint][]int{},
+		classLesson: map[string][]*plannedLesson{}, teacherLesson: map[uint][]*plannedLesson{}, roomLesson: map[string][]*plannedLesson{},
+	}
+	schedules, err := store.Bells.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving bell schedules"})
+		return nil, nil, false
+	}
+	if schedule := activeBellSchedule(schedules, ""); schedule != nil {
+		p.periods = schedule.Periods
+	}
+	if len(p.periods) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A default bell schedule with class periods is required to generate a timetable"})
+		return nil, nil, false
+	}
+	if p.maxLessons == 0 || p.maxLessons > len(p.periods) {
+		p.maxLessons = len(p.periods)
+	}
+
+	if p.rooms, err = store.Planning.Rooms(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving rooms"})
+		return nil, nil, false
+	}
+	requirements, err := store.Planning.Requirements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teaching requirements"})
+		return nil, nil, false
+	}
+	for _, requirement := range requirements {
+		p.requirements[requirement.SubjectID] = requirement
+	}
+	availability, err := store.Planning.Availability(0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teacher availability"})
+		return nil, nil, false
+	}
+	for _, period := range availability {
+		if p.availability[period.TeacherID] == nil {
+			p.availability[period.TeacherID] = map[availabilityKey]string{}
+		}
+		p.availability[period.TeacherID][availabilityKey{period.Day, period.ClassPeriod}] = period.Preference
+		if period.Preference == "prefer" {
+			p.preferred = append(p.preferred, period)
+		}
+	}
+	members, err := store.Classes.Students(term.AcademicYearID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class members"})
+		return nil, nil, false
+	}
+	for _, member := range members {
+		p.classSizes[member.ClassName]++
+	}
+
+	var entries []TimetableEntry
+	for _, day := range days {
+		dayEntries, err := store.Timetable.ListByDay(day)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable"})
+			return nil, nil, false
+		}
+		for _, entry := range dayEntries {
+			if entry.TermID == nil || *entry.TermID == term.ID {
+				p.block(entry)
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return p, entries, true
+}
+
+// subjectNames returns the names of the subjects of the lessons, keyed by subject ID.
+// It writes the error response itself and returns false when a subject cannot be loaded.
+func subjectNames(c *gin.Context, subjectIDs []uint) (map[uint]Subject, bool) {
+	subjects := map[uint]Subject{}
+	for _, id := range subjectIDs {
+		if _, ok := subjects[id]; ok {
+			continue
+		}
+		subject, err := store.Subjects.ByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+			return nil, false
+		}
+		subjects[id] = subject
+	}
+	return subjects, true
+}
+
+// draftPlanner rebuilds the placement of a draft's lessons on the term's current timetable. It returns the lessons that
+// break a hard constraint with the reason, leaving out the lesson skip, which is being edited.
+// It writes the error response itself and returns false when the data cannot be loaded.
+func draftPlanner(c *gin.Context, draft TimetableDraft, skip uint) (*timetablePlanner, map[*plannedLesson]string, bool) {
+	term, err := store.Terms.ByID(draft.TermID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving term"})
+		return nil, nil, false
+	}
+	p, _, ok := newPlanner(c, term, draft.Days, draft.MaxLessonsPerDay, draft.AllowGaps)
+	if !ok {
+		return nil, nil, false
+	}
+	var subjectIDs []uint
+	hours := map[uint]int{}
+	for _, entry := range draft.Entries {
+		subjectIDs = append(subjectIDs, entry.SubjectID)
+		hours[entry.SubjectID]++
+	}
+	subjects, ok := subjectNames(c, subjectIDs)
+	if !ok {
+		return nil, nil, false
+	}
+
+	conflicts := map[*plannedLesson]string{}
+	for _, entry := range draft.Entries {
+		if entry.ID == skip {
+			continue
+		}
+		lesson := p.addLesson(entry, subjects[entry.SubjectID].Name, hours[entry.SubjectID])
+		if entry.ClassPeriod == 0 {
+			continue
+		}
+		s, ok := p.slot(entry.Day, entry.ClassPeriod)
+		if !ok {
+			conflicts[lesson] = fmt.Sprintf("Period %d on %s is not part of the draft's week", entry.ClassPeriod, entry.Day)
+			continue
+		}
+		if reason := p.check(lesson, s, entry.Room); reason != "" {
+			conflicts[lesson] = reason
+		}
+		p.place(lesson, s, entry.Room)
+	}
+	return p, conflicts, true
+}
+
+// reviewDraft adds the violations of an unpublished draft to it.
+// It writes the error response itself and returns false when they cannot be worked out.
+func reviewDraft(c *gin.Context, draft *TimetableDraft) bool {
+	if draft.PublishedAt != nil {
+		return true
+	}
+	p, conflicts, ok := draftPlanner(c, *draft, 0)
+	if !ok {
+		return false
+	}
+	draft.Violations = p.violations(conflicts)
+	return true
+}
+
+// defaultSchoolDays are the days lessons are placed on unless the request names others
+var defaultSchoolDays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+
+func GenerateTimetableDraft(c *gin.Context) {
+	var req TimetableGeneration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	var termID uint
+	if req.TermID != nil {
+		termID = *req.TermID
+	}
+	term, ok := loadTerm(c, termID)
+	if !ok {
+		return
+	}
+	if len(req.Days) == 0 {
+		req.Days = defaultSchoolDays
+	}
+	seen := map[string]bool{}
+	for _, day := range req.Days {
+		if !validWeekday(day) || seen[day] {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Days must be distinct days of the week such as Monday"})
+			return
+		}
+		seen[day] = true
+	}
+	scope := map[string]bool{}
+	for _, name := range req.ClassNames {
+		if _, err := store.Classes.ByName(name); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Class not found: " + name})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving class"})
+			return
+		}
+		scope[name] = true
+	}
+
+	p, entries, ok := newPlanner(c, term, req.Days, req.MaxLessonsPerDay, req.AllowGaps)
+	if !ok {
+		return
+	}
+	// Lessons already in the timetable count towards the requirements
+	scheduled := map[uint]int{}
+	classLessons := map[string]int{}
+	for _, entry := range entries {
+		scheduled[entry.SubjectID]++
+		classLessons[entry.ClassName]++
+	}
+	var subjectIDs []uint
+	for subjectID := range p.requirements {
+		subjectIDs = append(subjectIDs, subjectID)
+	}
+	sort.Slice(subjectIDs, func(i, j int) bool { return subjectIDs[i] < subjectIDs[j] })
+	subjects, ok := subjectNames(c, subjectIDs)
+	if !ok {
+		return
+	}
+	for _, subjectID := range subjectIDs {
+		subject, requirement := subjects[subjectID], p.requirements[subjectID]
+		if len(scope) > 0 && !scope[subject.ClassName] {
+			continue
+		}
+		if (len(p.rooms) > 0 || requirement.RoomType != "") && len(p.roomsFor(subject.ClassName, requirement)) == 0 {
+			kind := "room"
+			if requirement.RoomType != "" {
+				kind = requirement.RoomType + " room"
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("No %s holds the %d students of class %s for %s", kind, p.classSizes[subject.ClassName], subject.ClassName, subject.Name)})
+			return
+		}
+		for i := scheduled[subjectID]; i < int(requirement.HoursPerWeek); i++ {
+			entry := TimetableDraftEntry{SubjectID: subjectID, TeacherID: subject.TeacherID, ClassName: subject.ClassName}
+			p.addLesson(entry, subject.Name, 0)
+			classLessons[subject.ClassName]++
+		}
+	}
+	if len(p.lessons) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "No lessons to place: the timetable already meets the teaching requirements of the classes"})
+		return
+	}
+	classes := make([]string, 0, len(classLessons))
+	for class := range classLessons {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		if fit := len(req.Days) * p.maxLessons; classLessons[class] > fit {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Class %s needs %d lessons a week but at most %d fit", class, classLessons[class], fit)})
+			return
+		}
+	}
+
+	p.greedy()
+	p.search()
+
+	draft := TimetableDraft{TermID: term.ID, Days: req.Days, MaxLessonsPerDay: uint(p.maxLessons), AllowGaps: req.AllowGaps}
+	var err error
+	draft.CreatedBy, err = currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	draft.CreatedAt = formatTimestamp(time.Now())
+	for _, lesson := range p.lessons {
+		draft.Entries = append(draft.Entries, lesson.entry)
+	}
+	id, err := store.Drafts.Create(draft)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving timetable draft"})
+		return
+	}
+	recordAudit(c, "generate", "timetable_draft", id, nil, draft)
+
+	saved, err := store.Drafts.ByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable draft"})
+		return
+	}
+	if !reviewDraft(c, &saved) {
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Timetable draft generated successfully", "id": id, "draft": saved})
+}
+
+// timetableDraftParam loads the draft named by the :id path parameter with its lessons.
+// It writes the error response itself and returns false when the draft cannot be loaded.
+func timetableDraftParam(c *gin.Context) (TimetableDraft, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid timetable draft ID"})
+		return TimetableDraft{}, false
+	}
+	return loadTimetableDraft(c, uint(id))
+}
+
+// loadTimetableDraft loads a draft with its lessons.
+// It writes the error response itself and returns false when the draft cannot be loaded.
+func loadTimetableDraft(c *gin.Context, id uint) (TimetableDraft, bool) {
+	draft, err := store.Drafts.ByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable draft not found"})
+		return draft, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable draft"})
+		return draft, false
+	}
+	return draft, true
+}
+
+// requireUnpublished rejects changes to a published draft.
+// It writes the error response itself and returns false when the draft is published.
+func requireUnpublished(c *gin.Context, draft TimetableDraft) bool {
+	if draft.PublishedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": "The timetable draft has already been published"})
+		return false
+	}
+	return true
+}
+
+func GetTimetableDrafts(c *gin.Context) {
+	drafts, err := store.Drafts.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable drafts"})
+		return
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+func GetTimetableDraft(c *gin.Context) {
+	draft, ok := timetableDraftParam(c)
+	if !ok {
+		return
+	}
+	if !reviewDraft(c, &draft) {
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// timetableDraftEntryParam loads the drafted lesson named by the :id path parameter and its draft.
+// It writes the error response itself and returns false when they cannot be loaded.
+func timetableDraftEntryParam(c *gin.Context) (TimetableDraftEntry, TimetableDraft, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid timetable draft entry ID"})
+		return TimetableDraftEntry{}, TimetableDraft{}, false
+	}
+	entry, err := store.Drafts.EntryByID(uint(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Timetable draft entry not found"})
+		return entry, TimetableDraft{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable draft entry"})
+		return entry, TimetableDraft{}, false
+	}
+	draft, ok := loadTimetableDraft(c, entry.DraftID)
+	if !ok || !requireUnpublished(c, draft) {
+		return entry, draft, false
+	}
+	return entry, draft, true
+}
+
+// UpdateTimetableDraftEntry moves a drafted lesson to another day, period or room. Without a room one it fits in is
+// picked; without a day and period the lesson is left unplaced. Moves breaking a hard constraint are rejected.
+func UpdateTimetableDraftEntry(c *gin.Context) {
+	before, draft, ok := timetableDraftEntryParam(c)
+	if !ok {
+		return
+	}
+	var change TimetableDraftEntry
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if (change.Day == "") != (change.ClassPeriod == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Day and class period must be given together"})
+		return
+	}
+
+	entry := before
+	entry.Day, entry.ClassPeriod, entry.Room = change.Day, change.ClassPeriod, strings.TrimSpace(change.Room)
+	if entry.ClassPeriod != 0 {
+		p, _, ok := draftPlanner(c, draft, entry.ID)
+		if !ok {
+			return
+		}
+		s, ok := p.slot(entry.Day, entry.ClassPeriod)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Period %d on %s is not part of the draft's week", entry.ClassPeriod, entry.Day)})
+			return
+		}
+		hours := 0
+		for _, other := range draft.Entries {
+			if other.SubjectID == entry.SubjectID {
+				hours++
+			}
+		}
+		subjects, ok := subjectNames(c, []uint{entry.SubjectID})
+		if !ok {
+			return
+		}
+		lesson := p.addLesson(entry, subjects[entry.SubjectID].Name, hours)
+		if entry.Room == "" {
+			if entry.Room, ok = p.freeRoom(lesson, s); !ok {
+				c.JSON(http.StatusConflict, gin.H{"message": "No room the lesson fits in is free " + p.when(s)})
+				return
+			}
+		} else if len(p.rooms) > 0 {
+			room := p.room(entry.Room)
+			if room.Name == "" {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Room not found"})
+				return
+			}
+			entry.Room = room.Name
+		}
+		if reason := p.check(lesson, s, entry.Room); reason != "" {
+			c.JSON(http.StatusConflict, gin.H{"message": reason})
+			return
+		}
+	} else {
+		entry.Room = ""
+	}
+
+	if err := store.Drafts.UpdateEntry(entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error updating timetable draft entry"})
+		return
+	}
+	recordAudit(c, "update", "timetable_draft_entry", entry.ID, before, entry)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable draft entry updated successfully", "entry": entry})
+}
+
+func DeleteTimetableDraftEntry(c *gin.Context) {
+	entry, _, ok := timetableDraftEntryParam(c)
+	if !ok {
+		return
+	}
+	if err := store.Drafts.DeleteEntry(entry.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting timetable draft entry"})
+		return
+	}
+	recordAudit(c, "delete", "timetable_draft_entry", entry.ID, entry, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable draft entry deleted successfully"})
+}
+
+func DeleteTimetableDraft(c *gin.Context) {
+	draft, ok := timetableDraftParam(c)
+	if !ok {
+		return
+	}
+	if err := store.Drafts.Delete(draft.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting timetable draft"})
+		return
+	}
+	recordAudit(c, "delete", "timetable_draft", draft.ID, draft, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable draft deleted successfully"})
+}
+
+// PublishTimetableDraft adds the lessons of a draft to the timetable of its term. The draft is checked again against
+// the current timetable, and any hard violation, such as an unplaced lesson, blocks publishing.
+// errDraftRejected stops publishing a draft whose review has already written the response
+var errDraftRejected = errors.New("timetable draft rejected")
+
+func PublishTimetableDraft(c *gin.Context) {
+	draft, ok := timetableDraftParam(c)
+	if !ok || !requireUnpublished(c, draft) {
+		return
+	}
+	publishedBy, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	// The draft is reviewed inside the publishing transaction, so that lessons and planning data changed in the
+	// meantime cannot slip past the hard constraints
+	added, err := store.Drafts.Publish(draft.ID, publishedBy, formatTimestamp(time.Now()), func() error {
+		current, err := store.Drafts.ByID(draft.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving timetable draft"})
+			return errDraftRejected
+		}
+		if !reviewDraft(c, &current) {
+			return errDraftRejected
+		}
+		var hard []DraftViolation
+		for _, violation := range current.Violations {
+			if violation.Hard {
+				hard = append(hard, violation)
+			}
+		}
+		if len(hard) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "The timetable draft breaks hard constraints", "violations": hard})
+			return errDraftRejected
+		}
+		draft = current
+		return nil
+	})
+	if err == errDraftRejected {
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"message": "The timetable draft has already been published"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error publishing timetable draft"})
+		return
+	}
+	recordAudit(c, "publish", "timetable_draft", draft.ID, nil, draft.Entries)
+	c.JSON(http.StatusOK, gin.H{"message": "Timetable draft published successfully", "added": added})
+}
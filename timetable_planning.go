@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validPreference reports whether value is a teacher_availability.preference
+func validPreference(value string) bool {
+	return value == "unavailable" || value == "avoid" || value == "prefer"
+}
+
+// idParam returns the :id path parameter, named what in the error response.
+// It writes the error response itself and returns false when the ID is invalid.
+func idParam(c *gin.Context, what string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func AddRoom(c *gin.Context) {
+	var room Room
+	if err := c.ShouldBindJSON(&room); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	room.Name, room.RoomType = strings.TrimSpace(room.Name), strings.TrimSpace(room.RoomType)
+	if room.Name == "" || room.Capacity == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Name and capacity are required"})
+		return
+	}
+	rooms, err := store.Planning.Rooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving rooms"})
+		return
+	}
+	for _, existing := range rooms {
+		if roomKey(existing.Name) == roomKey(room.Name) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Room already exists"})
+			return
+		}
+	}
+
+	id, err := store.Planning.CreateRoom(room)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving room"})
+		return
+	}
+	room.ID = id
+	recordAudit(c, "create", "room", room.ID, nil, room)
+	c.JSON(http.StatusCreated, gin.H{"message": "Room created successfully", "id": room.ID})
+}
+
+func GetRooms(c *gin.Context) {
+	rooms, err := store.Planning.Rooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving rooms"})
+		return
+	}
+	c.JSON(http.StatusOK, rooms)
+}
+
+func DeleteRoom(c *gin.Context) {
+	id, ok := idParam(c, "room")
+	if !ok {
+		return
+	}
+	rooms, err := store.Planning.Rooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving rooms"})
+		return
+	}
+	for _, room := range rooms {
+		if room.ID != id {
+			continue
+		}
+		if err := store.Planning.DeleteRoom(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting room"})
+			return
+		}
+		recordAudit(c, "delete", "room", id, room, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"message": "Room not found"})
+}
+
+// SetTeachingRequirement stores how many lessons of a subject its class has each week, replacing the previous requirement
+func SetTeachingRequirement(c *gin.Context) {
+	var requirement TeachingRequirement
+	if err := c.ShouldBindJSON(&requirement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	requirement.RoomType = strings.TrimSpace(requirement.RoomType)
+	if requirement.SubjectID == 0 || requirement.HoursPerWeek == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Subject ID and hours per week are required"})
+		return
+	}
+	if requirement.MaxPerDay != nil && *requirement.MaxPerDay == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Max per day must be at least 1"})
+		return
+	}
+	if _, err := store.Subjects.ByID(requirement.SubjectID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Subject not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving subject"})
+		return
+	}
+	requirements, err := store.Planning.Requirements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teaching requirements"})
+		return
+	}
+	var before interface{}
+	for _, existing := range requirements {
+		if existing.SubjectID == requirement.SubjectID {
+			before = existing
+		}
+	}
+
+	id, err := store.Planning.SetRequirement(requirement)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving teaching requirement"})
+		return
+	}
+	requirement.ID = id
+	recordAudit(c, "set", "teaching_requirement", requirement.ID, before, requirement)
+	c.JSON(http.StatusOK, gin.H{"message": "Teaching requirement saved successfully", "id": requirement.ID})
+}
+
+func GetTeachingRequirements(c *gin.Context) {
+	requirements, err := store.Planning.Requirements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teaching requirements"})
+		return
+	}
+	c.JSON(http.StatusOK, requirements)
+}
+
+func DeleteTeachingRequirement(c *gin.Context) {
+	id, ok := idParam(c, "teaching requirement")
+	if !ok {
+		return
+	}
+	requirements, err := store.Planning.Requirements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teaching requirements"})
+		return
+	}
+	for _, requirement := range requirements {
+		if requirement.ID != id {
+			continue
+		}
+		if err := store.Planning.DeleteRequirement(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting teaching requirement"})
+			return
+		}
+		recordAudit(c, "delete", "teaching_requirement", id, requirement, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "Teaching requirement deleted successfully"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"message": "Teaching requirement not found"})
+}
+
+// SetTeacherAvailability stores whether a teacher cannot, would rather not or would rather teach in a period,
+// or on a whole day with class period 0, replacing the previous entry for that period
+func SetTeacherAvailability(c *gin.Context) {
+	var availability TeacherAvailability
+	if err := c.ShouldBindJSON(&availability); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if availability.TeacherID == 0 || availability.Day == "" || availability.Preference == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Teacher ID, day, and preference are required"})
+		return
+	}
+	if !validWeekday(availability.Day) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Day must be a day of the week such as Monday"})
+		return
+	}
+	if !validPreference(availability.Preference) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Preference must be unavailable, avoid or prefer"})
+		return
+	}
+	if !requireTeacherAccount(c, availability.TeacherID) {
+		return
+	}
+	existing, err := store.Planning.Availability(availability.TeacherID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teacher availability"})
+		return
+	}
+	var before interface{}
+	for _, period := range existing {
+		if period.Day == availability.Day && period.ClassPeriod == availability.ClassPeriod {
+			before = period
+		}
+	}
+
+	id, err := store.Planning.SetAvailability(availability)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving teacher availability"})
+		return
+	}
+	availability.ID = id
+	recordAudit(c, "set", "teacher_availability", availability.ID, before, availability)
+	c.JSON(http.StatusOK, gin.H{"message": "Teacher availability saved successfully", "id": availability.ID})
+}
+
+// GetTeacherAvailability lists the availability of the teacher given with ?teacher_id=, or of every teacher
+func GetTeacherAvailability(c *gin.Context) {
+	var teacherID uint64
+	if value := c.Query("teacher_id"); value != "" {
+		var err error
+		if teacherID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid teacher_id"})
+			return
+		}
+	}
+	availability, err := store.Planning.Availability(uint(teacherID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teacher availability"})
+		return
+	}
+	c.JSON(http.StatusOK, availability)
+}
+
+func DeleteTeacherAvailability(c *gin.Context) {
+	id, ok := idParam(c, "teacher availability")
+	if !ok {
+		return
+	}
+	availability, err := store.Planning.Availability(0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving teacher availability"})
+		return
+	}
+	for _, period := range availability {
+		if period.ID != id {
+			continue
+		}
+		if err := store.Planning.DeleteAvailability(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error deleting teacher availability"})
+			return
+		}
+		recordAudit(c, "delete", "teacher_availability", id, period, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "Teacher availability deleted successfully"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"message": "Teacher availability not found"})
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	recoveryCodeCount = 10
+	challengeTTL      = 5 * time.Minute
+)
+
+// ChallengeClaims represents the short-lived token linking the password step of a login to its second factor
+type ChallengeClaims struct {
+	UserID  uint   `json:"uid"`     // Reference to users(uid)
+	Purpose string `json:"purpose"` // Always "2fa"
+	jwt.StandardClaims
+}
+
+func signChallengeToken(userID uint) (string, error) {
+	claims := &ChallengeClaims{
+		UserID:  userID,
+		Purpose: "2fa",
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(challengeTTL).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
+
+func parseChallengeToken(tokenString string) (uint, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ChallengeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid {
+		return 0, jwt.ErrSignatureInvalid
+	}
+	claims, ok := token.Claims.(*ChallengeClaims)
+	if !ok || claims.Purpose != "2fa" || claims.UserID == 0 {
+		return 0, jwt.ErrSignatureInvalid
+	}
+	return claims.UserID, nil
+}
+
+// totpEnabled reports whether the user has a confirmed authenticator
+func totpEnabled(userID uint) (bool, error) {
+	var enabled bool
+	err := db.QueryRow("SELECT enabled FROM user_totp WHERE user_id = ?", userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// mfaRequired reports whether the admin policy requires two-factor authentication for the role
+func mfaRequired(role string) (bool, error) {
+	var required bool
+	err := db.QueryRow("SELECT required FROM mfa_policy WHERE role = ?", role).Scan(&required)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
+
+// mfaSetupPending reports whether the user must enroll an authenticator before using the API
+func mfaSetupPending(userID uint, role string) (bool, error) {
+	required, err := mfaRequired(role)
+	if err != nil || !required {
+		return false, err
+	}
+	enabled, err := totpEnabled(userID)
+	return !enabled, err
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code, consuming it on success
+func verifySecondFactor(userID uint, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		result, err := db.Exec("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+			formatTimestamp(time.Now()), userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected == 1, err
+	}
+
+	var secret string
+	var lastStep int64
+	err := db.QueryRow("SELECT secret, last_step FROM user_totp WHERE user_id = ? AND enabled = 1", userID).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	step, ok := verifyTOTP(secret, code, lastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// Guarded by last_step so that a code racing with itself is accepted only once
+	result, err := db.Exec("UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and stores a fresh set, returning them in plain text
+func replaceRecoveryCodes(tx *Tx, userID uint) ([]string, error) {
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func LoginTwoFactor(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.ChallengeToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if request.Code == "" && request.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Code or recovery code is required"})
+		return
+	}
+
+	userID, err := parseChallengeToken(request.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired challenge"})
+		return
+	}
+
+	var user User
+	err = db.QueryRow("SELECT uid, email, role FROM users WHERE uid = ?", userID).Scan(&user.UID, &user.Email, &user.Role)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired challenge"})
+		return
+	}
+
+	// Second-factor guesses count towards the same lockout as password guesses
+	ip := c.ClientIP()
+	wait, err := checkLoginThrottle(ip, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking login attempts"})
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(c, wait)
+		return
+	}
+
+	ok, err := verifySecondFactor(user.UID, request.Code, request.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error verifying code"})
+		return
+	}
+	if !ok {
+		if err := recordLoginFailure(ip, user.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid code"})
+		return
+	}
+
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+		return
+	}
+
+	issueTokens(c, user.UID, user.Email, user.Role)
+}
+
+func EnrollTwoFactor(c *gin.Context) {
+	email, _ := c.Get("email")
+	var user User
+	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+
+	enabled, err := totpEnabled(user.UID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking two-factor authentication"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"message": "Two-factor authentication already enabled"})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate secret"})
+		return
+	}
+
+	_, err = db.Exec(`INSERT INTO user_totp (user_id, secret, enabled, last_step, created_at) VALUES (?, ?, 0, 0, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled = 0, last_step = 0, created_at = excluded.created_at, enabled_at = NULL`,
+		user.UID, secret, formatTimestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving secret"})
+		return
+	}
+
+	// The secret itself is never written to the audit log
+	recordAudit(c, "enroll_2fa", "user", user.UID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totpURI(secret, user.Email),
+	})
+}
+
+func ConfirmTwoFactor(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	email, _ := c.Get("email")
+	var user User
+	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	if !throttleAttempt(c, user.Email) {
+		return
+	}
+
+	var secret string
+	err = db.QueryRow("SELECT secret FROM user_totp WHERE user_id = ? AND enabled = 0", user.UID).Scan(&secret)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "No pending enrollment"})
+		return
+	}
+
+	step, ok := verifyTOTP(secret, request.Code, 0, time.Now())
+	if !ok {
+		failAttempt(c, user.Email, "Invalid code")
+		return
+	}
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_totp SET enabled = 1, last_step = ?, enabled_at = ? WHERE user_id = ?", step, formatTimestamp(time.Now()), user.UID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error enabling two-factor authentication"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(tx, user.UID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving recovery codes"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
+		return
+	}
+
+	recordAudit(c, "enable_2fa", "user", user.UID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	email, _ := c.Get("email")
+	var user User
+	err := db.QueryRow("SELECT uid, email, password, role FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email, &user.Password, &user.Role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	if !throttleAttempt(c, user.Email) {
+		return
+	}
+
+	required, err := mfaRequired(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error checking two-factor policy"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	if !CheckPasswordHash(request.Password, user.Password) {
+		failAttempt(c, user.Email, "Incorrect password")
+		return
+	}
+	ok, err := verifySecondFactor(user.UID, request.Code, request.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error verifying code"})
+		return
+	}
+	if !ok {
+		failAttempt(c, user.Email, "Invalid code")
+		return
+	}
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+		return
+	}
+
+	if err := deleteTwoFactor(user.UID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error disabling two-factor authentication"})
+		return
+	}
+
+	recordAudit(c, "disable_2fa", "user", user.UID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var request TwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	email, _ := c.Get("email")
+	var user User
+	err := db.QueryRow("SELECT uid, email FROM users WHERE email = ?", email).Scan(&user.UID, &user.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return
+	}
+	if !throttleAttempt(c, user.Email) {
+		return
+	}
+
+	ok, err := verifySecondFactor(user.UID, request.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error verifying code"})
+		return
+	}
+	if !ok {
+		failAttempt(c, user.Email, "Invalid code")
+		return
+	}
+	if err := clearLoginFailures(user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error recording login attempt"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error starting transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, user.UID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving recovery codes"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error committing transaction"})
+		return
+	}
+
+	recordAudit(c, "regenerate_recovery_codes", "user", user.UID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// deleteTwoFactor removes the authenticator and recovery codes of a user
+func deleteTwoFactor(userID uint) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ResetTwoFactor(c *gin.Context) {
+	var user User
+	if err := c.ShouldBindJSON(&user); err != nil || user.UID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+
+	if err := deleteTwoFactor(user.UID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error resetting two-factor authentication"})
+		return
+	}
+	// Sessions opened with the lost device must not outlive the reset
+	if err := revokeUserSessions(user.UID, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error revoking sessions"})
+		return
+	}
+
+	recordAudit(c, "reset_2fa", "user", user.UID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+func GetMFAPolicy(c *gin.Context) {
+	var policies []MFAPolicy
+	for _, role := range userRoles {
+		required, err := mfaRequired(role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving two-factor policy"})
+			return
+		}
+		policies = append(policies, MFAPolicy{Role: role, Required: required})
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+func SetMFAPolicy(c *gin.Context) {
+	var policy MFAPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		return
+	}
+	if !validRole(policy.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid role"})
+		return
+	}
+
+	wasRequired, err := mfaRequired(policy.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error retrieving two-factor policy"})
+		return
+	}
+
+	_, err = db.Exec("INSERT INTO mfa_policy (role, required) VALUES (?, ?) ON CONFLICT(role) DO UPDATE SET required = excluded.required",
+		policy.Role, policy.Required)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error saving two-factor policy"})
+		return
+	}
+
+	recordAudit(c, "update", "mfa_policy", policy.Role, MFAPolicy{Role: policy.Role, Required: wasRequired}, policy)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor policy updated successfully"})
+}
@@ -1,7 +1,10 @@
 package main
 import (
 	"golang.org/x/crypto/bcrypt"
+    "fmt"
     "math/rand"
+    "os"
+    "strconv"
     "sync"
     "time"
 )
@@ -11,6 +14,18 @@ var (
 	mu               sync.Mutex
 )
 
+// userRoles lists the roles accepted in users.role
+var userRoles = []string{"student", "teacher", "admin", "parent"}
+
+func validRole(role string) bool {
+    for _, r := range userRoles {
+        if r == role {
+            return true
+        }
+    }
+    return false
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -20,13 +35,6 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
-func TableExists(tableName string) bool {
-    query := `SELECT name FROM sqlite_master WHERE type='table' AND name=?;`
-    var name string
-    err := db.QueryRow(query, tableName).Scan(&name)
-    return err == nil
-}
-
 func generateRandomNumber() int {
     return rand.Intn(35) + 1
 }
@@ -44,4 +52,48 @@ func getRandomNumber() int {
     randomNum = generateRandomNumber()
     lastGeneratedDate = today
     return randomNum
-}
\ No newline at end of file
+}
+
+// formatTimestamp formats a point in time as stored in the database (RFC 3339, UTC)
+func formatTimestamp(t time.Time) string {
+    return t.UTC().Format(time.RFC3339)
+}
+
+// intFromEnv reads an integer from the environment, keeping the default when unset
+func intFromEnv(name string, def int) (int, error) {
+    value, exists := os.LookupEnv(name)
+    if !exists {
+        return def, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %w", name, err)
+    }
+    return n, nil
+}
+
+// boolFromEnv reads a boolean ("true", "false", "1", "0", ...) from the environment, keeping the default when unset
+func boolFromEnv(name string, def bool) (bool, error) {
+    value, exists := os.LookupEnv(name)
+    if !exists {
+        return def, nil
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        return false, fmt.Errorf("invalid %s: %w", name, err)
+    }
+    return b, nil
+}
+
+// durationFromEnv reads a Go duration from the environment, keeping the default when unset
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+    value, exists := os.LookupEnv(name)
+    if !exists {
+        return def, nil
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %w", name, err)
+    }
+    return d, nil
+}